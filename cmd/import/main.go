@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/burakkarasel/bookings/internal/config"
+	"github.com/burakkarasel/bookings/internal/csvimport"
+	"github.com/burakkarasel/bookings/internal/driver"
+	"github.com/burakkarasel/bookings/internal/repository/dbrepo"
+)
+
+// import command does the same as the admin import page, it checks the rows of a CSV file and imports them only if
+// -commit is given and none of the rows has errors
+func main() {
+	file := flag.String("file", "", "CSV file to import")
+	commit := flag.Bool("commit", false, "Import the rows, otherwise rows are only checked")
+	dbName := flag.String("dbname", "", "Database name")
+	dbUser := flag.String("dbuser", "", "Database username")
+	dbPw := flag.String("dbpw", "", "Database password")
+	dbHost := flag.String("dbhost", "localhost", "Database host")
+	dbPort := flag.String("dbport", "5432", "Database port")
+	dbSSL := flag.String("dbssl", "disable", "Database ssl settings (disable, prefer, require)")
+
+	flag.Parse()
+
+	if *file == "" || *dbName == "" || *dbUser == "" {
+		fmt.Println("missing required flags")
+		os.Exit(1)
+	}
+
+	var app config.AppConfig
+	app.InfoLog = log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
+	app.ErrorLog = log.New(os.Stdout, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
+
+	connectionString := fmt.Sprintf("host=%s port=%s dbname=%s user=%s password=%s sslmode=%s", *dbHost, *dbPort, *dbName, *dbUser, *dbPw, *dbSSL)
+	db, err := driver.ConnectSQL(connectionString)
+
+	if err != nil {
+		log.Fatal("Cannot connect to DB:", err)
+	}
+
+	defer db.SQL.Close()
+
+	f, err := os.Open(*file)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer f.Close()
+
+	importer := csvimport.NewImporter(dbrepo.NewPostgresRepo(db.SQL, &app))
+
+	rows, err := importer.Parse(f)
+
+	if err != nil {
+		log.Fatal("Cannot read the import file: ", err)
+	}
+
+	for _, r := range rows {
+		if !r.Valid() {
+			fmt.Printf("line %d: %s\n", r.Line, strings.Join(r.Errors, "; "))
+		}
+	}
+
+	if !csvimport.Valid(rows) {
+		log.Fatal("Nothing is imported, fix the rows with errors and try again")
+	}
+
+	if !*commit {
+		fmt.Printf("%d rows can be imported, run again with -commit to import them\n", len(rows))
+		return
+	}
+
+	err = importer.Commit(rows)
+
+	if err != nil {
+		log.Fatal("Nothing is imported: ", err)
+	}
+
+	fmt.Printf("%d rows imported\n", len(rows))
+}
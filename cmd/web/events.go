@@ -0,0 +1,13 @@
+package main
+
+// listenForEvents runs asynchronously while our program runs, if event channel receives an event it dispatches it to
+// the webhook endpoints and the admin pages listening for live updates
+func listenForEvents() {
+	go func() {
+		for {
+			e := <-app.EventChan
+			dispatcher.Dispatch(e)
+			app.Broker.Publish(e)
+		}
+	}()
+}
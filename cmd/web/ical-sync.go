@@ -0,0 +1,13 @@
+package main
+
+import "time"
+
+// listenForICalSync runs asynchronously while our program runs, and syncs the calendar imports every interval
+func listenForICalSync(interval time.Duration) {
+	go func() {
+		for {
+			syncer.SyncAll()
+			time.Sleep(interval)
+		}
+	}()
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"time"
+
+	"github.com/burakkarasel/bookings/internal/handlers"
+)
+
+// loginFailureCleanupInterval is how often old failed logins are removed, anyone can add them, so they are removed
+// even though they aren't counted after the lockout
+const loginFailureCleanupInterval = 15 * time.Minute
+
+// listenForLoginFailureCleanup runs asynchronously while our program runs, and removes old failed logins every interval
+func listenForLoginFailureCleanup(interval time.Duration) {
+	go func() {
+		for {
+			n, err := handlers.Repo.CleanUpLoginFailures()
+
+			if err != nil {
+				errorLog.Println("cannot clean up failed logins:", err)
+			} else if n > 0 {
+				infoLog.Printf("cleaned up %d old failed logins", n)
+			}
+
+			time.Sleep(interval)
+		}
+	}()
+}
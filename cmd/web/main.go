@@ -7,6 +7,8 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/alexedwards/scs/v2"
@@ -14,8 +16,12 @@ import (
 	"github.com/burakkarasel/bookings/internal/driver"
 	"github.com/burakkarasel/bookings/internal/handlers"
 	"github.com/burakkarasel/bookings/internal/helpers"
+	"github.com/burakkarasel/bookings/internal/icalsync"
+	"github.com/burakkarasel/bookings/internal/live"
 	"github.com/burakkarasel/bookings/internal/models"
+	"github.com/burakkarasel/bookings/internal/sessionstore"
 	"github.com/burakkarasel/bookings/internal/utils"
+	"github.com/burakkarasel/bookings/internal/webhooks"
 )
 
 var port = ":8080"
@@ -24,6 +30,17 @@ var app config.AppConfig
 
 var session *scs.SessionManager
 
+var dispatcher *webhooks.Dispatcher
+
+var syncer *icalsync.Syncer
+
+var icalSyncInterval time.Duration
+
+// sessionStore is nil when sessions are kept in memory
+var sessionStore *sessionstore.PostgresStore
+
+var sessionCleanupInterval time.Duration
+
 var infoLog *log.Logger
 var errorLog *log.Logger
 
@@ -43,6 +60,21 @@ func main() {
 	log.Println("Starting mail listener!")
 	listenForMail()
 
+	defer close(app.EventChan)
+	log.Println("Starting event listener!")
+	listenForEvents()
+
+	log.Println("Starting calendar import sync!")
+	listenForICalSync(icalSyncInterval)
+
+	log.Println("Starting failed login cleanup!")
+	listenForLoginFailureCleanup(loginFailureCleanupInterval)
+
+	if sessionStore != nil {
+		log.Println("Starting session cleanup!")
+		listenForSessionCleanup(sessionStore, sessionCleanupInterval)
+	}
+
 	fmt.Println("starting at port", port)
 
 	srv := &http.Server{
@@ -84,6 +116,12 @@ func run() (*driver.DB, error) {
 	dbHost := flag.String("dbhost", "localhost", "Database host")
 	dbPort := flag.String("dbport", "5432", "Database port")
 	dbSSL := flag.String("dbssl", "disable", "Database ssl settings (disable, prefer, require)")
+	baseURL := flag.String("baseurl", "", "Scheme and host of the public site for links in emails, e.g. https://bookings.example.com")
+	ipHeader := flag.String("ipheader", "", "Header the reverse proxy sends the client's IP address in, e.g. X-Forwarded-For, empty uses the connection's address")
+	icalSync := flag.Duration("icalsync", 15*time.Minute, "How often calendar imports are synced")
+	twoFactorRoles := flag.String("2fa-roles", "", "Comma separated access levels of the roles that must use two-factor authentication")
+	sessions := flag.String("sessions", "postgres", "Session store (postgres, memory), memory sessions are lost on restarts and aren't shared by app instances")
+	sessionCleanup := flag.Duration("sessioncleanup", 5*time.Minute, "How often expired sessions are removed from the postgres session store")
 
 	flag.Parse()
 
@@ -92,11 +130,43 @@ func run() (*driver.DB, error) {
 		os.Exit(1)
 	}
 
+	if !strings.HasPrefix(*baseURL, "http://") && !strings.HasPrefix(*baseURL, "https://") {
+		return nil, fmt.Errorf("baseurl must start with http:// or https://: %q", *baseURL)
+	}
+
+	app.BaseURL = strings.TrimSuffix(*baseURL, "/")
+	app.IPHeader = *ipHeader
+
 	app.InProduction = *inProduction
+	icalSyncInterval = *icalSync
+	sessionCleanupInterval = *sessionCleanup
+
+	if *sessions != "postgres" && *sessions != "memory" {
+		return nil, fmt.Errorf("unknown session store: %s", *sessions)
+	}
+
+	for _, level := range strings.Split(*twoFactorRoles, ",") {
+		if strings.TrimSpace(level) == "" {
+			continue
+		}
+
+		accessLevel, err := strconv.Atoi(strings.TrimSpace(level))
+
+		if err != nil || !models.IsRole(accessLevel) {
+			return nil, fmt.Errorf("unknown role in 2fa-roles: %s", level)
+		}
+
+		app.TwoFactorRoles = append(app.TwoFactorRoles, accessLevel)
+	}
 
 	mailChan := make(chan models.MailData)
 	app.MailChan = mailChan
 
+	eventChan := make(chan models.Event)
+	app.EventChan = eventChan
+
+	app.Broker = live.NewBroker()
+
 	session = scs.New()
 	session.Lifetime = 24 * time.Hour
 	session.Cookie.Persist = true
@@ -117,6 +187,12 @@ func run() (*driver.DB, error) {
 
 	log.Println("Connected to DB!")
 
+	// sessions use scs's memory store unless they are kept in postgres
+	if *sessions == "postgres" {
+		sessionStore = sessionstore.NewPostgresStore(db.SQL)
+		session.Store = sessionStore
+	}
+
 	tc, err := utils.CreateTemplateCache()
 
 	if err != nil {
@@ -130,6 +206,9 @@ func run() (*driver.DB, error) {
 	repo := handlers.NewRepo(&app, db)
 	handlers.NewHandlers(repo)
 
+	dispatcher = webhooks.NewDispatcher(&app, repo.DB)
+	syncer = icalsync.NewSyncer(&app, repo.DB)
+
 	utils.NewRenderer(&app)
 	helpers.NewHelpers(&app)
 
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"github.com/burakkarasel/bookings/internal/handlers"
 	"github.com/burakkarasel/bookings/internal/helpers"
 	"github.com/justinas/nosurf"
 	"net/http"
+	"strings"
 )
 
 // NoSurf adds CSRF protection to all POST requests
@@ -19,12 +21,35 @@ func NoSurf(next http.Handler) http.Handler {
 	return csrfHandler
 }
 
-// SessionLoad loads and saves session data
+// SessionLoad loads and saves session data, event streams only load it because LoadAndSave buffers the response until
+// the handler returns, and a stream never returns while the page is open
 func SessionLoad(next http.Handler) http.Handler {
-	return session.LoadAndSave(next)
+	loadAndSave := session.LoadAndSave(next)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != "text/event-stream" {
+			loadAndSave.ServeHTTP(w, r)
+			return
+		}
+
+		var token string
+		if cookie, err := r.Cookie(session.Cookie.Name); err == nil {
+			token = cookie.Value
+		}
+
+		ctx, err := session.Load(r.Context(), token)
+
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
 }
 
-// Auth protects our routes needs to be protected
+// Auth protects our routes needs to be protected, it loads the logged-in user into the request's context, and logs
+// out the users that are deactivated or whose passwords are reset or changed since they logged in
 func Auth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if !helpers.IsAuthenticated(r) {
@@ -32,6 +57,41 @@ func Auth(next http.Handler) http.Handler {
 			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
 			return
 		}
-		next.ServeHTTP(w, r)
+
+		user, err := handlers.Repo.DB.GetUserById(session.GetInt(r.Context(), "user_id"))
+
+		// the session keeps the fingerprint of the password the user logged in with
+		changed := session.GetString(r.Context(), "password_fingerprint") != helpers.PasswordFingerprint(user.Password)
+
+		if err != nil || user.Active == 0 || user.Invited() || changed {
+			_ = session.Destroy(r.Context())
+			_ = session.RenewToken(r.Context())
+			session.Put(r.Context(), "error", "Your session has ended, log in again")
+			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+			return
+		}
+
+		// users whose roles must use two-factor authentication can only set it up until they do
+		if helpers.RequiresTwoFactor(user) && !user.TwoFactorEnabled() && !strings.HasPrefix(r.URL.Path, "/admin/two-factor") {
+			session.Put(r.Context(), "warning", "Your role requires two-factor authentication, set it up to continue")
+			http.Redirect(w, r, "/admin/two-factor", http.StatusSeeOther)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(helpers.WithUser(r.Context(), user)))
 	})
 }
+
+// Can lets only the users whose roles have the permission through, it must be used after Auth
+func Can(permission string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !helpers.CurrentUser(r).Can(permission) {
+				session.Put(r.Context(), "error", "You don't have permission to do that")
+				http.Redirect(w, r, "/admin/dashboard", http.StatusSeeOther)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -2,7 +2,12 @@ package main
 
 import (
 	"net/http"
+	"net/http/httptest"
 	"testing"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/burakkarasel/bookings/internal/helpers"
+	"github.com/burakkarasel/bookings/internal/models"
 )
 
 // TestNoSurf is test func for NoSurf func in middleware.go. It checks return type of the func NoSurf.
@@ -32,3 +37,33 @@ func TestSessionLoad(t *testing.T) {
 		t.Errorf("type is not http.Handler, it is %T", v)
 	}
 }
+
+// TestCan is test func for Can func in middleware.go. It checks if only the users with the permission are let through.
+func TestCan(t *testing.T) {
+	session = scs.New()
+
+	var tests = []struct {
+		name               string
+		accessLevel        int
+		expectedStatusCode int
+	}{
+		{"owner", models.RoleOwner, http.StatusOK},
+		{"viewer", models.RoleViewer, http.StatusSeeOther},
+		{"unknown role", 0, http.StatusSeeOther},
+	}
+
+	var myH myHandler
+	h := session.LoadAndSave(Can(models.PermManageUsers)(&myH))
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/admin/users", nil)
+		req = req.WithContext(helpers.WithUser(req.Context(), models.User{AccessLevel: tt.accessLevel}))
+
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+
+		if rr.Code != tt.expectedStatusCode {
+			t.Errorf("for %s: got status code %d, wanted %d", tt.name, rr.Code, tt.expectedStatusCode)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/burakkarasel/bookings/internal/config"
 	"github.com/burakkarasel/bookings/internal/handlers"
+	"github.com/burakkarasel/bookings/internal/models"
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
 )
@@ -38,6 +39,15 @@ func routes(app *config.AppConfig) http.Handler {
 	mux.Get("/user/login", handlers.Repo.ShowLogin)
 	mux.Post("/user/login", handlers.Repo.PostShowLogin)
 	mux.Get("/user/logout", handlers.Repo.Logout)
+	mux.Get("/user/set-password", handlers.Repo.ShowSetPassword)
+	mux.Post("/user/set-password", handlers.Repo.PostSetPassword)
+	mux.Get("/user/forgot-password", handlers.Repo.ShowForgotPassword)
+	mux.Post("/user/forgot-password", handlers.Repo.PostForgotPassword)
+	mux.Get("/user/login/two-factor", handlers.Repo.ShowLoginTwoFactor)
+	mux.Post("/user/login/two-factor", handlers.Repo.PostLoginTwoFactor)
+	mux.Get("/user/confirm-email", handlers.Repo.ConfirmEmail)
+
+	mux.Get("/ical/rooms/{id}.ics", handlers.Repo.RoomICalFeed)
 
 	fileServer := http.FileServer(http.Dir("./static/"))
 	mux.Handle("/static/*", http.StripPrefix("/static", fileServer))
@@ -47,16 +57,81 @@ func routes(app *config.AppConfig) http.Handler {
 
 		mux.Get("/dashboard", handlers.Repo.AdminDashboard)
 
-		mux.Get("/reservations-new", handlers.Repo.AdminNewReservations)
-		mux.Get("/reservations-all", handlers.Repo.AdminAllReservations)
-		mux.Get("/reservations-calendar", handlers.Repo.AdminReservationsCalendar)
-		mux.Post("/reservations-calendar", handlers.Repo.AdminPostReservationsCalendar)
-
-		mux.Get("/process-reservations/{src}/{id}/do", handlers.Repo.AdminProcessedReservation)
-		mux.Get("/delete-reservations/{src}/{id}/do", handlers.Repo.AdminDeleteReservation)
-
-		mux.Get("/reservations/{src}/{id}/show", handlers.Repo.AdminShowReservationDetail)
-		mux.Post("/reservations/{src}/{id}", handlers.Repo.AdminPostShowReservationDetail)
+		// every user can edit their own account and set up their own two-factor authentication
+		mux.Get("/account", handlers.Repo.AdminAccount)
+		mux.Post("/account", handlers.Repo.AdminPostAccount)
+		mux.Post("/account/password", handlers.Repo.AdminPostAccountPassword)
+		mux.Get("/two-factor", handlers.Repo.AdminTwoFactor)
+		mux.Post("/two-factor", handlers.Repo.AdminPostTwoFactor)
+		mux.Post("/two-factor/recovery-codes", handlers.Repo.AdminPostRecoveryCodes)
+		mux.Post("/two-factor/disable", handlers.Repo.AdminPostDisableTwoFactor)
+
+		// each route requires the permission of the action it performs, roles and their permissions are in models
+		view := mux.With(Can(models.PermViewReservations))
+		edit := mux.With(Can(models.PermEditReservations))
+		del := mux.With(Can(models.PermDeleteReservations))
+		export := mux.With(Can(models.PermExportReservations))
+		rooms := mux.With(Can(models.PermManageRooms))
+		users := mux.With(Can(models.PermManageUsers))
+		integrations := mux.With(Can(models.PermManageIntegrations))
+		audit := mux.With(Can(models.PermViewAuditLog))
+
+		view.Get("/events", handlers.Repo.AdminLiveEvents)
+		view.Get("/search", handlers.Repo.AdminSearch)
+
+		view.Get("/reservations-new", handlers.Repo.AdminNewReservations)
+		view.Get("/reservations-all", handlers.Repo.AdminAllReservations)
+		// each bulk action checks its own permission
+		view.Post("/reservations-bulk", handlers.Repo.AdminPostBulkReservations)
+		edit.Get("/reservations-create", handlers.Repo.AdminNewReservation)
+		edit.Post("/reservations-create", handlers.Repo.AdminPostNewReservation)
+		export.Get("/reservations-export", handlers.Repo.AdminExportReservations)
+		edit.Get("/reservations-import", handlers.Repo.AdminImportReservations)
+		edit.Post("/reservations-import", handlers.Repo.AdminPostImportReservations)
+		view.Get("/reservations-calendar", handlers.Repo.AdminReservationsCalendar)
+		edit.Post("/reservations-calendar", handlers.Repo.AdminPostReservationsCalendar)
+		view.Get("/reservations-timeline", handlers.Repo.AdminReservationsTimeline)
+		view.Get("/reports-daily", handlers.Repo.AdminDailyReport)
+
+		edit.Get("/process-reservations/{src}/{id}/do", handlers.Repo.AdminProcessedReservation)
+		del.Get("/delete-reservations/{src}/{id}/do", handlers.Repo.AdminDeleteReservation)
+
+		view.Get("/reservations/{src}/{id}/show", handlers.Repo.AdminShowReservationDetail)
+		edit.Post("/reservations/{src}/{id}", handlers.Repo.AdminPostShowReservationDetail)
+		edit.Post("/reservations/{src}/{id}/notes", handlers.Repo.AdminPostReservationNote)
+
+		view.Get("/guests", handlers.Repo.AdminGuests)
+		view.Get("/guests/{id}/show", handlers.Repo.AdminShowGuest)
+		edit.Post("/guests/{id}", handlers.Repo.AdminPostShowGuest)
+		edit.Post("/merge-guests/{id}", handlers.Repo.AdminPostMergeGuest)
+
+		rooms.Get("/rooms", handlers.Repo.AdminRooms)
+		rooms.Get("/rooms/{id}/show", handlers.Repo.AdminShowRoom)
+		rooms.Post("/rooms/{id}", handlers.Repo.AdminPostShowRoom)
+		rooms.Get("/move-rooms/{id}/do", handlers.Repo.AdminMoveRoom)
+
+		users.Get("/users", handlers.Repo.AdminUsers)
+		users.Get("/users/{id}/show", handlers.Repo.AdminShowUser)
+		users.Post("/users/{id}", handlers.Repo.AdminPostShowUser)
+		users.Post("/reset-users/{id}", handlers.Repo.AdminPostResetUserPassword)
+		users.Post("/reset-two-factor/{id}", handlers.Repo.AdminPostResetTwoFactor)
+		users.Post("/unlock-logins", handlers.Repo.AdminPostUnlockLogins)
+
+		integrations.Get("/webhooks", handlers.Repo.AdminWebhooks)
+		integrations.Get("/webhooks/{id}/show", handlers.Repo.AdminShowWebhook)
+		integrations.Post("/webhooks/{id}", handlers.Repo.AdminPostShowWebhook)
+		integrations.Post("/delete-webhooks/{id}", handlers.Repo.AdminPostDeleteWebhook)
+
+		integrations.Get("/ical-feeds", handlers.Repo.AdminICalFeeds)
+		integrations.Post("/ical-feeds", handlers.Repo.AdminPostICalFeeds)
+		integrations.Post("/delete-ical-feeds/{id}", handlers.Repo.AdminPostDeleteICalFeed)
+
+		integrations.Get("/ical-imports", handlers.Repo.AdminICalImports)
+		integrations.Post("/ical-imports", handlers.Repo.AdminPostICalImports)
+		integrations.Post("/sync-ical-imports/{id}", handlers.Repo.AdminPostSyncICalImport)
+		integrations.Post("/delete-ical-imports/{id}", handlers.Repo.AdminPostDeleteICalImport)
+
+		audit.Get("/audit-log", handlers.Repo.AdminAuditLog)
 	})
 
 	return mux
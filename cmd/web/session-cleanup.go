@@ -0,0 +1,25 @@
+package main
+
+import "time"
+
+// expiredSessionDeleter is the part of the session store the cleanup uses
+type expiredSessionDeleter interface {
+	DeleteExpired() (int64, error)
+}
+
+// listenForSessionCleanup runs asynchronously while our program runs, and removes the expired sessions every interval
+func listenForSessionCleanup(store expiredSessionDeleter, interval time.Duration) {
+	go func() {
+		for {
+			n, err := store.DeleteExpired()
+
+			if err != nil {
+				errorLog.Println("cannot clean up sessions:", err)
+			} else if n > 0 {
+				infoLog.Printf("cleaned up %d expired sessions", n)
+			}
+
+			time.Sleep(interval)
+		}
+	}()
+}
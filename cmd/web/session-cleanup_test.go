@@ -0,0 +1,42 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"log"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeSessionStore counts the cleanups, every other one fails
+type fakeSessionStore struct {
+	calls int32
+}
+
+func (s *fakeSessionStore) DeleteExpired() (int64, error) {
+	if atomic.AddInt32(&s.calls, 1)%2 == 0 {
+		return 0, errors.New("connection refused")
+	}
+
+	return 3, nil
+}
+
+// TestListenForSessionCleanup checks if the expired sessions are removed every interval, and errors don't stop it
+func TestListenForSessionCleanup(t *testing.T) {
+	infoLog = log.New(io.Discard, "", 0)
+	errorLog = log.New(io.Discard, "", 0)
+
+	store := &fakeSessionStore{}
+	listenForSessionCleanup(store, time.Millisecond)
+
+	deadline := time.Now().Add(time.Second)
+
+	for atomic.LoadInt32(&store.calls) < 4 {
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d cleanups in a second, wanted at least 4", atomic.LoadInt32(&store.calls))
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
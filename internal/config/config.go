@@ -2,6 +2,7 @@ package config
 
 import (
 	"github.com/alexedwards/scs/v2"
+	"github.com/burakkarasel/bookings/internal/live"
 	"github.com/burakkarasel/bookings/internal/models"
 	"html/template"
 	"log"
@@ -16,4 +17,13 @@ type AppConfig struct {
 	InProduction  bool
 	Session       *scs.SessionManager
 	MailChan      chan models.MailData
+	EventChan     chan models.Event
+	Broker        *live.Broker
+	// BaseURL is the scheme and host of the public site, links in emails are built from it, never from requests
+	BaseURL string
+	// IPHeader is the header a trusted reverse proxy sends the client's IP address in, empty uses the connection's
+	// address. It must only be set behind a proxy that overwrites the header, otherwise clients can choose their IP
+	IPHeader string
+	// TwoFactorRoles are the access levels of the roles that must use two-factor authentication
+	TwoFactorRoles []int
 }
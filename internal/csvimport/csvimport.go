@@ -0,0 +1,301 @@
+package csvimport
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/burakkarasel/bookings/internal/models"
+	"github.com/burakkarasel/bookings/internal/repository"
+)
+
+const dateLayout = "2006-01-02"
+
+// row types of the import file
+const (
+	TypeReservation = "reservation"
+	TypeBlock       = "block"
+)
+
+// Columns are the columns an import file can have, type, room, start_date and end_date are required
+var Columns = []string{"type", "first_name", "last_name", "email", "phone", "room", "start_date", "end_date"}
+
+var requiredColumns = []string{"type", "room", "start_date", "end_date"}
+
+// ErrInvalidRows is returned when rows with errors are tried to be imported
+var ErrInvalidRows = errors.New("import file has invalid rows")
+
+// Row is a row of an import file, a row can only be imported if it has no errors
+type Row struct {
+	Line        int
+	Type        string
+	Reservation models.Reservation
+	Block       models.RoomRestriction
+	Errors      []string
+}
+
+// Valid returns true if the row has no errors
+func (r Row) Valid() bool {
+	return len(r.Errors) == 0
+}
+
+// StartDate returns the first day of the row's reservation or block
+func (r Row) StartDate() time.Time {
+	if r.Type == TypeBlock {
+		return r.Block.StartDate
+	}
+	return r.Reservation.StartDate
+}
+
+// EndDate returns the last day of the row's reservation or block
+func (r Row) EndDate() time.Time {
+	if r.Type == TypeBlock {
+		return r.Block.EndDate
+	}
+	return r.Reservation.EndDate
+}
+
+// RoomID returns the room of the row's reservation or block
+func (r Row) RoomID() int {
+	if r.Type == TypeBlock {
+		return r.Block.RoomID
+	}
+	return r.Reservation.RoomID
+}
+
+// Importer reads reservations and blocks from CSV files, and saves them to the DB
+type Importer struct {
+	DB repository.DatabaseRepo
+}
+
+// NewImporter creates a new importer
+func NewImporter(db repository.DatabaseRepo) *Importer {
+	return &Importer{
+		DB: db,
+	}
+}
+
+// Valid returns true if none of the rows has errors
+func Valid(rows []Row) bool {
+	for _, r := range rows {
+		if !r.Valid() {
+			return false
+		}
+	}
+	return true
+}
+
+// Parse reads and validates all rows of an import file, problems of a row are kept in its Errors, only problems with
+// the file itself are returned as errors
+func (i *Importer) Parse(r io.Reader) ([]Row, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+
+	if err == io.EOF {
+		return nil, errors.New("import file is empty")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]int)
+	for n, h := range header {
+		// files saved by Excel start with a BOM
+		h = strings.TrimPrefix(h, "\uFEFF")
+		columns[strings.ToLower(strings.TrimSpace(h))] = n
+	}
+
+	for _, c := range requiredColumns {
+		if _, ok := columns[c]; !ok {
+			return nil, fmt.Errorf("import file doesn't have the %s column", c)
+		}
+	}
+
+	rooms, err := i.rooms()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []Row
+
+	for {
+		record, err := reader.Read()
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := reader.FieldPos(0)
+
+		value := func(column string) string {
+			n, ok := columns[column]
+			if !ok || n >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[n])
+		}
+
+		if strings.Join(record, "") == "" {
+			continue
+		}
+
+		rows = append(rows, parseRow(line, value, rooms))
+	}
+
+	err = i.checkOverlaps(rows)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return rows, nil
+}
+
+// rooms returns the rooms by their names and ids, so rows can use either of them
+func (i *Importer) rooms() (map[string]models.Room, error) {
+	rooms, err := i.DB.AllRooms()
+
+	if err != nil {
+		return nil, err
+	}
+
+	m := make(map[string]models.Room)
+	for _, r := range rooms {
+		m[strings.ToLower(r.RoomName)] = r
+		m[strconv.Itoa(r.ID)] = r
+	}
+
+	return m, nil
+}
+
+// parseRow turns a record into a row, and validates its values
+func parseRow(line int, value func(string) string, rooms map[string]models.Room) Row {
+	row := Row{
+		Line: line,
+		Type: strings.ToLower(value("type")),
+	}
+
+	addError := func(format string, a ...interface{}) {
+		row.Errors = append(row.Errors, fmt.Sprintf(format, a...))
+	}
+
+	room, ok := rooms[strings.ToLower(value("room"))]
+	if !ok {
+		addError("unknown room %q", value("room"))
+	}
+
+	start, err := time.Parse(dateLayout, value("start_date"))
+	if err != nil {
+		addError("invalid start date %q, dates must be like 2022-08-01", value("start_date"))
+	}
+
+	end, err := time.Parse(dateLayout, value("end_date"))
+	if err != nil {
+		addError("invalid end date %q, dates must be like 2022-08-01", value("end_date"))
+	}
+
+	if !start.IsZero() && !end.IsZero() && !end.After(start) {
+		addError("end date must be after start date")
+	}
+
+	switch row.Type {
+	case TypeReservation:
+		row.Reservation = models.Reservation{
+			FirstName: value("first_name"),
+			LastName:  value("last_name"),
+			Email:     value("email"),
+			Phone:     value("phone"),
+			StartDate: start,
+			EndDate:   end,
+			RoomID:    room.ID,
+			Room:      room,
+		}
+
+		for _, c := range []string{"first_name", "last_name", "email"} {
+			if value(c) == "" {
+				addError("%s is required for reservations", c)
+			}
+		}
+
+		if value("email") != "" && !govalidator.IsEmail(value("email")) {
+			addError("invalid email address %q", value("email"))
+		}
+	case TypeBlock:
+		row.Block = models.RoomRestriction{
+			StartDate:     start,
+			EndDate:       end,
+			RoomID:        room.ID,
+			Room:          room,
+			RestrictionID: 2,
+		}
+	default:
+		addError("type must be %s or %s", TypeReservation, TypeBlock)
+	}
+
+	return row
+}
+
+// checkOverlaps adds an error to the rows that overlap with existing restrictions, or with an earlier row of the file
+func (i *Importer) checkOverlaps(rows []Row) error {
+	for n := range rows {
+		if !rows[n].Valid() {
+			continue
+		}
+
+		available, err := i.DB.SearchAvailabilityByDatesByRoomID(rows[n].StartDate(), rows[n].EndDate(), rows[n].RoomID())
+
+		if err != nil {
+			return err
+		}
+
+		if !available {
+			rows[n].Errors = append(rows[n].Errors, "room is not available for these dates")
+			continue
+		}
+
+		// same as the availability check, a stay that starts on the last day of another one is an overlap
+		for _, earlier := range rows[:n] {
+			if earlier.Valid() && earlier.RoomID() == rows[n].RoomID() &&
+				!rows[n].StartDate().After(earlier.EndDate()) && !rows[n].EndDate().Before(earlier.StartDate()) {
+				rows[n].Errors = append(rows[n].Errors, fmt.Sprintf("overlaps with line %d", earlier.Line))
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+// Commit saves all of the rows in a single transaction, nothing is saved if any row is invalid or fails
+func (i *Importer) Commit(rows []Row) error {
+	if !Valid(rows) {
+		return ErrInvalidRows
+	}
+
+	var reservations []models.Reservation
+	var blocks []models.RoomRestriction
+
+	for _, r := range rows {
+		if r.Type == TypeBlock {
+			blocks = append(blocks, r.Block)
+		} else {
+			reservations = append(reservations, r.Reservation)
+		}
+	}
+
+	return i.DB.ImportReservationsAndBlocks(reservations, blocks)
+}
@@ -0,0 +1,131 @@
+package csvimport
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/burakkarasel/bookings/internal/config"
+	"github.com/burakkarasel/bookings/internal/models"
+	"github.com/burakkarasel/bookings/internal/repository"
+	"github.com/burakkarasel/bookings/internal/repository/dbrepo"
+)
+
+// roomsRepo is the testing repo with rooms, the testing repo itself doesn't have any
+type roomsRepo struct {
+	repository.DatabaseRepo
+}
+
+// AllRooms returns the rooms of the test files
+func (repo roomsRepo) AllRooms() ([]models.Room, error) {
+	return []models.Room{
+		{ID: 1, RoomName: "General's Quarters"},
+		{ID: 2, RoomName: "Major's Suite"},
+	}, nil
+}
+
+// newTestImporter creates an importer with the testing repo
+func newTestImporter() *Importer {
+	return NewImporter(roomsRepo{dbrepo.NewTestingRepo(&config.AppConfig{})})
+}
+
+// TestImporter_Parse checks if each kind of invalid row gets its error
+func TestImporter_Parse(t *testing.T) {
+	file := "\uFEFFType,First_Name,Last_Name,Email,Phone,Room,Start_Date,End_Date\n" +
+		"reservation,John,Smith,john@smith.com,555,General's Quarters,2022-08-01,2022-08-03\n" +
+		"block,,,,,2,2022-08-10,2022-08-12\n" +
+		"\n" +
+		"reservation,Jane,Doe,jane@doe.com,,Unknown Room,2022-08-01,2022-08-03\n" +
+		"reservation,Jane,Doe,jane@doe.com,,1,01/08/2022,2022-08-03\n" +
+		"reservation,Jane,Doe,jane@doe.com,,1,2022-09-03,2022-09-01\n" +
+		"reservation,Jane,,not-an-email,,1,2022-10-01,2022-10-03\n" +
+		"cleaning,,,,,1,2022-10-01,2022-10-03\n" +
+		"block,,,,,1,2022-08-02,2022-08-04\n" +
+		"block,,,,,2,2030-01-03,2030-01-05\n"
+
+	rows, err := newTestImporter().Parse(strings.NewReader(file))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		line  int
+		error string
+	}{
+		{2, ""},
+		{3, ""},
+		{5, "unknown room"},
+		{6, "invalid start date"},
+		{7, "end date must be after start date"},
+		{8, "last_name is required"},
+		{9, "type must be"},
+		{10, "overlaps with line 2"},
+		{11, "room is not available"},
+	}
+
+	if len(rows) != len(expected) {
+		t.Fatalf("got %d rows, wanted %d", len(rows), len(expected))
+	}
+
+	for n, e := range expected {
+		row := rows[n]
+
+		if row.Line != e.line {
+			t.Errorf("got line %d, wanted %d", row.Line, e.line)
+		}
+
+		if e.error == "" && !row.Valid() {
+			t.Errorf("line %d: expected no errors, got %v", e.line, row.Errors)
+		}
+
+		if e.error != "" && (row.Valid() || !strings.Contains(strings.Join(row.Errors, "; "), e.error)) {
+			t.Errorf("line %d: expected error %q, got %v", e.line, e.error, row.Errors)
+		}
+	}
+
+	if rows[0].Reservation.RoomID != 1 || rows[1].Block.RoomID != 2 {
+		t.Error("expected rooms to be found by their names and ids")
+	}
+
+	if Valid(rows) {
+		t.Error("expected rows to be invalid")
+	}
+
+	err = newTestImporter().Commit(rows)
+
+	if err != ErrInvalidRows {
+		t.Errorf("got %v, wanted %v", err, ErrInvalidRows)
+	}
+}
+
+// TestImporter_ParseMissingColumn checks if files without a required column are refused
+func TestImporter_ParseMissingColumn(t *testing.T) {
+	_, err := newTestImporter().Parse(strings.NewReader("type,room,start_date\n"))
+
+	if err == nil {
+		t.Error("expected an error for missing end_date column")
+	}
+
+	_, err = newTestImporter().Parse(strings.NewReader(""))
+
+	if err == nil {
+		t.Error("expected an error for empty file")
+	}
+}
+
+// TestImporter_Commit checks if valid rows are imported
+func TestImporter_Commit(t *testing.T) {
+	rows, err := newTestImporter().Parse(strings.NewReader("type,first_name,last_name,email,room,start_date,end_date\n" +
+		"reservation,John,Smith,john@smith.com,1,2022-08-01,2022-08-03\n" +
+		"block,,,,1,2022-08-05,2022-08-06\n"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = newTestImporter().Commit(rows)
+
+	if err != nil {
+		t.Error(err)
+	}
+}
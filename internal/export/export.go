@@ -0,0 +1,194 @@
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/burakkarasel/bookings/internal/models"
+)
+
+// utf8BOM lets Excel know that the file is UTF-8, otherwise it shows non-ascii names wrong
+const utf8BOM = "\uFEFF"
+
+// Column is a column of the reservation export
+type Column struct {
+	Key    string
+	Header string
+	Value  func(r models.Reservation) string
+}
+
+// ReservationColumns holds all of the columns admins can choose for the export, in the order they are written
+var ReservationColumns = []Column{
+	{Key: "id", Header: "ID", Value: func(r models.Reservation) string { return strconv.Itoa(r.ID) }},
+	{Key: "first_name", Header: "First Name", Value: func(r models.Reservation) string { return r.FirstName }},
+	{Key: "last_name", Header: "Last Name", Value: func(r models.Reservation) string { return r.LastName }},
+	{Key: "email", Header: "Email", Value: func(r models.Reservation) string { return r.Email }},
+	{Key: "phone", Header: "Phone", Value: func(r models.Reservation) string { return r.Phone }},
+	{Key: "room", Header: "Room", Value: func(r models.Reservation) string { return r.Room.RoomName }},
+	{Key: "start_date", Header: "Arrival", Value: func(r models.Reservation) string { return r.StartDate.Format("2006-01-02") }},
+	{Key: "end_date", Header: "Departure", Value: func(r models.Reservation) string { return r.EndDate.Format("2006-01-02") }},
+	{Key: "nights", Header: "Nights", Value: func(r models.Reservation) string {
+		return strconv.Itoa(int(r.EndDate.Sub(r.StartDate).Hours() / 24))
+	}},
+	{Key: "processed", Header: "Processed", Value: func(r models.Reservation) string {
+		if r.Processed == 1 {
+			return "yes"
+		}
+		return "no"
+	}},
+	{Key: "created_at", Header: "Booked At", Value: func(r models.Reservation) string { return r.CreatedAt.Format("2006-01-02 15:04") }},
+	{Key: "notes", Header: "Notes", Value: func(r models.Reservation) string {
+		lines := make([]string, len(r.Notes))
+		for i, n := range r.Notes {
+			lines[i] = fmt.Sprintf("%s %s: %s", n.CreatedAt.Format("2006-01-02 15:04"), n.Author(), n.Body)
+		}
+		return strings.Join(lines, "\n")
+	}},
+}
+
+// SelectColumns returns the columns with given keys in the export's order, all columns are returned if none is given
+func SelectColumns(keys []string) []Column {
+	if len(keys) == 0 {
+		return ReservationColumns
+	}
+
+	wanted := make(map[string]bool)
+	for _, k := range keys {
+		wanted[k] = true
+	}
+
+	var columns []Column
+	for _, c := range ReservationColumns {
+		if wanted[c.Key] {
+			columns = append(columns, c)
+		}
+	}
+
+	if len(columns) == 0 {
+		return ReservationColumns
+	}
+
+	return columns
+}
+
+// CSVWriter writes reservations as CSV rows that Excel can open directly
+type CSVWriter struct {
+	w       *csv.Writer
+	columns []Column
+}
+
+// NewCSVWriter writes the BOM and the header row, and returns a writer for the rows
+func NewCSVWriter(w io.Writer, columns []Column) (*CSVWriter, error) {
+	_, err := io.WriteString(w, utf8BOM)
+
+	if err != nil {
+		return nil, err
+	}
+
+	cw := &CSVWriter{
+		w:       csv.NewWriter(w),
+		columns: columns,
+	}
+	cw.w.UseCRLF = true
+
+	header := make([]string, len(columns))
+	for i, c := range columns {
+		header[i] = c.Header
+	}
+
+	err = cw.w.Write(header)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cw, nil
+}
+
+// Write writes a reservation as a row
+func (cw *CSVWriter) Write(r models.Reservation) error {
+	row := make([]string, len(cw.columns))
+	for i, c := range cw.columns {
+		row[i] = Sanitize(c.Value(r))
+	}
+
+	return cw.w.Write(row)
+}
+
+// Flush writes the buffered rows to the underlying writer
+func (cw *CSVWriter) Flush() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// Sanitize prevents values that guests typed from being run as formulas by spreadsheet programs
+func Sanitize(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// dailyReportHeader is the header row of the daily report
+var dailyReportHeader = []string{"Room", "Housekeeping", "Departing Guest", "Arriving Guest", "Arriving Until",
+	"Staying Guest", "Staying Until", "Vacant Tonight"}
+
+// WriteDailyReport writes the rooms of the daily report as CSV that Excel can open directly
+func WriteDailyReport(w io.Writer, rooms []models.DailyRoom) error {
+	_, err := io.WriteString(w, utf8BOM)
+
+	if err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(w)
+	cw.UseCRLF = true
+
+	err = cw.Write(dailyReportHeader)
+
+	if err != nil {
+		return err
+	}
+
+	guest := func(rr models.RoomRestriction) string {
+		if rr.ID == 0 {
+			return ""
+		}
+		return Sanitize(strings.TrimSpace(rr.Reservation.FirstName + " " + rr.Reservation.LastName))
+	}
+
+	until := func(rr models.RoomRestriction) string {
+		if rr.ID == 0 {
+			return ""
+		}
+		return rr.EndDate.Format("2006-01-02")
+	}
+
+	for _, d := range rooms {
+		vacant := "no"
+		if d.Vacant() {
+			vacant = "yes"
+		}
+
+		err = cw.Write([]string{
+			Sanitize(d.Room.RoomName),
+			d.Housekeeping(),
+			guest(d.Departure),
+			guest(d.Arrival),
+			until(d.Arrival),
+			guest(d.StayOver),
+			until(d.StayOver),
+			vacant,
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,130 @@
+package export
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/burakkarasel/bookings/internal/models"
+)
+
+// TestCSVWriter checks if the header and rows are written with the chosen columns
+func TestCSVWriter(t *testing.T) {
+	var buf bytes.Buffer
+
+	cw, err := NewCSVWriter(&buf, SelectColumns([]string{"last_name", "id", "nights", "unknown"}))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cw.Write(models.Reservation{
+		ID:        7,
+		LastName:  "Smith, Jr.",
+		StartDate: time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2022, 8, 4, 0, 0, 0, 0, time.UTC),
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cw.Flush()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := utf8BOM + "ID,Last Name,Nights\r\n7,\"Smith, Jr.\",3\r\n"
+
+	if buf.String() != expected {
+		t.Errorf("got %q, wanted %q", buf.String(), expected)
+	}
+}
+
+// TestNotesColumn checks if the notes are written with their dates and authors, one note per line
+func TestNotesColumn(t *testing.T) {
+	columns := SelectColumns([]string{"notes"})
+
+	value := columns[0].Value(models.Reservation{
+		Notes: []models.ReservationNote{
+			{
+				Body:      "Late arrival",
+				CreatedAt: time.Date(2022, 7, 20, 9, 30, 0, 0, time.UTC),
+				User:      models.User{ID: 1, FirstName: "John", LastName: "Smith"},
+			},
+			{
+				Body:      "Paid cash deposit",
+				CreatedAt: time.Date(2022, 7, 21, 14, 0, 0, 0, time.UTC),
+			},
+		},
+	})
+
+	expected := "2022-07-20 09:30 John Smith: Late arrival\n2022-07-21 14:00 Deleted user: Paid cash deposit"
+
+	if value != expected {
+		t.Errorf("got %q, wanted %q", value, expected)
+	}
+}
+
+// TestSelectColumns checks if all columns are returned when no valid column is chosen
+func TestSelectColumns(t *testing.T) {
+	if len(SelectColumns(nil)) != len(ReservationColumns) {
+		t.Error("expected all columns for no keys")
+	}
+
+	if len(SelectColumns([]string{"unknown"})) != len(ReservationColumns) {
+		t.Error("expected all columns for unknown keys")
+	}
+}
+
+// TestSanitize checks if values that start like formulas are escaped
+func TestSanitize(t *testing.T) {
+	for value, expected := range map[string]string{
+		"=SUM(A1:A2)": "'=SUM(A1:A2)",
+		"+905551234":  "'+905551234",
+		"@here":       "'@here",
+		"John":        "John",
+		"":            "",
+	} {
+		if got := Sanitize(value); got != expected {
+			t.Errorf("for %s got %s, wanted %s", value, got, expected)
+		}
+	}
+
+	if !strings.HasPrefix(Sanitize("-1"), "'") {
+		t.Error("expected negative looking values to be escaped")
+	}
+}
+
+// TestWriteDailyReport checks if each room of the daily report is written as a row
+func TestWriteDailyReport(t *testing.T) {
+	var buf bytes.Buffer
+
+	day := time.Date(2022, 8, 10, 0, 0, 0, 0, time.UTC)
+
+	err := WriteDailyReport(&buf, []models.DailyRoom{
+		{
+			Room:      models.Room{RoomName: "General's Quarters"},
+			Departure: models.RoomRestriction{ID: 1, Reservation: models.Reservation{FirstName: "=John", LastName: "Smith"}},
+			Arrival: models.RoomRestriction{ID: 2, EndDate: day.AddDate(0, 0, 2),
+				Reservation: models.Reservation{FirstName: "Jane", LastName: "Doe"}},
+		},
+		{
+			Room: models.Room{RoomName: "Major's Suite"},
+		},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := utf8BOM + "Room,Housekeeping,Departing Guest,Arriving Guest,Arriving Until,Staying Guest,Staying Until,Vacant Tonight\r\n" +
+		"General's Quarters,Turnover,'=John Smith,Jane Doe,2022-08-12,,,no\r\n" +
+		"Major's Suite,None,,,,,,yes\r\n"
+
+	if buf.String() != expected {
+		t.Errorf("got %q, wanted %q", buf.String(), expected)
+	}
+}
@@ -61,3 +61,63 @@ func (f *Form) IsEmail(field string) {
 		f.Errors.Add(field, "Invalid email address")
 	}
 }
+
+// IsURL checks if given value is a valid http or https URL
+func (f *Form) IsURL(field string) {
+	x := f.Get(field)
+	if !govalidator.IsRequestURL(x) || !(strings.HasPrefix(x, "http://") || strings.HasPrefix(x, "https://")) {
+		f.Errors.Add(field, "Invalid URL")
+	}
+}
+
+// Matches checks if the field has the same value as the other field, we use it to confirm passwords
+func (f *Form) Matches(field, other string) {
+	if f.Get(field) != f.Get(other) {
+		f.Errors.Add(field, "The values don't match")
+	}
+}
+
+// maxPasswordLength is the most bytes bcrypt uses of a password, the rest would be ignored silently
+const maxPasswordLength = 72
+
+// commonPasswords are passwords that are guessed first, so they aren't allowed
+var commonPasswords = []string{"password", "password1", "password123", "passw0rd", "12345678", "123456789",
+	"1234567890", "87654321", "11111111", "00000000", "qwertyui", "qwertyuiop", "qwerty123", "iloveyou", "sunshine",
+	"football", "baseball", "welcome1", "admin123", "letmein1", "trustno1", "abc12345", "abcd1234"}
+
+// IsPassword checks if the password is hard to guess, it can't be a common password, a single repeated character
+// or contain the user's personal details like the name or email
+func (f *Form) IsPassword(field string, personal ...string) {
+	x := f.Get(field)
+	lower := strings.ToLower(x)
+
+	if len(x) > maxPasswordLength {
+		f.Errors.Add(field, fmt.Sprintf("This field must be at most %d characters long", maxPasswordLength))
+		return
+	}
+
+	if x != "" && strings.Count(x, x[:1]) == len(x) {
+		f.Errors.Add(field, "The password is too easy to guess")
+		return
+	}
+
+	for _, p := range commonPasswords {
+		if lower == p {
+			f.Errors.Add(field, "The password is too easy to guess")
+			return
+		}
+	}
+
+	for _, p := range personal {
+		// emails are checked by their names too, short details would forbid too many passwords
+		p = strings.ToLower(strings.TrimSpace(p))
+		name, _, _ := strings.Cut(p, "@")
+
+		for _, s := range []string{p, name} {
+			if len(s) >= 3 && strings.Contains(lower, s) {
+				f.Errors.Add(field, "The password can't contain your name or email")
+				return
+			}
+		}
+	}
+}
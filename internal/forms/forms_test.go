@@ -2,6 +2,7 @@ package forms
 
 import (
 	"net/url"
+	"strings"
 	"testing"
 )
 
@@ -169,3 +170,85 @@ func TestForm_MinLength(t *testing.T) {
 		t.Error("expected true for len(another)valid == 11 && len(another)valid > 10 but got false")
 	}
 }
+
+// TestForm_IsURL tests our IsURL func in forms.go
+func TestForm_IsURL(t *testing.T) {
+	postedData := url.Values{}
+	form := New(postedData)
+
+	// expecting error for non-existing key
+	form.IsURL("non_existing_key")
+
+	if form.Valid() {
+		t.Error("form shows valid, even if there must be an error because we didnt create a key")
+	}
+
+	// expecting error for a value without http or https scheme
+	postedData.Add("invalid", "ftp://here.com/hook")
+	form = New(postedData)
+
+	form.IsURL("invalid")
+
+	if form.Valid() {
+		t.Error("expected not valid but returned valid")
+	}
+
+	// expecting no error
+	postedData = url.Values{}
+	postedData.Add("valid", "https://here.com/hook")
+	form = New(postedData)
+
+	form.IsURL("valid")
+
+	if !form.Valid() {
+		t.Error("expected valid but returned invalid")
+	}
+}
+
+// TestForm_Matches is a test func for Matches func in forms.go
+func TestForm_Matches(t *testing.T) {
+	postedData := url.Values{}
+	postedData.Add("password", "secret-password")
+	postedData.Add("same", "secret-password")
+	postedData.Add("different", "another-password")
+
+	form := New(postedData)
+
+	// checks matching values
+	form.Matches("same", "password")
+
+	if !form.Valid() {
+		t.Error("got an error for matching values")
+	}
+
+	// checks different values
+	form.Matches("different", "password")
+
+	if form.Errors.Get("different") == "" {
+		t.Error("expected an error for different values, but didn't get one")
+	}
+}
+
+// TestForm_IsPassword is a test func for IsPassword func in forms.go
+func TestForm_IsPassword(t *testing.T) {
+	var tests = []struct {
+		password string
+		valid    bool
+	}{
+		{"correct-horse", true},
+		{"Password1", false},
+		{"aaaaaaaaaa", false},
+		{"jane-secret", false},
+		{"my-doe-house", false},
+		{strings.Repeat("correct-horse", 6), false},
+	}
+
+	for _, tt := range tests {
+		form := New(url.Values{"password": {tt.password}})
+		form.IsPassword("password", "Jane", "Doe", "jane@here.com")
+
+		if form.Valid() != tt.valid {
+			t.Errorf("for %s: got valid %t, wanted %t", tt.password, form.Valid(), tt.valid)
+		}
+	}
+}
@@ -1,23 +1,42 @@
 package handlers
 
 import (
+	"bytes"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"html"
+	"html/template"
+	"image/png"
+	"io"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/burakkarasel/bookings/internal/config"
+	"github.com/burakkarasel/bookings/internal/csvimport"
 	"github.com/burakkarasel/bookings/internal/driver"
+	"github.com/burakkarasel/bookings/internal/export"
 	"github.com/burakkarasel/bookings/internal/forms"
 	"github.com/burakkarasel/bookings/internal/helpers"
+	"github.com/burakkarasel/bookings/internal/ical"
+	"github.com/burakkarasel/bookings/internal/icalsync"
 	"github.com/burakkarasel/bookings/internal/models"
 	"github.com/burakkarasel/bookings/internal/repository"
 	"github.com/burakkarasel/bookings/internal/repository/dbrepo"
 	"github.com/burakkarasel/bookings/internal/utils"
+	"github.com/burakkarasel/bookings/internal/webhooks"
 	"github.com/go-chi/chi"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
 )
 
 // Repository holds our app's configurations
@@ -28,6 +47,9 @@ type Repository struct {
 
 var Repo *Repository
 
+// maxUploadSize is the maximum size of the files admins can upload
+const maxUploadSize = 10 << 20
+
 // jsonResponse lets us marshal & unmarshal json data that comes with the request
 type jsonResponse struct {
 	OK        bool   `json:"ok"`
@@ -374,7 +396,42 @@ func (repo *Repository) PostMakeReservation(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	reservation.ID = newReservationID
+	repo.sendEvent(models.EventReservationCreated, reservation)
+
 	// send notifications - first to guest
+	repo.sendReservationConfirmation(reservation)
+
+	htmlOwnerMessage := fmt.Sprintf(`
+		<strong>Reservation Confirmation</strong>
+    	<br>
+    	Dear Owner, 
+		<br>
+    	This is confirmation for reservation of your %s from %s to %s.
+		You can reach the guest via this email : %s.
+	`, reservation.Room.RoomName, reservation.StartDate.Format("2006-01-02"),
+		reservation.EndDate.Format("2006-01-02"), reservation.Email)
+
+	ownerMessage := models.MailData{
+		To:       "owner@here.com",
+		From:     "me@here.com",
+		Subject:  "New Reservation",
+		Content:  htmlOwnerMessage,
+		Template: "basic.gohtml",
+	}
+
+	repo.App.MailChan <- ownerMessage
+
+	// we put the value we receive from form into our session as last version of the reservation,
+	//so we can display it when we redirect to reservation summary route
+	repo.App.Session.Put(r.Context(), "reservation", reservation)
+
+	http.Redirect(w, r, "/reservation-summary", http.StatusSeeOther)
+
+}
+
+// sendReservationConfirmation emails the reservation's details to the guest
+func (repo *Repository) sendReservationConfirmation(reservation models.Reservation) {
 	htmlGuestMessage := fmt.Sprintf(`
 		<strong>Reservation Confirmation</strong>
     	<br>
@@ -393,33 +450,51 @@ func (repo *Repository) PostMakeReservation(w http.ResponseWriter, r *http.Reque
 	}
 
 	repo.App.MailChan <- guestMSG
+}
 
-	htmlOwnerMessage := fmt.Sprintf(`
-		<strong>Reservation Confirmation</strong>
-    	<br>
-    	Dear Owner, 
+// sendReservationChange emails the guest the new dates and room of the reservation
+func (repo *Repository) sendReservationChange(old, reservation models.Reservation) {
+	htmlGuestMessage := fmt.Sprintf(`
+		<strong>Reservation Changed</strong>
 		<br>
-    	This is confirmation for reservation of your %s from %s to %s.
-		You can reach the guest via this email : %s.
-	`, reservation.Room.RoomName, reservation.StartDate.Format("2006-01-02"),
-		reservation.EndDate.Format("2006-01-02"), reservation.Email)
+		Dear %s,
+		<br>
+		Your reservation from %s to %s in %s has been changed to %s to %s in %s
+	`, reservation.FirstName+" "+reservation.LastName, old.StartDate.Format("2006-01-02"),
+		old.EndDate.Format("2006-01-02"), old.Room.RoomName, reservation.StartDate.Format("2006-01-02"),
+		reservation.EndDate.Format("2006-01-02"), reservation.Room.RoomName)
 
-	ownerMessage := models.MailData{
-		To:       "owner@here.com",
+	guestMSG := models.MailData{
+		To:       reservation.Email,
 		From:     "me@here.com",
-		Subject:  "New Reservation",
-		Content:  htmlOwnerMessage,
+		Subject:  "Reservation Changed",
+		Content:  htmlGuestMessage,
 		Template: "basic.gohtml",
 	}
 
-	repo.App.MailChan <- ownerMessage
+	repo.App.MailChan <- guestMSG
+}
 
-	// we put the value we receive from form into our session as last version of the reservation,
-	//so we can display it when we redirect to reservation summary route
-	repo.App.Session.Put(r.Context(), "reservation", reservation)
+// sendReservationCancellation emails the guest that the reservation is cancelled with the reason
+func (repo *Repository) sendReservationCancellation(reservation models.Reservation, reason string) {
+	htmlGuestMessage := fmt.Sprintf(`
+		<strong>Reservation Cancelled</strong>
+		<br>
+		Dear %s,
+		<br>
+		Your reservation from %s to %s in %s has been cancelled: %s
+	`, reservation.FirstName+" "+reservation.LastName, reservation.StartDate.Format("2006-01-02"),
+		reservation.EndDate.Format("2006-01-02"), reservation.Room.RoomName, html.EscapeString(reason))
 
-	http.Redirect(w, r, "/reservation-summary", http.StatusSeeOther)
+	guestMSG := models.MailData{
+		To:       reservation.Email,
+		From:     "me@here.com",
+		Subject:  "Reservation Cancelled",
+		Content:  htmlGuestMessage,
+		Template: "basic.gohtml",
+	}
 
+	repo.App.MailChan <- guestMSG
 }
 
 // ReservationSummary renders summary of reservation according to user's inputs
@@ -570,334 +645,4066 @@ func (repo *Repository) PostShowLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	id, _, err := repo.DB.Authenticate(email, password)
+	ip := repo.clientIP(r)
+	account, byIP, err := repo.DB.LoginFailuresSince(email, ip, time.Now().Add(-loginLockout))
 
 	if err != nil {
-		repo.App.Session.Put(r.Context(), "error", "Invalid login credentials")
-		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		helpers.ServerError(w, err)
 		return
 	}
 
-	repo.App.Session.Put(r.Context(), "user_id", id)
-
-	repo.App.Session.Put(r.Context(), "flash", "Logged in successfully")
-	http.Redirect(w, r, "/", http.StatusSeeOther)
-}
+	// the password isn't checked while the account or the IP address must wait, so guessing gets slower with every
+	// failure, even across app instances
+	if wait := time.Until(nextLoginAt(account, byIP)); wait > 0 {
+		repo.App.Session.Put(r.Context(), "error", fmt.Sprintf("Too many failed logins, try again in %s", waitText(wait)))
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
 
-// Logout logs a user out
-func (repo *Repository) Logout(w http.ResponseWriter, r *http.Request) {
-	_ = repo.App.Session.Destroy(r.Context())
-	_ = repo.App.Session.RenewToken(r.Context())
+	id, hashedPassword, err := repo.DB.Authenticate(email, password)
 
-	repo.App.Session.Put(r.Context(), "warning", "Succesfully logged out!")
+	if err != nil {
+		err = repo.loginFailed(email, ip, account)
 
-	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
-}
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
 
-// AdminDashboard renders the admin-dashboard template
-func (repo *Repository) AdminDashboard(w http.ResponseWriter, r *http.Request) {
-	utils.Template(w, r, "admin-dashboard.page.gohtml", &models.TemplateData{})
-}
+		repo.App.Session.Put(r.Context(), "error", "Invalid login credentials")
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
 
-// AdminNewReservations shows all new reservations in admin dashboard
-func (repo *Repository) AdminNewReservations(w http.ResponseWriter, r *http.Request) {
-	reservations, err := repo.DB.AllNewReservations()
+	user, err := repo.DB.GetUserById(id)
 
 	if err != nil {
 		helpers.ServerError(w, err)
 		return
 	}
 
-	data := make(map[string]interface{})
-	data["reservations"] = reservations
-
-	utils.Template(w, r, "admin-new-reservations.page.gohtml", &models.TemplateData{
-		Data: data,
-	})
-}
+	// users with two-factor authentication get user_id only after they enter a code too, their failed logins are
+	// cleared after the code, so the code can't be guessed without the throttle
+	if user.TwoFactorEnabled() {
+		repo.App.Session.Put(r.Context(), "two_factor_user_id", id)
+		repo.App.Session.Put(r.Context(), "two_factor_fingerprint", helpers.PasswordFingerprint(hashedPassword))
+		repo.App.Session.Put(r.Context(), "two_factor_started", time.Now().Unix())
+		http.Redirect(w, r, "/user/login/two-factor", http.StatusSeeOther)
+		return
+	}
 
-// AdminNewReservations shows all reservations in admin dashboard
-func (repo *Repository) AdminAllReservations(w http.ResponseWriter, r *http.Request) {
-	reservations, err := repo.DB.AllReservations()
+	err = repo.DB.ClearLoginFailures(email, "")
 
 	if err != nil {
 		helpers.ServerError(w, err)
 		return
 	}
 
-	data := make(map[string]interface{})
-	data["reservations"] = reservations
+	repo.App.Session.Put(r.Context(), "user_id", id)
+	repo.App.Session.Put(r.Context(), "password_fingerprint", helpers.PasswordFingerprint(hashedPassword))
+	repo.auditLogin(r, user)
 
-	utils.Template(w, r, "admin-all-reservations.page.gohtml", &models.TemplateData{
-		Data: data,
-	})
+	repo.App.Session.Put(r.Context(), "flash", "Logged in successfully")
+	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
-// AdminShowReservationDetail shows the reservation's details in dashboard
-func (repo *Repository) AdminShowReservationDetail(w http.ResponseWriter, r *http.Request) {
-
-	// last one in this slice is my id
-	id, err := strconv.Atoi(chi.URLParam(r, "id"))
-
-	if err != nil {
-		helpers.ServerError(w, err)
-		return
-	}
+// loginLimits are how many failed logins start slowing down logins and lock them out
+type loginLimits struct {
+	delayAfter int
+	lockAfter  int
+}
 
-	// before the last one my source
-	src := chi.URLParam(r, "src")
+// accounts are limited sooner than IP addresses, since many users can share an address
+var (
+	accountLoginLimits = loginLimits{delayAfter: 3, lockAfter: 10}
+	ipLoginLimits      = loginLimits{delayAfter: 20, lockAfter: 100}
+)
 
-	stringMap := make(map[string]string)
-	stringMap["src"] = src
+// failed logins are counted for loginLockout, which is also how long a lockout lasts after the last failure
+const (
+	loginLockout  = 15 * time.Minute
+	maxLoginDelay = time.Minute
+)
 
-	year := r.URL.Query().Get("y")
-	month := r.URL.Query().Get("m")
+// CleanUpLoginFailures removes the failed logins that are too old to be counted, and returns how many were removed
+func (repo *Repository) CleanUpLoginFailures() (int64, error) {
+	return repo.DB.DeleteLoginFailuresBefore(time.Now().Add(-loginLockout))
+}
 
-	stringMap["month"] = month
-	stringMap["year"] = year
+// nextAt returns when a login can be tried after the failures, the delay doubles with every failure after delayAfter
+func (l loginLimits) nextAt(f models.LoginFailures) time.Time {
+	switch {
+	case f.Count >= l.lockAfter:
+		return f.Last.Add(loginLockout)
+	case f.Count >= l.delayAfter:
+		delay := time.Second << (f.Count - l.delayAfter)
 
-	res, err := repo.DB.GetReservationById(id)
+		if delay > maxLoginDelay {
+			delay = maxLoginDelay
+		}
 
-	if err != nil {
-		helpers.ServerError(w, err)
-		return
+		return f.Last.Add(delay)
 	}
 
-	data := make(map[string]interface{})
-	data["reservation"] = res
-
-	utils.Template(w, r, "admin-reservation-detail.page.gohtml", &models.TemplateData{
-		StringMap: stringMap,
-		Data:      data,
-		Form:      forms.New(nil),
-	})
+	return time.Time{}
 }
 
-// AdminPostShowReservationDetail updates the reservation according to form
-func (repo *Repository) AdminPostShowReservationDetail(w http.ResponseWriter, r *http.Request) {
-	err := r.ParseForm()
+// nextLoginAt returns when a login can be tried for the account from the IP address
+func nextLoginAt(account, byIP models.LoginFailures) time.Time {
+	next := accountLoginLimits.nextAt(account)
 
-	if err != nil {
-		helpers.ServerError(w, err)
-		return
+	if ipNext := ipLoginLimits.nextAt(byIP); ipNext.After(next) {
+		return ipNext
 	}
 
-	exploded := strings.Split(r.RequestURI, "/")
-
-	id, err := strconv.Atoi(exploded[4])
+	return next
+}
 
-	if err != nil {
-		helpers.ServerError(w, err)
-		return
+// waitText returns the wait in seconds or minutes, rounded up
+func waitText(wait time.Duration) string {
+	if wait <= time.Minute {
+		return fmt.Sprintf("%d seconds", int((wait+time.Second-1)/time.Second))
 	}
 
-	src := exploded[3]
-
-	stringMap := make(map[string]string)
-	stringMap["src"] = src
+	return fmt.Sprintf("%d minutes", int((wait+time.Minute-1)/time.Minute))
+}
 
-	res, err := repo.DB.GetReservationById(id)
+// loginFailed records a failed login, and emails the account's user when the account is locked out
+func (repo *Repository) loginFailed(email, ip string, account models.LoginFailures) error {
+	err := repo.DB.InsertLoginFailure(email, ip)
 
 	if err != nil {
-		helpers.ServerError(w, err)
-		return
+		return err
 	}
 
-	res.FirstName = r.Form.Get("first_name")
-	res.LastName = r.Form.Get("last_name")
-	res.Email = r.Form.Get("email")
-	res.Phone = r.Form.Get("phone")
+	if account.Count+1 != accountLoginLimits.lockAfter {
+		return nil
+	}
 
-	err = repo.DB.UpdateReservation(res)
+	user, err := repo.DB.GetUserByEmail(email)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
 
 	if err != nil {
-		helpers.ServerError(w, err)
-		return
+		return err
 	}
 
-	month := r.Form.Get("month")
-	year := r.Form.Get("year")
+	htmlMessage := fmt.Sprintf(`
+		<strong>Failed Logins</strong>
+		<br>
+		Dear %s,
+		<br>
+		There were %d failed logins to your account, the last one from %s. Logins to your account are locked for %d minutes.
+		<br>
+		If it wasn't you, someone may be guessing your password. You can set a new one with the link below.
+		<br>
+		<a href="%s/user/forgot-password">%s/user/forgot-password</a>
+	`, user.FirstName+" "+user.LastName, accountLoginLimits.lockAfter, ip, int(loginLockout/time.Minute), repo.siteURL(), repo.siteURL())
 
-	if src != "cal" {
-		http.Redirect(w, r, fmt.Sprintf("/admin/reservations-%s", src), http.StatusSeeOther)
-	} else {
-		http.Redirect(w, r, fmt.Sprintf("/admin/reservations-calendar?y=%s&m=%s", year, month), http.StatusSeeOther)
+	repo.App.MailChan <- models.MailData{
+		To:       user.Email,
+		From:     "me@here.com",
+		Subject:  "Failed Logins",
+		Content:  htmlMessage,
+		Template: "basic.gohtml",
 	}
 
-	repo.App.Session.Put(r.Context(), "flash", "Successfully changed the reservation")
-
+	return nil
 }
 
-// AdminReservationsCalendar marks a reservation processed
-func (repo *Repository) AdminProcessedReservation(w http.ResponseWriter, r *http.Request) {
-	exploded := strings.Split(r.RequestURI, "/")
-	id, err := strconv.Atoi(exploded[4])
+// how long users have to enter their codes after their passwords, and how many times they can try
+const (
+	twoFactorLoginTTL    = 5 * time.Minute
+	maxTwoFactorAttempts = 5
+)
 
-	if err != nil {
-		helpers.ServerError(w, err)
+// ShowLoginTwoFactor shows the form to enter a code of the authenticator app or a recovery code after the password
+func (repo *Repository) ShowLoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	if repo.App.Session.GetInt(r.Context(), "two_factor_user_id") == 0 {
+		repo.App.Session.Put(r.Context(), "error", "Log in first!")
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
 		return
 	}
 
-	src := exploded[3]
-
-	processedVal := 1
+	utils.Template(w, r, "login-two-factor.page.gohtml", &models.TemplateData{
+		Form: forms.New(nil),
+	})
+}
 
-	err = repo.DB.UpdateProcessedForReservation(id, processedVal)
+// PostLoginTwoFactor logs the user in if the code of the authenticator app or an unused recovery code is entered
+func (repo *Repository) PostLoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
 
 	if err != nil {
 		helpers.ServerError(w, err)
 		return
 	}
 
-	year := r.URL.Query().Get("y")
-	month := r.URL.Query().Get("m")
-
-	if year == "" {
-		http.Redirect(w, r, fmt.Sprintf("/admin/reservations-%s", src), http.StatusSeeOther)
-	} else {
-		http.Redirect(w, r, fmt.Sprintf("/admin/reservations-calendar?y=%s&m=%s", year, month), http.StatusSeeOther)
-	}
+	ctx := r.Context()
+	id := repo.App.Session.GetInt(ctx, "two_factor_user_id")
+	started := time.Unix(repo.App.Session.GetInt64(ctx, "two_factor_started"), 0)
 
-	repo.App.Session.Put(r.Context(), "flash", "Reservation marked as processed")
-}
+	var user models.User
 
-// AdminDeleteReservation deletes a reservation from DB
-func (repo *Repository) AdminDeleteReservation(w http.ResponseWriter, r *http.Request) {
-	exploded := strings.Split(r.RequestURI, "/")
-	id, err := strconv.Atoi(exploded[4])
+	if id > 0 && time.Since(started) < twoFactorLoginTTL {
+		user, err = repo.DB.GetUserById(id)
+	}
 
-	if err != nil {
-		helpers.ServerError(w, err)
+	if id == 0 || time.Since(started) >= twoFactorLoginTTL || err != nil || user.Active == 0 || !user.TwoFactorEnabled() {
+		repo.clearTwoFactorLogin(r)
+		repo.App.Session.Put(ctx, "error", "Your login has expired, log in again")
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
 		return
 	}
 
-	src := exploded[3]
-
-	err = repo.DB.DeleteReservation(id)
+	// wrong codes count as failed logins of the account, like wrong passwords
+	ip := repo.clientIP(r)
+	account, byIP, err := repo.DB.LoginFailuresSince(user.Email, ip, time.Now().Add(-loginLockout))
 
 	if err != nil {
 		helpers.ServerError(w, err)
+		return
 	}
 
-	year := r.URL.Query().Get("y")
-	month := r.URL.Query().Get("m")
-
-	if year == "" {
-		http.Redirect(w, r, fmt.Sprintf("/admin/reservations-%s", src), http.StatusSeeOther)
-	} else {
-		http.Redirect(w, r, fmt.Sprintf("/admin/reservations-calendar?y=%s&m=%s", year, month), http.StatusSeeOther)
+	if wait := time.Until(nextLoginAt(account, byIP)); wait > 0 {
+		repo.clearTwoFactorLogin(r)
+		repo.App.Session.Put(ctx, "error", fmt.Sprintf("Too many failed logins, try again in %s", waitText(wait)))
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
 	}
 
-	repo.App.Session.Put(r.Context(), "warning", "Reservation deleted successfully")
-}
-
-// AdminReservationsCalendar displays the reservation calendar
-func (repo *Repository) AdminReservationsCalendar(w http.ResponseWriter, r *http.Request) {
-	now := time.Now()
+	code := normalizeCode(r.Form.Get("code"))
+	valid := false
+	recovery := false
 
-	if r.URL.Query().Get("y") != "" {
-		year, err := strconv.Atoi(r.URL.Query().Get("y"))
+	if len(code) == 6 {
+		valid = totp.Validate(code, user.TOTPSecret)
+	} else if code != "" {
+		err = repo.DB.UseRecoveryCode(id, code)
 
-		if err != nil {
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
 			helpers.ServerError(w, err)
 			return
 		}
 
-		month, err := strconv.Atoi(r.URL.Query().Get("m"))
+		valid = err == nil
+		recovery = valid
+	}
+
+	if !valid {
+		err = repo.loginFailed(user.Email, ip, account)
 
 		if err != nil {
 			helpers.ServerError(w, err)
 			return
 		}
 
-		now = time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
-	}
+		attempts := repo.App.Session.GetInt(ctx, "two_factor_attempts") + 1
 
-	data := make(map[string]interface{})
+		if attempts >= maxTwoFactorAttempts {
+			repo.clearTwoFactorLogin(r)
+			repo.App.Session.Put(ctx, "error", "Too many invalid codes, log in again")
+			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+			return
+		}
+
+		repo.App.Session.Put(ctx, "two_factor_attempts", attempts)
+
+		form := forms.New(r.PostForm)
+		form.Errors.Add("code", "Invalid code")
+
+		utils.Template(w, r, "login-two-factor.page.gohtml", &models.TemplateData{
+			Form: form,
+		})
+		return
+	}
+
+	err = repo.DB.ClearLoginFailures(user.Email, "")
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	fingerprint := repo.App.Session.GetString(ctx, "two_factor_fingerprint")
+	repo.clearTwoFactorLogin(r)
+
+	// the user logs in with a new session token, like after the password
+	_ = repo.App.Session.RenewToken(ctx)
+
+	repo.App.Session.Put(ctx, "user_id", id)
+	repo.App.Session.Put(ctx, "password_fingerprint", fingerprint)
+	repo.auditLogin(r, user)
+
+	if recovery {
+		left, err := repo.DB.CountRecoveryCodes(id)
+
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+
+		repo.App.Session.Put(ctx, "warning", fmt.Sprintf("You used a recovery code, %d codes are left", left))
+	}
+
+	repo.App.Session.Put(ctx, "flash", "Logged in successfully")
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// auditLogin records the user's login in the audit log, which users see as their login activity
+func (repo *Repository) auditLogin(r *http.Request, user models.User) {
+	repo.audit(r.WithContext(helpers.WithUser(r.Context(), user)), models.AuditLogin, models.EntityUser, user.ID, nil, nil)
+}
+
+// clearTwoFactorLogin removes the login that waits for a code from the session
+func (repo *Repository) clearTwoFactorLogin(r *http.Request) {
+	for _, key := range []string{"two_factor_user_id", "two_factor_fingerprint", "two_factor_started", "two_factor_attempts"} {
+		repo.App.Session.Remove(r.Context(), key)
+	}
+}
+
+// normalizeCode removes the spaces and dashes users type in codes, recovery codes are compared in lower case
+func normalizeCode(code string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(code))
+}
+
+// Logout logs a user out
+func (repo *Repository) Logout(w http.ResponseWriter, r *http.Request) {
+	_ = repo.App.Session.Destroy(r.Context())
+	_ = repo.App.Session.RenewToken(r.Context())
+
+	repo.App.Session.Put(r.Context(), "warning", "Succesfully logged out!")
+
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
+
+// dashboardWeeks is how many weeks of bookings the dashboard chart shows
+const dashboardWeeks = 12
+
+// AdminDashboard shows today's arrivals, departures and guests, the occupancy of rooms for this and next month, and
+// the bookings created in last weeks
+func (repo *Repository) AdminDashboard(w http.ResponseWriter, r *http.Request) {
+	today := today()
+	thisMonth := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC)
+	nextMonth := thisMonth.AddDate(0, 1, 0)
+
+	stats, err := repo.DB.DashboardStats(today)
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	occupancyThisMonth, err := repo.DB.OccupancyByRoom(thisMonth, nextMonth)
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	occupancyNextMonth, err := repo.DB.OccupancyByRoom(nextMonth, nextMonth.AddDate(0, 1, 0))
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	// weeks start on Monday as date_trunc of postgres does
+	firstWeek := today.AddDate(0, 0, -(int(today.Weekday())+6)%7-7*(dashboardWeeks-1))
+
+	bookings, err := repo.DB.BookingsPerWeek(firstWeek)
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	weeks := fillWeeks(bookings, firstWeek, dashboardWeeks)
+
+	var weekLabels []string
+	var weekCounts []int
+
+	for _, week := range weeks {
+		weekLabels = append(weekLabels, week.WeekStart.Format("Jan 2"))
+		weekCounts = append(weekCounts, week.Count)
+	}
+
+	data := make(map[string]interface{})
+	data["stats"] = stats
+	data["occupancy_this_month"] = occupancyThisMonth
+	data["occupancy_next_month"] = occupancyNextMonth
+	data["week_labels"] = weekLabels
+	data["week_counts"] = weekCounts
+
+	stringMap := make(map[string]string)
+	stringMap["this_month"] = thisMonth.Format("January 2006")
+	stringMap["next_month"] = nextMonth.Format("January 2006")
+
+	utils.Template(w, r, "admin-dashboard.page.gohtml", &models.TemplateData{
+		Data:      data,
+		StringMap: stringMap,
+	})
+}
+
+// today returns the current day at midnight, in the same location as the dates of reservations
+func today() time.Time {
+	now := time.Now()
+
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// fillWeeks returns a count for each of the weeks starting with firstWeek, weeks without a count are zero
+func fillWeeks(counts []models.WeeklyCount, firstWeek time.Time, weeks int) []models.WeeklyCount {
+	byWeek := make(map[string]int)
+	for _, c := range counts {
+		byWeek[c.WeekStart.Format("2006-01-02")] = c.Count
+	}
+
+	filled := make([]models.WeeklyCount, weeks)
+	for i := range filled {
+		week := firstWeek.AddDate(0, 0, 7*i)
+		filled[i] = models.WeeklyCount{
+			WeekStart: week,
+			Count:     byWeek[week.Format("2006-01-02")],
+		}
+	}
+
+	return filled
+}
+
+// AdminNewReservations shows a page of the new reservations in admin dashboard
+func (repo *Repository) AdminNewReservations(w http.ResponseWriter, r *http.Request) {
+	repo.reservationList(w, r, "admin-new-reservations.page.gohtml", "new")
+}
+
+// AdminAllReservations shows a page of all reservations in admin dashboard
+func (repo *Repository) AdminAllReservations(w http.ResponseWriter, r *http.Request) {
+	repo.reservationList(w, r, "admin-all-reservations.page.gohtml", "all")
+}
+
+// reservationList shows a page of the reservations that match the filters of the URL, src is the list's name in the
+// reservation links, and the new list only shows new reservations
+func (repo *Repository) reservationList(w http.ResponseWriter, r *http.Request, tmpl, src string) {
+	filter, err := reservationFilterFromQuery(r.URL.Query())
+
+	if err != nil {
+		repo.App.Session.Put(r.Context(), "error", "Invalid filters")
+		http.Redirect(w, r, r.URL.Path, http.StatusSeeOther)
+		return
+	}
+
+	if src == "new" {
+		filter.Status = models.StatusNew
+	}
+
+	// latest arrivals are shown first unless the admin sorts the list
+	if filter.Sort == "" {
+		filter.Sort = "start_date"
+		filter.Direction = models.SortDesc
+	}
+
+	reservations, total, err := repo.DB.FilterReservations(filter)
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	rooms, err := repo.DB.AllRooms()
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	pages := (total + filter.PerPage - 1) / filter.PerPage
+
+	data := make(map[string]interface{})
+	data["src"] = src
+	data["reservations"] = reservations
+	data["rooms"] = rooms
+	data["columns"] = export.ReservationColumns
+	data["filter"] = filter
+	data["pages"] = pageLinks(r.URL, filter.Page, pages)
+	data["sort_urls"] = sortURLs(r.URL, filter)
+
+	if filter.Page > 1 {
+		data["prev_url"] = listURL(r.URL, map[string]string{"page": strconv.Itoa(filter.Page - 1)})
+	}
+
+	if filter.Page < pages {
+		data["next_url"] = listURL(r.URL, map[string]string{"page": strconv.Itoa(filter.Page + 1)})
+	}
+
+	intMap := make(map[string]int)
+	intMap["total"] = total
+
+	utils.Template(w, r, tmpl, &models.TemplateData{
+		Data:   data,
+		IntMap: intMap,
+	})
+}
+
+// pageLink is a link of the pagination controls, links with zero Number are gaps between pages
+type pageLink struct {
+	Number int
+	URL    string
+	Active bool
+}
+
+// pageLinks returns the links of the first, last and the pages around the current page
+func pageLinks(u *url.URL, current, pages int) []pageLink {
+	var links []pageLink
+
+	for i := 1; i <= pages; i++ {
+		if i != 1 && i != pages && (i < current-2 || i > current+2) {
+			if links[len(links)-1].Number != 0 {
+				links = append(links, pageLink{})
+			}
+			continue
+		}
+
+		links = append(links, pageLink{
+			Number: i,
+			URL:    listURL(u, map[string]string{"page": strconv.Itoa(i)}),
+			Active: i == current,
+		})
+	}
+
+	return links
+}
+
+// sortURLs returns the links of the list's column headers, the sorted column's link reverses its direction
+func sortURLs(u *url.URL, filter models.ReservationFilter) map[string]string {
+	urls := make(map[string]string)
+
+	for _, column := range models.ReservationSortColumns {
+		direction := models.SortAsc
+		if column == filter.Sort && filter.Direction != models.SortDesc {
+			direction = models.SortDesc
+		}
+
+		urls[column] = listURL(u, map[string]string{"sort": column, "dir": direction, "page": ""})
+	}
+
+	return urls
+}
+
+// listURL returns the URL with the changed query values, so the filters are kept between pages, empty values are
+// removed
+func listURL(u *url.URL, changes map[string]string) string {
+	q := u.Query()
+
+	for k, v := range changes {
+		if v == "" {
+			q.Del(k)
+		} else {
+			q.Set(k, v)
+		}
+	}
+
+	if len(q) == 0 {
+		return u.Path
+	}
+
+	return u.Path + "?" + q.Encode()
+}
+
+// AdminNewReservation shows the form to create a reservation for phone and walk-in guests
+func (repo *Repository) AdminNewReservation(w http.ResponseWriter, r *http.Request) {
+	rooms, err := repo.DB.AllRooms()
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["reservation"] = models.Reservation{}
+	data["rooms"] = rooms
+
+	stringMap := make(map[string]string)
+	stringMap["send_email"] = "1"
+
+	utils.Template(w, r, "admin-new-reservation.page.gohtml", &models.TemplateData{
+		Data:      data,
+		StringMap: stringMap,
+		Form:      forms.New(nil),
+	})
+}
+
+// AdminPostNewReservation creates a reservation with its room restriction after the same availability check of the
+// public booking flow, the guest is only emailed if the admin wants to
+func (repo *Repository) AdminPostNewReservation(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	reservation := models.Reservation{
+		FirstName: r.Form.Get("first_name"),
+		LastName:  r.Form.Get("last_name"),
+		Email:     r.Form.Get("email"),
+		Phone:     r.Form.Get("phone"),
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("first_name", "last_name", "room_id", "start_date", "end_date")
+
+	sendEmail := r.Form.Get("send_email") != ""
+
+	// guests booking by phone or walking in might not have an email, but we can't confirm without it
+	if sendEmail {
+		form.Required("email")
+	}
+
+	if form.Has("email") {
+		form.IsEmail("email")
+	}
+
+	layout := "2006-01-02"
+
+	if form.Has("start_date") {
+		reservation.StartDate, err = time.Parse(layout, r.Form.Get("start_date"))
+		if err != nil {
+			form.Errors.Add("start_date", "Invalid date")
+		}
+	}
+
+	if form.Has("end_date") {
+		reservation.EndDate, err = time.Parse(layout, r.Form.Get("end_date"))
+		if err != nil {
+			form.Errors.Add("end_date", "Invalid date")
+		}
+	}
+
+	if !reservation.StartDate.IsZero() && !reservation.EndDate.IsZero() && !reservation.EndDate.After(reservation.StartDate) {
+		form.Errors.Add("end_date", "Departure must be after arrival")
+	}
+
+	if form.Has("room_id") {
+		reservation.RoomID, err = strconv.Atoi(r.Form.Get("room_id"))
+
+		if err == nil {
+			reservation.Room, err = repo.DB.GetRoomById(reservation.RoomID)
+		}
+
+		if err != nil {
+			form.Errors.Add("room_id", "Unknown room")
+		}
+	}
+
+	if form.Valid() {
+		available, err := repo.DB.SearchAvailabilityByDatesByRoomID(reservation.StartDate, reservation.EndDate, reservation.RoomID)
+
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+
+		if !available {
+			form.Errors.Add("start_date", "The room is not available for these dates")
+		}
+	}
+
+	if !form.Valid() {
+		rooms, err := repo.DB.AllRooms()
+
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+
+		data := make(map[string]interface{})
+		data["reservation"] = reservation
+		data["rooms"] = rooms
+
+		stringMap := make(map[string]string)
+		stringMap["start_date"] = r.Form.Get("start_date")
+		stringMap["end_date"] = r.Form.Get("end_date")
+		stringMap["send_email"] = r.Form.Get("send_email")
+
+		utils.Template(w, r, "admin-new-reservation.page.gohtml", &models.TemplateData{
+			Data:      data,
+			StringMap: stringMap,
+			Form:      form,
+		})
+		return
+	}
+
+	reservation.ID, err = repo.DB.InsertReservationWithRestriction(reservation)
+
+	if err != nil {
+		repo.App.ErrorLog.Println("cannot create reservation:", err)
+		repo.App.Session.Put(r.Context(), "error", "Can't create the reservation, the room might be booked meanwhile")
+		http.Redirect(w, r, "/admin/reservations-create", http.StatusSeeOther)
+		return
+	}
+
+	repo.audit(r, models.AuditCreate, models.EntityReservation, reservation.ID, nil, reservation)
+	repo.sendEvent(models.EventReservationCreated, reservation)
+
+	if sendEmail {
+		repo.sendReservationConfirmation(reservation)
+	}
+
+	repo.App.Session.Put(r.Context(), "flash", "Reservation created")
+	http.Redirect(w, r, fmt.Sprintf("/admin/reservations/all/%d/show", reservation.ID), http.StatusSeeOther)
+}
+
+// AdminExportReservations streams the reservations that match the filters as a CSV file
+func (repo *Repository) AdminExportReservations(w http.ResponseWriter, r *http.Request) {
+	filter, err := reservationFilterFromQuery(r.URL.Query())
+
+	if err != nil {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	repo.writeReservationsCSV(w, filter, export.SelectColumns(r.URL.Query()["columns"]))
+}
+
+// writeReservationsCSV streams the reservations that match the filter as a CSV file with the columns
+func (repo *Repository) writeReservationsCSV(w http.ResponseWriter, filter models.ReservationFilter, columns []export.Column) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=reservations-%s.csv", time.Now().Format("2006-01-02")))
+
+	cw, err := export.NewCSVWriter(w, columns)
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	// after the first row is sent we can't change the status anymore, so errors are only logged
+	err = repo.DB.EachReservation(filter, cw.Write)
+
+	if err == nil {
+		err = cw.Flush()
+	}
+
+	if err != nil {
+		repo.App.ErrorLog.Println("cannot export reservations:", err)
+	}
+}
+
+// reservationsPerPage is the default page size of the admin reservation lists
+const reservationsPerPage = 25
+
+// maxReservationsPerPage is the biggest page size admins can choose
+const maxReservationsPerPage = 100
+
+// reservationFilterFromQuery reads the reservation filters, sorting and page from the query string, empty values are
+// not filtered
+func reservationFilterFromQuery(q url.Values) (models.ReservationFilter, error) {
+	filter := models.ReservationFilter{
+		Search:  strings.TrimSpace(q.Get("q")),
+		Page:    1,
+		PerPage: reservationsPerPage,
+	}
+
+	var err error
+
+	layout := "2006-01-02"
+
+	if start := q.Get("start"); start != "" {
+		filter.StartDate, err = time.Parse(layout, start)
+		if err != nil {
+			return filter, err
+		}
+	}
+
+	if end := q.Get("end"); end != "" {
+		filter.EndDate, err = time.Parse(layout, end)
+		if err != nil {
+			return filter, err
+		}
+	}
+
+	if roomID := q.Get("room_id"); roomID != "" {
+		filter.RoomID, err = strconv.Atoi(roomID)
+		if err != nil {
+			return filter, err
+		}
+	}
+
+	switch status := q.Get("status"); status {
+	case "", models.StatusNew, models.StatusProcessed:
+		filter.Status = status
+	default:
+		return filter, fmt.Errorf("unknown reservation status %s", status)
+	}
+
+	if sort := q.Get("sort"); sort != "" {
+		valid := false
+		for _, column := range models.ReservationSortColumns {
+			if sort == column {
+				valid = true
+			}
+		}
+
+		if !valid {
+			return filter, fmt.Errorf("reservations can't be sorted by %s", sort)
+		}
+
+		filter.Sort = sort
+	}
+
+	switch direction := q.Get("dir"); direction {
+	case "", models.SortAsc, models.SortDesc:
+		filter.Direction = direction
+	default:
+		return filter, fmt.Errorf("unknown sort direction %s", direction)
+	}
+
+	if page := q.Get("page"); page != "" {
+		filter.Page, err = strconv.Atoi(page)
+		if err != nil {
+			return filter, err
+		}
+
+		if filter.Page < 1 {
+			filter.Page = 1
+		}
+	}
+
+	if perPage := q.Get("per_page"); perPage != "" {
+		filter.PerPage, err = strconv.Atoi(perPage)
+		if err != nil {
+			return filter, err
+		}
+
+		if filter.PerPage < 1 || filter.PerPage > maxReservationsPerPage {
+			filter.PerPage = reservationsPerPage
+		}
+	}
+
+	return filter, nil
+}
+
+// AdminImportReservations shows the form to import reservations and blocks from a CSV file
+func (repo *Repository) AdminImportReservations(w http.ResponseWriter, r *http.Request) {
+	data := make(map[string]interface{})
+	data["columns"] = csvimport.Columns
+
+	utils.Template(w, r, "admin-import-reservations.page.gohtml", &models.TemplateData{
+		Data: data,
+	})
+}
+
+// AdminPostImportReservations validates an import file and shows its rows, the rows are only saved when the admin
+// imports a preview without errors
+func (repo *Repository) AdminPostImportReservations(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseMultipartForm(maxUploadSize)
+
+	if err != nil && err != http.ErrNotMultipart {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	// the preview sends the file's content back, so the admin doesn't need to upload it again
+	content := r.Form.Get("content")
+
+	if content == "" {
+		file, _, err := r.FormFile("file")
+
+		if err != nil {
+			repo.App.Session.Put(r.Context(), "error", "Choose a CSV file to import")
+			http.Redirect(w, r, "/admin/reservations-import", http.StatusSeeOther)
+			return
+		}
+
+		defer file.Close()
+
+		b, err := io.ReadAll(file)
+
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+
+		content = string(b)
+	}
+
+	importer := csvimport.NewImporter(repo.DB)
+
+	rows, err := importer.Parse(strings.NewReader(content))
+
+	if err != nil {
+		repo.App.Session.Put(r.Context(), "error", fmt.Sprintf("Cannot read the import file: %s", err))
+		http.Redirect(w, r, "/admin/reservations-import", http.StatusSeeOther)
+		return
+	}
+
+	if len(rows) == 0 {
+		repo.App.Session.Put(r.Context(), "error", "Import file has no rows")
+		http.Redirect(w, r, "/admin/reservations-import", http.StatusSeeOther)
+		return
+	}
+
+	if r.Form.Get("action") != "import" || !csvimport.Valid(rows) {
+		data := make(map[string]interface{})
+		data["columns"] = csvimport.Columns
+		data["rows"] = rows
+		data["valid"] = csvimport.Valid(rows)
+		data["content"] = content
+
+		utils.Template(w, r, "admin-import-reservations.page.gohtml", &models.TemplateData{
+			Data: data,
+		})
+		return
+	}
+
+	err = importer.Commit(rows)
+
+	if err != nil {
+		repo.App.ErrorLog.Println("cannot import reservations:", err)
+		repo.App.Session.Put(r.Context(), "error", fmt.Sprintf("Nothing is imported: %s", err))
+		http.Redirect(w, r, "/admin/reservations-import", http.StatusSeeOther)
+		return
+	}
+
+	repo.audit(r, models.AuditImport, models.EntityReservation, 0, nil, rows)
+
+	repo.App.Session.Put(r.Context(), "flash", fmt.Sprintf("%d rows imported", len(rows)))
+	http.Redirect(w, r, "/admin/reservations-all", http.StatusSeeOther)
+}
+
+// AdminShowReservationDetail shows the reservation's details in dashboard
+func (repo *Repository) AdminShowReservationDetail(w http.ResponseWriter, r *http.Request) {
+
+	// last one in this slice is my id
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	// before the last one my source
+	src := chi.URLParam(r, "src")
+
+	stringMap := make(map[string]string)
+	stringMap["src"] = src
+
+	year := r.URL.Query().Get("y")
+	month := r.URL.Query().Get("m")
+
+	stringMap["month"] = month
+	stringMap["year"] = year
+
+	res, err := repo.DB.GetReservationById(id)
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	rooms, err := repo.DB.AllRooms()
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	notes, err := repo.DB.NotesForReservation(id)
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	stringMap["start_date"] = res.StartDate.Format("2006-01-02")
+	stringMap["end_date"] = res.EndDate.Format("2006-01-02")
+
+	data := make(map[string]interface{})
+	data["reservation"] = res
+	data["rooms"] = rooms
+	data["notes"] = notes
+
+	utils.Template(w, r, "admin-reservation-detail.page.gohtml", &models.TemplateData{
+		StringMap: stringMap,
+		Data:      data,
+		Form:      forms.New(nil),
+	})
+}
+
+// AdminPostShowReservationDetail updates the reservation according to form
+func (repo *Repository) AdminPostShowReservationDetail(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	exploded := strings.Split(r.RequestURI, "/")
+
+	id, err := strconv.Atoi(exploded[4])
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	src := exploded[3]
+
+	stringMap := make(map[string]string)
+	stringMap["src"] = src
+
+	res, err := repo.DB.GetReservationById(id)
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	old := res
+
+	res.FirstName = r.Form.Get("first_name")
+	res.LastName = r.Form.Get("last_name")
+	res.Email = r.Form.Get("email")
+	res.Phone = r.Form.Get("phone")
+
+	form := forms.New(r.PostForm)
+	layout := "2006-01-02"
+
+	// the dates and the room are kept as they are if they aren't posted
+	if form.Has("start_date") {
+		res.StartDate, err = time.Parse(layout, r.Form.Get("start_date"))
+		if err != nil {
+			form.Errors.Add("start_date", "Invalid date")
+		}
+	}
+
+	if form.Has("end_date") {
+		res.EndDate, err = time.Parse(layout, r.Form.Get("end_date"))
+		if err != nil {
+			form.Errors.Add("end_date", "Invalid date")
+		}
+	}
+
+	if form.Valid() && !res.EndDate.After(res.StartDate) && (form.Has("start_date") || form.Has("end_date")) {
+		form.Errors.Add("end_date", "Departure must be after arrival")
+	}
+
+	if form.Has("room_id") {
+		res.RoomID, err = strconv.Atoi(r.Form.Get("room_id"))
+
+		if err == nil {
+			res.Room, err = repo.DB.GetRoomById(res.RoomID)
+		}
+
+		if err != nil {
+			form.Errors.Add("room_id", "Unknown room")
+		}
+	}
+
+	moved := !res.StartDate.Equal(old.StartDate) || !res.EndDate.Equal(old.EndDate) || res.RoomID != old.RoomID
+
+	if form.Valid() && moved {
+		available, err := repo.DB.SearchAvailabilityExcludingReservation(res.StartDate, res.EndDate, res.RoomID, res.ID)
+
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+
+		if !available {
+			form.Errors.Add("start_date", "The room is not available for these dates")
+		}
+	}
+
+	if !form.Valid() {
+		rooms, err := repo.DB.AllRooms()
+
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+
+		stringMap["start_date"] = r.Form.Get("start_date")
+		stringMap["end_date"] = r.Form.Get("end_date")
+		stringMap["year"] = r.Form.Get("year")
+		stringMap["month"] = r.Form.Get("month")
+		stringMap["notify_guest"] = r.Form.Get("notify_guest")
+
+		notes, err := repo.DB.NotesForReservation(id)
+
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+
+		data := make(map[string]interface{})
+		data["reservation"] = res
+		data["rooms"] = rooms
+		data["notes"] = notes
+
+		utils.Template(w, r, "admin-reservation-detail.page.gohtml", &models.TemplateData{
+			StringMap: stringMap,
+			Data:      data,
+			Form:      form,
+		})
+		return
+	}
+
+	err = repo.DB.UpdateReservation(res)
+
+	if err != nil {
+		repo.App.ErrorLog.Println("cannot update reservation:", err)
+		repo.App.Session.Put(r.Context(), "error", "Can't update the reservation, the room might be booked meanwhile")
+		http.Redirect(w, r, fmt.Sprintf("/admin/reservations/%s/%d/show", src, id), http.StatusSeeOther)
+		return
+	}
+
+	repo.audit(r, models.AuditUpdate, models.EntityReservation, res.ID, old, res)
+
+	if moved && r.Form.Get("notify_guest") != "" && res.Email != "" {
+		repo.sendReservationChange(old, res)
+	}
+
+	repo.sendEvent(models.EventReservationUpdated, res)
+
+	month := r.Form.Get("month")
+	year := r.Form.Get("year")
+
+	if src != "cal" {
+		http.Redirect(w, r, fmt.Sprintf("/admin/reservations-%s", src), http.StatusSeeOther)
+	} else {
+		http.Redirect(w, r, fmt.Sprintf("/admin/reservations-calendar?y=%s&m=%s", year, month), http.StatusSeeOther)
+	}
+
+	repo.App.Session.Put(r.Context(), "flash", "Successfully changed the reservation")
+
+}
+
+// AdminPostReservationNote adds an internal note to a reservation, the author is the current user
+func (repo *Repository) AdminPostReservationNote(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	exploded := strings.Split(r.RequestURI, "/")
+
+	id, err := strconv.Atoi(exploded[4])
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	src := exploded[3]
+	redirect := fmt.Sprintf("/admin/reservations/%s/%d/show?y=%s&m=%s", src, id, url.QueryEscape(r.Form.Get("year")),
+		url.QueryEscape(r.Form.Get("month")))
+
+	note := models.ReservationNote{
+		ReservationID: id,
+		UserID:        helpers.CurrentUser(r).ID,
+		Body:          strings.TrimSpace(r.Form.Get("note")),
+	}
+
+	if note.Body == "" {
+		repo.App.Session.Put(r.Context(), "error", "Enter the note")
+		http.Redirect(w, r, redirect, http.StatusSeeOther)
+		return
+	}
+
+	note.ID, err = repo.DB.InsertReservationNote(note)
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	repo.audit(r, models.AuditCreate, models.EntityNote, note.ID, nil, note)
+
+	repo.App.Session.Put(r.Context(), "flash", "Note added")
+	http.Redirect(w, r, redirect, http.StatusSeeOther)
+}
+
+// AdminReservationsCalendar marks a reservation processed
+func (repo *Repository) AdminProcessedReservation(w http.ResponseWriter, r *http.Request) {
+	exploded := strings.Split(r.RequestURI, "/")
+	id, err := strconv.Atoi(exploded[4])
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	src := exploded[3]
+
+	res, err := repo.DB.GetReservationById(id)
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	processedVal := 1
+
+	err = repo.DB.UpdateProcessedForReservation(id, processedVal)
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	old := res
+	res.Processed = processedVal
+	repo.audit(r, models.AuditProcess, models.EntityReservation, id, old, res)
+
+	repo.sendEvent(models.EventReservationProcessed, models.Reservation{ID: id, Processed: processedVal})
+
+	year := r.URL.Query().Get("y")
+	month := r.URL.Query().Get("m")
+
+	if year == "" {
+		http.Redirect(w, r, fmt.Sprintf("/admin/reservations-%s", src), http.StatusSeeOther)
+	} else {
+		http.Redirect(w, r, fmt.Sprintf("/admin/reservations-calendar?y=%s&m=%s", year, month), http.StatusSeeOther)
+	}
+
+	repo.App.Session.Put(r.Context(), "flash", "Reservation marked as processed")
+}
+
+// AdminDeleteReservation deletes a reservation from DB
+func (repo *Repository) AdminDeleteReservation(w http.ResponseWriter, r *http.Request) {
+	exploded := strings.Split(r.RequestURI, "/")
+	id, err := strconv.Atoi(exploded[4])
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	src := exploded[3]
+
+	// we get the reservation before deleting it, so we can tell the listeners which reservation was cancelled
+	res, err := repo.DB.GetReservationById(id)
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	err = repo.DB.DeleteReservation(id)
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	repo.audit(r, models.AuditDelete, models.EntityReservation, id, res, nil)
+	repo.sendEvent(models.EventReservationCancelled, res)
+
+	year := r.URL.Query().Get("y")
+	month := r.URL.Query().Get("m")
+
+	if year == "" {
+		http.Redirect(w, r, fmt.Sprintf("/admin/reservations-%s", src), http.StatusSeeOther)
+	} else {
+		http.Redirect(w, r, fmt.Sprintf("/admin/reservations-calendar?y=%s&m=%s", year, month), http.StatusSeeOther)
+	}
+
+	repo.App.Session.Put(r.Context(), "warning", "Reservation deleted successfully")
+}
+
+// these are the actions that can be applied to the reservations selected in the lists
+const (
+	bulkProcess = "process"
+	bulkCancel  = "cancel"
+	bulkExport  = "export"
+	bulkEmail   = "email"
+)
+
+// bulkPermissions are the permissions the bulk actions require
+var bulkPermissions = map[string]string{
+	bulkProcess: models.PermEditReservations,
+	bulkCancel:  models.PermDeleteReservations,
+	bulkExport:  models.PermExportReservations,
+	bulkEmail:   models.PermEditReservations,
+}
+
+// AdminPostBulkReservations applies an action to the reservations selected in the lists, the reservations are changed
+// in a single transaction, so either all of them or none of them are changed
+func (repo *Repository) AdminPostBulkReservations(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	src := r.Form.Get("src")
+	if src != "new" {
+		src = "all"
+	}
+
+	back := fmt.Sprintf("/admin/reservations-%s", src)
+
+	action := r.Form.Get("action")
+	permission, ok := bulkPermissions[action]
+
+	if !ok {
+		repo.App.Session.Put(r.Context(), "error", "Choose an action")
+		http.Redirect(w, r, back, http.StatusSeeOther)
+		return
+	}
+
+	if !helpers.CurrentUser(r).Can(permission) {
+		repo.App.Session.Put(r.Context(), "error", "You don't have permission to do that")
+		http.Redirect(w, r, back, http.StatusSeeOther)
+		return
+	}
+
+	var ids []int
+	seen := make(map[int]bool)
+
+	for _, value := range r.Form["ids"] {
+		id, err := strconv.Atoi(value)
+
+		if err != nil {
+			helpers.ClientError(w, http.StatusBadRequest)
+			return
+		}
+
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	if len(ids) == 0 {
+		repo.App.Session.Put(r.Context(), "error", "Choose at least one reservation")
+		http.Redirect(w, r, back, http.StatusSeeOther)
+		return
+	}
+
+	reason := strings.TrimSpace(r.Form.Get("reason"))
+	subject := strings.TrimSpace(r.Form.Get("subject"))
+	message := strings.TrimSpace(r.Form.Get("message"))
+
+	if action == bulkCancel && reason == "" {
+		repo.App.Session.Put(r.Context(), "error", "Enter a reason for the cancellation")
+		http.Redirect(w, r, back, http.StatusSeeOther)
+		return
+	}
+
+	if action == bulkEmail && (subject == "" || message == "") {
+		repo.App.Session.Put(r.Context(), "error", "Enter the subject and the message of the email")
+		http.Redirect(w, r, back, http.StatusSeeOther)
+		return
+	}
+
+	filter := models.ReservationFilter{IDs: ids, PerPage: len(ids)}
+
+	if action == bulkExport {
+		repo.writeReservationsCSV(w, filter, export.SelectColumns(r.Form["columns"]))
+		return
+	}
+
+	reservations, _, err := repo.DB.FilterReservations(filter)
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	if len(reservations) != len(ids) {
+		err = repository.ErrReservationsChanged
+	}
+
+	if err == nil {
+		switch action {
+		case bulkProcess:
+			err = repo.DB.UpdateProcessedForReservations(ids, 1)
+		case bulkCancel:
+			err = repo.DB.DeleteReservations(ids)
+		}
+	}
+
+	if errors.Is(err, repository.ErrReservationsChanged) {
+		repo.App.Session.Put(r.Context(), "error", "Some of the reservations don't exist anymore, nothing is changed")
+		http.Redirect(w, r, back, http.StatusSeeOther)
+		return
+	}
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	switch action {
+	case bulkProcess:
+		for _, res := range reservations {
+			old := res
+			res.Processed = 1
+			repo.audit(r, models.AuditProcess, models.EntityReservation, res.ID, old, res)
+			repo.sendEvent(models.EventReservationProcessed, models.Reservation{ID: res.ID, Processed: res.Processed})
+		}
+
+		repo.App.Session.Put(r.Context(), "flash", fmt.Sprintf("%d reservations marked as processed", len(reservations)))
+	case bulkCancel:
+		for _, res := range reservations {
+			repo.audit(r, models.AuditDelete, models.EntityReservation, res.ID, res, map[string]string{"reason": reason})
+			repo.sendEvent(models.EventReservationCancelled, res)
+
+			if r.Form.Get("notify_guest") != "" && res.Email != "" {
+				repo.sendReservationCancellation(res, reason)
+			}
+		}
+
+		repo.App.Session.Put(r.Context(), "warning", fmt.Sprintf("%d reservations cancelled", len(reservations)))
+	case bulkEmail:
+		sent := 0
+
+		for _, res := range reservations {
+			if res.Email == "" {
+				continue
+			}
+
+			placeholders := reservationPlaceholders(res)
+
+			repo.App.MailChan <- models.MailData{
+				To:       res.Email,
+				From:     "me@here.com",
+				Subject:  placeholders.Replace(subject),
+				Content:  strings.ReplaceAll(html.EscapeString(placeholders.Replace(message)), "\n", "<br>"),
+				Template: "basic.gohtml",
+			}
+			sent++
+		}
+
+		repo.App.Session.Put(r.Context(), "flash", fmt.Sprintf("Email sent to %d guests", sent))
+	}
+
+	http.Redirect(w, r, back, http.StatusSeeOther)
+}
+
+// reservationPlaceholders replaces the placeholders of the bulk emails with the reservation's details
+func reservationPlaceholders(res models.Reservation) *strings.Replacer {
+	return strings.NewReplacer(
+		"{id}", strconv.Itoa(res.ID),
+		"{first_name}", res.FirstName,
+		"{last_name}", res.LastName,
+		"{start_date}", res.StartDate.Format("2006-01-02"),
+		"{end_date}", res.EndDate.Format("2006-01-02"),
+		"{room}", res.Room.RoomName,
+	)
+}
+
+// AdminReservationsCalendar displays the reservation calendar
+func (repo *Repository) AdminReservationsCalendar(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+
+	if r.URL.Query().Get("y") != "" {
+		year, err := strconv.Atoi(r.URL.Query().Get("y"))
+
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+
+		month, err := strconv.Atoi(r.URL.Query().Get("m"))
+
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+
+		now = time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	data := make(map[string]interface{})
 	data["now"] = now
 
-	next := now.AddDate(0, 1, 0)
-	previous := now.AddDate(0, -1, 0)
+	next := now.AddDate(0, 1, 0)
+	previous := now.AddDate(0, -1, 0)
+
+	nextMonth := next.Format("01")
+	nextMonthYear := next.Format("2006")
+
+	previousMonth := previous.Format("01")
+	previousMonthYear := previous.Format("2006")
+
+	stringMap := make(map[string]string)
+	stringMap["next_month"] = nextMonth
+	stringMap["next_month_year"] = nextMonthYear
+	stringMap["previous_month"] = previousMonth
+	stringMap["previous_month_year"] = previousMonthYear
+
+	stringMap["this_month"] = now.Format("01")
+	stringMap["this_year"] = now.Format("2006")
+
+	currentYear, currentMonth, _ := now.Date()
+	currentLocation := now.Location()
+	firstOfMonth := time.Date(currentYear, currentMonth, 1, 0, 0, 0, 0, currentLocation)
+	// here we add 1 month and reduce 1 day so we got the last day of the month
+	lastOfMonth := firstOfMonth.AddDate(0, 1, -1)
+
+	intMap := make(map[string]int)
+	intMap["days_in_month"] = lastOfMonth.Day()
+
+	rooms, err := repo.DB.AllRooms()
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data["rooms"] = rooms
+
+	// first we range over rooms
+	for _, x := range rooms {
+		reservationMap := make(map[string]int)
+		blockMap := make(map[string]int)
+
+		// then we range over for each room and set their reservation and block values to 0
+		for d := firstOfMonth; d.Before(lastOfMonth); d = d.AddDate(0, 0, 1) {
+			reservationMap[d.Format("2006-01-2")] = 0
+			blockMap[d.Format("2006-01-2")] = 0
+
+		}
+
+		// than we check for given room's restrictions and put them in a slice
+		restrictions, err := repo.DB.GetRestrictionsForRoomByDate(x.ID, firstOfMonth, lastOfMonth)
+
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+
+		// then we range over the slice and update restrictions according to if the restriction is a reservation or a block
+		for _, res := range restrictions {
+			if res.ReservationID > 0 {
+				for d := res.StartDate; d.Before(res.EndDate); d = d.AddDate(0, 0, 1) {
+					reservationMap[d.Format("2006-01-2")] = res.ReservationID
+				}
+			} else {
+				blockMap[res.StartDate.Format("2006-01-2")] = res.ID
+			}
+		}
+
+		data[fmt.Sprintf("reservation_map_%d", x.ID)] = reservationMap
+		data[fmt.Sprintf("block_map_%d", x.ID)] = blockMap
+
+		repo.App.Session.Put(r.Context(), fmt.Sprintf("block_map_%d", x.ID), blockMap)
+	}
+
+	utils.Template(w, r, "admin-reservations-calendar.page.gohtml", &models.TemplateData{
+		StringMap: stringMap,
+		Data:      data,
+		IntMap:    intMap,
+	})
+}
+
+// AdminPostReservationsCalendar handles the changes that made on calendar page
+func (repo *Repository) AdminPostReservationsCalendar(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	year, _ := strconv.Atoi(r.Form.Get("y"))
+	month, _ := strconv.Atoi(r.Form.Get("m"))
+
+	rooms, err := repo.DB.AllRooms()
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+
+	// this handles removed blocks
+	for _, x := range rooms {
+		curMap := repo.App.Session.Get(r.Context(), fmt.Sprintf("block_map_%d", x.ID)).(map[string]int)
+		for name, value := range curMap {
+			if val, ok := curMap[name]; ok {
+				if val > 0 {
+					if !form.Has(fmt.Sprintf("remove_block_%d_%s", x.ID, name)) {
+						err := repo.DB.RemoveBlockForRoom(value)
+						if err != nil {
+							helpers.ServerError(w, err)
+							return
+						}
+						date, _ := time.Parse("2006-01-2", name)
+						block := models.RoomRestriction{ID: value, RoomID: x.ID, StartDate: date, EndDate: date.AddDate(0, 0, 1)}
+						repo.audit(r, models.AuditDelete, models.EntityBlock, value, block, nil)
+						repo.sendEvent(models.EventBlockRemoved, models.RoomRestriction{ID: value, RoomID: x.ID})
+					}
+				}
+			}
+		}
+	}
+
+	// this handles new blocks
+	for name := range r.PostForm {
+		if strings.HasPrefix(name, "add_block") {
+			exploded := strings.Split(name, "_")
+			roomID, _ := strconv.Atoi(exploded[2])
+			// insert a new restriction
+
+			date, err := time.Parse("2006-01-2", exploded[len(exploded)-1])
+
+			if err != nil {
+				helpers.ServerError(w, err)
+				return
+			}
+
+			err = repo.DB.InsertBlockForRoom(roomID, date)
+
+			if err != nil {
+				helpers.ServerError(w, err)
+				return
+			}
+
+			block := models.RoomRestriction{
+				StartDate:     date,
+				EndDate:       date.AddDate(0, 0, 1),
+				RoomID:        roomID,
+				RestrictionID: 2,
+			}
+			repo.audit(r, models.AuditCreate, models.EntityBlock, 0, nil, block)
+			repo.sendEvent(models.EventBlockAdded, block)
+		}
+	}
+
+	repo.App.Session.Put(r.Context(), "flash", "Chages saved")
+	http.Redirect(w, r, fmt.Sprintf("/admin/reservations-calendar?y=%d&m=%d", year, month), http.StatusSeeOther)
+}
+
+// these are the bounds and the default of the timeline's range in days
+const (
+	timelineMinDays     = 14
+	timelineMaxDays     = 92
+	timelineDefaultDays = 31
+)
+
+// timelineRange is a range admins can choose in the timeline
+type timelineRange struct {
+	Days  int
+	Label string
+}
+
+// timelineRanges are the ranges offered in the timeline, other ranges between the bounds can be used in the URL
+var timelineRanges = []timelineRange{
+	{14, "2 weeks"},
+	{31, "1 month"},
+	{61, "2 months"},
+	{92, "3 months"},
+}
+
+// timelineBar is a reservation or a block in the timeline, it starts at the Column of its first day in the range,
+// counted from 1, and spans its days in the range
+type timelineBar struct {
+	Column        int
+	Span          int
+	Label         string
+	URL           string
+	Kind          string
+	ContinuesLeft bool
+	ContinuesOn   bool
+}
+
+// timelineRow is a room of the timeline with its bars
+type timelineRow struct {
+	Room models.Room
+	Bars []timelineBar
+}
+
+// timelineBars places the restrictions in the range of days from start, the parts outside of the range are cut off
+func timelineBars(restrictions []models.RoomRestriction, start time.Time, days int) []timelineBar {
+	var bars []timelineBar
+
+	for _, rr := range restrictions {
+		first := int(rr.StartDate.Sub(start).Hours() / 24)
+		last := int(rr.EndDate.Sub(start).Hours() / 24)
+
+		bar := timelineBar{
+			ContinuesLeft: first < 0,
+			ContinuesOn:   last > days,
+		}
+
+		if first < 0 {
+			first = 0
+		}
+
+		if last > days {
+			last = days
+		}
+
+		if last <= first {
+			continue
+		}
+
+		bar.Column = first + 1
+		bar.Span = last - first
+
+		switch {
+		case rr.ReservationID > 0:
+			bar.Kind = "reservation"
+			if rr.Reservation.Processed == 1 {
+				bar.Kind = "processed"
+			}
+			bar.Label = strings.TrimSpace(rr.Reservation.FirstName + " " + rr.Reservation.LastName)
+			bar.URL = fmt.Sprintf("/admin/reservations/all/%d/show", rr.ReservationID)
+		case rr.ICalImportID > 0:
+			bar.Kind = "external"
+			bar.Label = "External"
+		default:
+			bar.Kind = "block"
+			bar.Label = "Blocked"
+		}
+
+		bars = append(bars, bar)
+	}
+
+	return bars
+}
+
+// AdminReservationsTimeline shows the reservations and blocks of all rooms as bars on a timeline, rooms are the rows
+// and the days of the range are the columns
+func (repo *Repository) AdminReservationsTimeline(w http.ResponseWriter, r *http.Request) {
+	start := today()
+
+	if s := r.URL.Query().Get("start"); s != "" {
+		var err error
+		start, err = time.Parse("2006-01-02", s)
+
+		if err != nil {
+			repo.App.Session.Put(r.Context(), "error", "Invalid date")
+			http.Redirect(w, r, r.URL.Path, http.StatusSeeOther)
+			return
+		}
+	}
+
+	days := timelineDefaultDays
+
+	if d := r.URL.Query().Get("days"); d != "" {
+		var err error
+		days, err = strconv.Atoi(d)
+
+		if err != nil || days < timelineMinDays || days > timelineMaxDays {
+			repo.App.Session.Put(r.Context(), "error", fmt.Sprintf("The range must be between %d and %d days", timelineMinDays, timelineMaxDays))
+			http.Redirect(w, r, r.URL.Path, http.StatusSeeOther)
+			return
+		}
+	}
+
+	end := start.AddDate(0, 0, days)
+
+	timeline, err := repo.DB.RoomTimeline(start, end)
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	rows := make([]timelineRow, len(timeline))
+	for n, t := range timeline {
+		rows[n] = timelineRow{
+			Room: t.Room,
+			Bars: timelineBars(t.Restrictions, start, days),
+		}
+	}
+
+	dates := make([]time.Time, days)
+	for n := range dates {
+		dates[n] = start.AddDate(0, 0, n)
+	}
+
+	layout := "2006-01-02"
+
+	data := make(map[string]interface{})
+	data["rows"] = rows
+	data["dates"] = dates
+	data["ranges"] = timelineRanges
+	data["today"] = today()
+
+	stringMap := make(map[string]string)
+	stringMap["start"] = start.Format(layout)
+	stringMap["end"] = end.AddDate(0, 0, -1).Format(layout)
+	stringMap["previous"] = fmt.Sprintf("/admin/reservations-timeline?start=%s&days=%d", start.AddDate(0, 0, -days).Format(layout), days)
+	stringMap["next"] = fmt.Sprintf("/admin/reservations-timeline?start=%s&days=%d", end.Format(layout), days)
+	stringMap["today"] = fmt.Sprintf("/admin/reservations-timeline?days=%d", days)
+
+	intMap := make(map[string]int)
+	intMap["days"] = days
+
+	utils.Template(w, r, "admin-reservations-timeline.page.gohtml", &models.TemplateData{
+		Data:      data,
+		StringMap: stringMap,
+		IntMap:    intMap,
+	})
+}
+
+// liveHeartbeat is how often a comment is sent to live update clients, so proxies don't close idle connections
+const liveHeartbeat = 30 * time.Second
+
+// AdminLiveEvents streams reservation and block events to the admin pages as Server-Sent Events, the number of new
+// reservations is sent as a stats event when the client connects and after each reservation event
+func (repo *Repository) AdminLiveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+
+	if !ok {
+		helpers.ServerError(w, errors.New("streaming is not supported"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	events := repo.App.Broker.Subscribe()
+	defer repo.App.Broker.Unsubscribe(events)
+
+	ticker := time.NewTicker(liveHeartbeat)
+	defer ticker.Stop()
+
+	err := repo.writeLiveStats(w)
+
+	for err == nil {
+		flusher.Flush()
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			_, err = fmt.Fprint(w, ": ping\n\n")
+		case e := <-events:
+			err = writeLiveEvent(w, "", e)
+
+			if err == nil && strings.HasPrefix(e.Name, "reservation.") {
+				err = repo.writeLiveStats(w)
+			}
+		}
+	}
+
+	repo.App.ErrorLog.Println("live events stopped:", err)
+}
+
+// writeLiveStats sends the numbers shown in the admin layout
+func (repo *Repository) writeLiveStats(w io.Writer) error {
+	count, err := repo.DB.CountNewReservations()
+
+	if err != nil {
+		return err
+	}
+
+	return writeLiveEvent(w, "stats", map[string]int{"new_reservations": count})
+}
+
+// writeLiveEvent writes data as a Server-Sent Event, events without a name are received by onmessage of EventSource
+func writeLiveEvent(w io.Writer, name string, data interface{}) error {
+	payload, err := json.Marshal(data)
+
+	if err != nil {
+		return err
+	}
+
+	if name != "" {
+		_, err = fmt.Fprintf(w, "event: %s\n", name)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = fmt.Fprintf(w, "data: %s\n\n", payload)
+
+	return err
+}
+
+// sendEvent sends an event to the event channel, so the webhook endpoints and the admin pages can react to it
+func (repo *Repository) sendEvent(name string, data interface{}) {
+	repo.App.EventChan <- models.Event{
+		Name:      name,
+		Data:      data,
+		CreatedAt: time.Now(),
+	}
+}
+
+// audit records a change the current user made in the audit log, before and after are saved as JSON, nil is saved as
+// nothing. The change is already done when it's recorded, so the failures are only logged
+func (repo *Repository) audit(r *http.Request, action, entity string, entityID int, before, after interface{}) {
+	e := models.AuditEntry{
+		UserID:   helpers.CurrentUser(r).ID,
+		Action:   action,
+		Entity:   entity,
+		EntityID: entityID,
+		IP:       repo.clientIP(r),
+	}
+
+	var err error
+
+	e.Before, err = auditJSON(before)
+
+	if err == nil {
+		e.After, err = auditJSON(after)
+	}
+
+	if err == nil {
+		err = repo.DB.InsertAuditEntry(e)
+	}
+
+	if err != nil {
+		repo.App.ErrorLog.Printf("cannot record %s %s %d in audit log: %s", action, entity, entityID, err)
+	}
+}
+
+// auditJSON returns the JSON of an audited entity, nil returns an empty string
+func auditJSON(v interface{}) (string, error) {
+	if v == nil {
+		return "", nil
+	}
+
+	b, err := json.Marshal(v)
+
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
+// clientIP returns the IP address of the request without its port. Behind a reverse proxy every request comes from
+// the proxy's address, so the configured header is used instead, its last entry is the one the proxy added
+func (repo *Repository) clientIP(r *http.Request) string {
+	if repo.App.IPHeader != "" {
+		entries := strings.Split(r.Header.Get(repo.App.IPHeader), ",")
+
+		if ip := strings.TrimSpace(entries[len(entries)-1]); ip != "" {
+			return ip
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// auditEntriesPerPage is the page size of the audit log
+const auditEntriesPerPage = 50
+
+// auditFilterFromQuery reads the audit log's filters and page from the query, an error is returned for invalid values
+func auditFilterFromQuery(q url.Values) (models.AuditFilter, error) {
+	filter := models.AuditFilter{
+		Page:    1,
+		PerPage: auditEntriesPerPage,
+	}
+
+	var err error
+
+	layout := "2006-01-02"
+
+	if userID := q.Get("user_id"); userID != "" {
+		filter.UserID, err = strconv.Atoi(userID)
+		if err != nil {
+			return filter, err
+		}
+	}
+
+	if entity := q.Get("entity"); entity != "" {
+		valid := false
+		for _, e := range models.AuditEntities {
+			if entity == e {
+				valid = true
+			}
+		}
+
+		if !valid {
+			return filter, fmt.Errorf("unknown audit entity %s", entity)
+		}
+
+		filter.Entity = entity
+	}
+
+	if start := q.Get("start"); start != "" {
+		filter.StartDate, err = time.Parse(layout, start)
+		if err != nil {
+			return filter, err
+		}
+	}
+
+	if end := q.Get("end"); end != "" {
+		filter.EndDate, err = time.Parse(layout, end)
+		if err != nil {
+			return filter, err
+		}
+	}
+
+	if page := q.Get("page"); page != "" {
+		filter.Page, err = strconv.Atoi(page)
+		if err != nil {
+			return filter, err
+		}
+
+		if filter.Page < 1 {
+			filter.Page = 1
+		}
+	}
+
+	return filter, nil
+}
+
+// AdminAuditLog shows the changes admins made, latest first, filtered by user, entity and date
+func (repo *Repository) AdminAuditLog(w http.ResponseWriter, r *http.Request) {
+	filter, err := auditFilterFromQuery(r.URL.Query())
+
+	if err != nil {
+		repo.App.Session.Put(r.Context(), "error", "Invalid filters")
+		http.Redirect(w, r, r.URL.Path, http.StatusSeeOther)
+		return
+	}
+
+	entries, total, err := repo.DB.FilterAuditEntries(filter)
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	users, err := repo.DB.AllUsers()
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	pages := (total + filter.PerPage - 1) / filter.PerPage
+
+	data := make(map[string]interface{})
+	data["entries"] = entries
+	data["users"] = users
+	data["entities"] = models.AuditEntities
+	data["filter"] = filter
+	data["pages"] = pageLinks(r.URL, filter.Page, pages)
+
+	if filter.Page > 1 {
+		data["prev_url"] = listURL(r.URL, map[string]string{"page": strconv.Itoa(filter.Page - 1)})
+	}
+
+	if filter.Page < pages {
+		data["next_url"] = listURL(r.URL, map[string]string{"page": strconv.Itoa(filter.Page + 1)})
+	}
+
+	intMap := make(map[string]int)
+	intMap["total"] = total
+
+	utils.Template(w, r, "admin-audit-log.page.gohtml", &models.TemplateData{
+		Data:   data,
+		IntMap: intMap,
+	})
+}
+
+// searchResultsPerType is how many reservations, guests and rooms the admin search shows at most
+const searchResultsPerType = 10
+
+// searchMinLength is the shortest query the admin search runs, shorter ones would match almost everything
+const searchMinLength = 2
+
+// AdminSearch searches reservations, guests and rooms by the query of the navbar, the results are grouped by type
+func (repo *Repository) AdminSearch(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+
+	var results models.SearchResults
+	searched := utf8.RuneCountInString(q) >= searchMinLength
+
+	if searched {
+		var err error
+		results, err = repo.DB.Search(q, searchResultsPerType)
+
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+	}
+
+	stringMap := make(map[string]string)
+	stringMap["q"] = q
+
+	intMap := make(map[string]int)
+	intMap["min_length"] = searchMinLength
+	intMap["per_type"] = searchResultsPerType
+
+	data := make(map[string]interface{})
+	data["results"] = results
+	data["searched"] = searched
+
+	utils.Template(w, r, "admin-search.page.gohtml", &models.TemplateData{
+		StringMap: stringMap,
+		IntMap:    intMap,
+		Data:      data,
+	})
+}
+
+// dailyRooms returns the rooms of the daily report with their arrival, departure, stay-over and block on the day,
+// the timeline must hold the restrictions from the day before to the day after, inactive rooms are only listed if
+// they have something going on
+func dailyRooms(timeline []models.TimelineRoom, day time.Time) []models.DailyRoom {
+	var rooms []models.DailyRoom
+
+	for _, t := range timeline {
+		d := models.DailyRoom{Room: t.Room}
+
+		for _, rr := range t.Restrictions {
+			switch {
+			case rr.ReservationID == 0:
+				if !rr.StartDate.After(day) && rr.EndDate.After(day) {
+					d.Block = rr
+				}
+			case rr.StartDate.Equal(day):
+				d.Arrival = rr
+			case rr.EndDate.Equal(day):
+				d.Departure = rr
+			case rr.StartDate.Before(day) && rr.EndDate.After(day):
+				d.StayOver = rr
+			}
+		}
+
+		if t.Room.Active == 0 && d.Housekeeping() == models.HousekeepingNone {
+			continue
+		}
+
+		rooms = append(rooms, d)
+	}
+
+	return rooms
+}
+
+// AdminDailyReport shows who arrives, leaves and stays over on a day, the vacant rooms and the housekeeping tasks of
+// each room, the report is downloaded as CSV if format is csv
+func (repo *Repository) AdminDailyReport(w http.ResponseWriter, r *http.Request) {
+	day := today()
+
+	if s := r.URL.Query().Get("date"); s != "" {
+		var err error
+		day, err = time.Parse("2006-01-02", s)
+
+		if err != nil {
+			repo.App.Session.Put(r.Context(), "error", "Invalid date")
+			http.Redirect(w, r, r.URL.Path, http.StatusSeeOther)
+			return
+		}
+	}
+
+	// departures end on the day and arrivals start on it, so the timeline covers the days around it
+	timeline, err := repo.DB.RoomTimeline(day.AddDate(0, 0, -1), day.AddDate(0, 0, 1))
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	rooms := dailyRooms(timeline, day)
+
+	if r.URL.Query().Get("format") == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=daily-report-%s.csv", day.Format("2006-01-02")))
+
+		err = export.WriteDailyReport(w, rooms)
+
+		if err != nil {
+			repo.App.ErrorLog.Println("cannot export daily report:", err)
+		}
+		return
+	}
+
+	var arrivals, departures, stayOvers, vacant []models.DailyRoom
+
+	for _, d := range rooms {
+		if d.Arrival.ID > 0 {
+			arrivals = append(arrivals, d)
+		}
+
+		if d.Departure.ID > 0 {
+			departures = append(departures, d)
+		}
+
+		if d.StayOver.ID > 0 {
+			stayOvers = append(stayOvers, d)
+		}
+
+		if d.Vacant() {
+			vacant = append(vacant, d)
+		}
+	}
+
+	stringMap := make(map[string]string)
+	stringMap["date"] = day.Format("2006-01-02")
+	stringMap["prev"] = day.AddDate(0, 0, -1).Format("2006-01-02")
+	stringMap["next"] = day.AddDate(0, 0, 1).Format("2006-01-02")
+
+	data := make(map[string]interface{})
+	data["day"] = day
+	data["rooms"] = rooms
+	data["arrivals"] = arrivals
+	data["departures"] = departures
+	data["stay_overs"] = stayOvers
+	data["vacant"] = vacant
+
+	utils.Template(w, r, "admin-daily-report.page.gohtml", &models.TemplateData{
+		StringMap: stringMap,
+		Data:      data,
+	})
+}
+
+// guestsPerPage is the page size of the guest list
+const guestsPerPage = 25
+
+// AdminGuests shows the guests that match the search and the tag
+func (repo *Repository) AdminGuests(w http.ResponseWriter, r *http.Request) {
+	filter := models.GuestFilter{
+		Search:  strings.TrimSpace(r.URL.Query().Get("q")),
+		Tag:     strings.TrimSpace(r.URL.Query().Get("tag")),
+		Page:    1,
+		PerPage: guestsPerPage,
+	}
+
+	if page := r.URL.Query().Get("page"); page != "" {
+		var err error
+		filter.Page, err = strconv.Atoi(page)
+
+		if err != nil || filter.Page < 1 {
+			repo.App.Session.Put(r.Context(), "error", "Invalid page")
+			http.Redirect(w, r, r.URL.Path, http.StatusSeeOther)
+			return
+		}
+	}
+
+	guests, total, err := repo.DB.FilterGuests(filter)
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	pages := (total + filter.PerPage - 1) / filter.PerPage
+
+	data := make(map[string]interface{})
+	data["guests"] = guests
+	data["filter"] = filter
+	data["tags"] = models.GuestTags
+	data["pages"] = pageLinks(r.URL, filter.Page, pages)
+
+	if filter.Page > 1 {
+		data["prev_url"] = listURL(r.URL, map[string]string{"page": strconv.Itoa(filter.Page - 1)})
+	}
+
+	if filter.Page < pages {
+		data["next_url"] = listURL(r.URL, map[string]string{"page": strconv.Itoa(filter.Page + 1)})
+	}
+
+	intMap := make(map[string]int)
+	intMap["total"] = total
+
+	utils.Template(w, r, "admin-guests.page.gohtml", &models.TemplateData{
+		Data:   data,
+		IntMap: intMap,
+	})
+}
+
+// AdminShowGuest shows a guest's profile with past and upcoming stays, and the guests that might be duplicates
+func (repo *Repository) AdminShowGuest(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	guest, err := repo.DB.GetGuestById(id)
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	repo.renderGuest(w, r, guest, forms.New(nil))
+}
+
+// renderGuest shows the guest's profile page with the form
+func (repo *Repository) renderGuest(w http.ResponseWriter, r *http.Request, guest models.Guest, form *forms.Form) {
+	reservations, err := repo.DB.ReservationsForGuest(guest.ID)
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	duplicates, err := repo.DB.GuestDuplicates(guest)
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	// stays that haven't ended yet are upcoming
+	var upcoming, past []models.Reservation
+	for _, res := range reservations {
+		if res.EndDate.Before(today()) {
+			past = append(past, res)
+		} else {
+			upcoming = append(upcoming, res)
+		}
+	}
+
+	// suggested tags are checkboxes, the others are edited as text
+	var otherTags []string
+	for _, tag := range guest.Tags {
+		if !(models.Guest{Tags: models.GuestTags}).HasTag(tag) {
+			otherTags = append(otherTags, tag)
+		}
+	}
+
+	stringMap := make(map[string]string)
+	stringMap["other_tags"] = strings.Join(otherTags, ", ")
+
+	data := make(map[string]interface{})
+	data["guest"] = guest
+	data["upcoming"] = upcoming
+	data["past"] = past
+	data["duplicates"] = duplicates
+	data["tags"] = models.GuestTags
+
+	utils.Template(w, r, "admin-guest-detail.page.gohtml", &models.TemplateData{
+		StringMap: stringMap,
+		Data:      data,
+		Form:      form,
+	})
+}
+
+// parseTags returns the comma separated tags without duplicates, tags are compared case insensitively
+func parseTags(s string) []string {
+	var g models.Guest
+
+	for _, tag := range strings.Split(s, ",") {
+		tag = strings.TrimSpace(tag)
+
+		if tag != "" && !g.HasTag(tag) {
+			g.Tags = append(g.Tags, tag)
+		}
+	}
+
+	return g.Tags
+}
+
+// AdminPostShowGuest saves a guest's details, notes and tags
+func (repo *Repository) AdminPostShowGuest(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	exploded := strings.Split(r.RequestURI, "/")
+
+	id, err := strconv.Atoi(exploded[3])
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	guest, err := repo.DB.GetGuestById(id)
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	old := guest
+
+	guest.FirstName = r.Form.Get("first_name")
+	guest.LastName = r.Form.Get("last_name")
+	guest.Email = strings.TrimSpace(r.Form.Get("email"))
+	guest.Phone = r.Form.Get("phone")
+	guest.Notes = r.Form.Get("notes")
+	guest.Tags = parseTags(strings.Join(r.Form["tags"], ","))
+
+	form := forms.New(r.PostForm)
+	form.Required("first_name", "last_name")
+
+	if guest.Email != "" {
+		form.IsEmail("email")
+
+		existing, err := repo.DB.GetGuestByEmail(guest.Email)
+
+		if err == nil && existing.ID != guest.ID {
+			form.Errors.Add("email", fmt.Sprintf("Guest #%d has this email, merge the guests instead", existing.ID))
+		} else if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			helpers.ServerError(w, err)
+			return
+		}
+	}
+
+	if !form.Valid() {
+		repo.renderGuest(w, r, guest, form)
+		return
+	}
+
+	err = repo.DB.UpdateGuest(guest)
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	repo.audit(r, models.AuditUpdate, models.EntityGuest, id, old, guest)
+
+	repo.App.Session.Put(r.Context(), "flash", "Guest saved")
+	http.Redirect(w, r, fmt.Sprintf("/admin/guests/%d/show", id), http.StatusSeeOther)
+}
+
+// AdminPostMergeGuest merges a duplicate guest into the guest, the duplicate's stays move to the guest
+func (repo *Repository) AdminPostMergeGuest(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	exploded := strings.Split(r.RequestURI, "/")
+	id, err := strconv.Atoi(exploded[3])
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	duplicateID, err := strconv.Atoi(r.Form.Get("duplicate"))
+
+	if err != nil || duplicateID == id {
+		repo.App.Session.Put(r.Context(), "error", "Choose another guest to merge")
+		http.Redirect(w, r, fmt.Sprintf("/admin/guests/%d/show", id), http.StatusSeeOther)
+		return
+	}
+
+	guest, err := repo.DB.GetGuestById(id)
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	duplicate, err := repo.DB.GetGuestById(duplicateID)
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	err = repo.DB.MergeGuests(id, duplicateID)
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	repo.audit(r, models.AuditMerge, models.EntityGuest, id, []models.Guest{guest, duplicate}, models.MergeGuest(guest, duplicate))
+
+	repo.App.Session.Put(r.Context(), "flash", fmt.Sprintf("Guest #%d merged into this guest", duplicateID))
+	http.Redirect(w, r, fmt.Sprintf("/admin/guests/%d/show", id), http.StatusSeeOther)
+}
+
+// AdminRooms shows all rooms in their order in admin dashboard
+func (repo *Repository) AdminRooms(w http.ResponseWriter, r *http.Request) {
+	rooms, err := repo.DB.AllRooms()
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["rooms"] = rooms
+
+	utils.Template(w, r, "admin-rooms.page.gohtml", &models.TemplateData{
+		Data: data,
+	})
+}
+
+// AdminShowRoom shows a room and its upcoming reservations, id 0 shows an empty form for a new room
+func (repo *Repository) AdminShowRoom(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	room := models.Room{Active: 1}
+	var reservations []models.Reservation
+
+	if id > 0 {
+		room, err = repo.DB.GetRoomById(id)
+
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+
+		reservations, err = repo.DB.FutureReservationsForRoom(id, today())
+
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+	}
+
+	data := make(map[string]interface{})
+	data["room"] = room
+	data["reservations"] = reservations
+
+	utils.Template(w, r, "admin-room-detail.page.gohtml", &models.TemplateData{
+		Data: data,
+		Form: forms.New(nil),
+	})
+}
+
+// AdminPostShowRoom creates or updates a room according to form, a room with upcoming reservations can't be
+// deactivated until they are moved to other rooms or cancelled
+func (repo *Repository) AdminPostShowRoom(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	exploded := strings.Split(r.RequestURI, "/")
+
+	id, err := strconv.Atoi(exploded[3])
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	room := models.Room{ID: id}
+	var old interface{}
+	var reservations []models.Reservation
+
+	if id > 0 {
+		room, err = repo.DB.GetRoomById(id)
+
+		if errors.Is(err, sql.ErrNoRows) {
+			helpers.ClientError(w, http.StatusNotFound)
+			return
+		}
+
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+
+		old = room
+
+		reservations, err = repo.DB.FutureReservationsForRoom(id, today())
+
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+	}
+
+	room.RoomName = r.Form.Get("room_name")
+	room.Description = r.Form.Get("description")
+
+	room.Active = 0
+	if r.Form.Get("active") != "" {
+		room.Active = 1
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("room_name")
+	form.MinLength("room_name", 3)
+
+	if room.Active == 0 && len(reservations) > 0 {
+		form.Errors.Add("active", fmt.Sprintf("The room has %d upcoming reservations, move them to another room or cancel them first", len(reservations)))
+	}
+
+	if !form.Valid() {
+		data := make(map[string]interface{})
+		data["room"] = room
+		data["reservations"] = reservations
+
+		utils.Template(w, r, "admin-room-detail.page.gohtml", &models.TemplateData{
+			Data: data,
+			Form: form,
+		})
+		return
+	}
+
+	action := models.AuditUpdate
+
+	if id > 0 {
+		err = repo.DB.UpdateRoom(room, today())
+	} else {
+		action = models.AuditCreate
+		id, err = repo.DB.InsertRoom(room)
+		room.ID = id
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+
+	if errors.Is(err, repository.ErrRoomHasReservations) {
+		repo.App.Session.Put(r.Context(), "error", "The room has been booked meanwhile, it can't be deactivated")
+		http.Redirect(w, r, fmt.Sprintf("/admin/rooms/%d/show", id), http.StatusSeeOther)
+		return
+	}
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	repo.audit(r, action, models.EntityRoom, id, old, room)
+
+	repo.App.Session.Put(r.Context(), "flash", "Room saved")
+	http.Redirect(w, r, fmt.Sprintf("/admin/rooms/%d/show", id), http.StatusSeeOther)
+}
+
+// AdminMoveRoom moves a room one place up or down in the rooms' order
+func (repo *Repository) AdminMoveRoom(w http.ResponseWriter, r *http.Request) {
+	exploded := strings.Split(r.RequestURI, "/")
+	id, err := strconv.Atoi(exploded[3])
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	rooms, err := repo.DB.AllRooms()
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	ids := make([]int, len(rooms))
+	for n, room := range rooms {
+		ids[n] = room.ID
+	}
+
+	old := append([]int(nil), ids...)
+
+	for n := range ids {
+		if ids[n] != id {
+			continue
+		}
+
+		if r.URL.Query().Get("dir") == "up" && n > 0 {
+			ids[n-1], ids[n] = ids[n], ids[n-1]
+		} else if r.URL.Query().Get("dir") == "down" && n < len(ids)-1 {
+			ids[n+1], ids[n] = ids[n], ids[n+1]
+		}
+
+		break
+	}
+
+	err = repo.DB.UpdateRoomOrder(ids)
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	repo.audit(r, models.AuditReorder, models.EntityRoom, id, old, ids)
+
+	http.Redirect(w, r, "/admin/rooms", http.StatusSeeOther)
+}
+
+// siteURL returns the configured scheme and host of the site for the links that are shared outside of it, it never
+// comes from a request since clients control the Host header
+func (repo *Repository) siteURL() string {
+	return repo.App.BaseURL
+}
+
+// minPasswordLength is the minimum length of users' passwords
+const minPasswordLength = 8
+
+// how long the links emailed to users can be used
+const (
+	invitationTTL    = 72 * time.Hour
+	passwordResetTTL = 24 * time.Hour
+	// anyone can ask for these links, so they expire sooner
+	forgotPasswordTTL = time.Hour
+	emailChangeTTL    = 24 * time.Hour
+)
+
+// AdminUsers shows all staff users in admin dashboard
+func (repo *Repository) AdminUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := repo.DB.AllUsers()
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	locked, err := repo.DB.LockedLogins(time.Now().Add(-loginLockout), accountLoginLimits.lockAfter, ipLoginLimits.lockAfter)
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	for i := range locked {
+		locked[i].LockedUntil = locked[i].Last.Add(loginLockout)
+	}
+
+	data := make(map[string]interface{})
+	data["users"] = users
+	data["locked"] = locked
+
+	utils.Template(w, r, "admin-users.page.gohtml", &models.TemplateData{
+		Data: data,
+	})
+}
+
+// AdminPostUnlockLogins removes the failed logins of an email or an IP address, so it can log in again at once
+func (repo *Repository) AdminPostUnlockLogins(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	email := r.Form.Get("email")
+	ip := r.Form.Get("ip")
+
+	if email == "" && ip == "" {
+		repo.App.Session.Put(r.Context(), "error", "Choose an email or an IP address to unlock")
+		http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+		return
+	}
+
+	err = repo.DB.ClearLoginFailures(email, ip)
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	repo.audit(r, models.AuditUnlock, models.EntityLogin, 0, nil, map[string]string{"email": email, "ip": ip})
+
+	repo.App.Session.Put(r.Context(), "flash", fmt.Sprintf("%s can log in again", strings.TrimSpace(email+" "+ip)))
+	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+}
+
+// AdminShowUser shows a staff user, id 0 shows an empty form to invite a new user
+func (repo *Repository) AdminShowUser(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	user := models.User{AccessLevel: models.RoleViewer, Active: 1}
+
+	if id > 0 {
+		user, err = repo.DB.GetUserById(id)
+
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+	}
+
+	data := make(map[string]interface{})
+	data["user"] = user
+	data["roles"] = models.Roles
+
+	utils.Template(w, r, "admin-user-detail.page.gohtml", &models.TemplateData{
+		Data: data,
+		Form: forms.New(nil),
+	})
+}
+
+// AdminPostShowUser invites a new user or updates a user according to form, invited users get an email with a link
+// to set their password
+func (repo *Repository) AdminPostShowUser(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	exploded := strings.Split(r.RequestURI, "/")
+
+	id, err := strconv.Atoi(exploded[3])
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	user := models.User{ID: id, Active: 1}
+	var old interface{}
+
+	if id > 0 {
+		user, err = repo.DB.GetUserById(id)
+
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+
+		old = user
+	}
+
+	user.FirstName = r.Form.Get("first_name")
+	user.LastName = r.Form.Get("last_name")
+	user.Email = r.Form.Get("email")
+	user.AccessLevel, _ = strconv.Atoi(r.Form.Get("access_level"))
+
+	if id > 0 {
+		user.Active = 0
+		if r.Form.Get("active") != "" {
+			user.Active = 1
+		}
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("first_name", "last_name", "email")
+	form.IsEmail("email")
+
+	if !models.IsRole(user.AccessLevel) {
+		form.Errors.Add("access_level", "Choose a role")
+	}
+
+	// users can't lock themselves out, another owner has to do it
+	if id > 0 && user.ID == repo.App.Session.GetInt(r.Context(), "user_id") {
+		if user.Active == 0 {
+			form.Errors.Add("active", "You can't deactivate your own account")
+		}
+
+		if user.AccessLevel != helpers.CurrentUser(r).AccessLevel {
+			form.Errors.Add("access_level", "You can't change your own role")
+		}
+	}
+
+	if form.Has("email") {
+		existing, err := repo.DB.GetUserByEmail(user.Email)
+
+		if err == nil && existing.ID != user.ID {
+			form.Errors.Add("email", "A user with this email already exists")
+		} else if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			helpers.ServerError(w, err)
+			return
+		}
+	}
+
+	if !form.Valid() {
+		data := make(map[string]interface{})
+		data["user"] = user
+		data["roles"] = models.Roles
+
+		utils.Template(w, r, "admin-user-detail.page.gohtml", &models.TemplateData{
+			Data: data,
+			Form: form,
+		})
+		return
+	}
+
+	if id > 0 {
+		err = repo.DB.UpdateUser(user)
+
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+
+		repo.audit(r, models.AuditUpdate, models.EntityUser, id, old, user)
+
+		repo.App.Session.Put(r.Context(), "flash", "User saved")
+		http.Redirect(w, r, fmt.Sprintf("/admin/users/%d/show", id), http.StatusSeeOther)
+		return
+	}
+
+	user.ID, err = repo.DB.InsertUser(user)
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	repo.audit(r, models.AuditCreate, models.EntityUser, user.ID, nil, user)
+
+	err = repo.sendUserToken(user, models.TokenInvitation)
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	repo.App.Session.Put(r.Context(), "flash", fmt.Sprintf("Invitation sent to %s", user.Email))
+	http.Redirect(w, r, fmt.Sprintf("/admin/users/%d/show", user.ID), http.StatusSeeOther)
+}
+
+// AdminPostResetUserPassword removes a user's password and emails a link to set a new one
+func (repo *Repository) AdminPostResetUserPassword(w http.ResponseWriter, r *http.Request) {
+	exploded := strings.Split(r.RequestURI, "/")
+	id, err := strconv.Atoi(exploded[3])
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	user, err := repo.DB.GetUserById(id)
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	err = repo.DB.ResetUserPassword(id)
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	repo.audit(r, models.AuditResetPassword, models.EntityUser, id, nil, nil)
+
+	err = repo.sendUserToken(user, models.TokenPasswordReset)
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	repo.App.Session.Put(r.Context(), "warning", fmt.Sprintf("Password reset, a link to set a new one is sent to %s", user.Email))
+	http.Redirect(w, r, fmt.Sprintf("/admin/users/%d/show", id), http.StatusSeeOther)
+}
+
+// sendUserToken creates a new token for the purpose, and emails the user a link to set a password with it
+func (repo *Repository) sendUserToken(user models.User, purpose string) error {
+	token, err := helpers.RandomToken()
+
+	if err != nil {
+		return err
+	}
+
+	ttl := passwordResetTTL
+	subject := "Reset Your Password"
+	message := "Your password has been reset, please set a new one with the link below."
+
+	switch purpose {
+	case models.TokenInvitation:
+		ttl = invitationTTL
+		subject = "You Are Invited"
+		message = "You are invited to the staff area of the bookings site, please set your password with the link below."
+	case models.TokenForgotPassword:
+		ttl = forgotPasswordTTL
+		message = "We received a request to reset your password, you can set a new one with the link below. " +
+			"If you didn't ask for it, you can ignore this email, your password stays the same."
+	}
+
+	err = repo.DB.InsertUserToken(models.UserToken{
+		UserID:    user.ID,
+		Token:     token,
+		Purpose:   purpose,
+		ExpiresAt: time.Now().Add(ttl),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/user/set-password?token=%s", repo.siteURL(), token)
+
+	htmlMessage := fmt.Sprintf(`
+		<strong>%s</strong>
+		<br>
+		Dear %s,
+		<br>
+		%s
+		<br>
+		<a href="%s">%s</a>
+		<br>
+		The link can be used once, until %s.
+	`, subject, user.FirstName+" "+user.LastName, message, link, link, time.Now().Add(ttl).Format("2006-01-02 15:04"))
+
+	repo.App.MailChan <- models.MailData{
+		To:       user.Email,
+		From:     "me@here.com",
+		Subject:  subject,
+		Content:  htmlMessage,
+		Template: "basic.gohtml",
+	}
+
+	return nil
+}
+
+// loginActivityCount is how many of their latest logins users see on their account page
+const loginActivityCount = 10
+
+// AdminAccount shows the current user's profile, password and login activity
+func (repo *Repository) AdminAccount(w http.ResponseWriter, r *http.Request) {
+	repo.renderAccount(w, r, helpers.CurrentUser(r), forms.New(nil))
+}
+
+// renderAccount shows the account page with the profile form's values
+func (repo *Repository) renderAccount(w http.ResponseWriter, r *http.Request, user models.User, form *forms.Form) {
+	logins, _, err := repo.DB.FilterAuditEntries(models.AuditFilter{
+		UserID:  helpers.CurrentUser(r).ID,
+		Action:  models.AuditLogin,
+		Entity:  models.EntityUser,
+		Page:    1,
+		PerPage: loginActivityCount,
+	})
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["user"] = user
+	data["logins"] = logins
+
+	utils.Template(w, r, "admin-account.page.gohtml", &models.TemplateData{
+		Data: data,
+		Form: form,
+	})
+}
+
+// AdminPostAccount saves the current user's name, a new email is saved after it is confirmed with the emailed link
+func (repo *Repository) AdminPostAccount(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	before := helpers.CurrentUser(r)
+
+	user := before
+	user.FirstName = strings.TrimSpace(r.Form.Get("first_name"))
+	user.LastName = strings.TrimSpace(r.Form.Get("last_name"))
+	email := strings.TrimSpace(r.Form.Get("email"))
+
+	form := forms.New(r.PostForm)
+	form.Required("first_name", "last_name", "email")
+	form.IsEmail("email")
+
+	emailChanged := !strings.EqualFold(email, before.Email)
+
+	// a stolen session could take over the account with a new email, so changing it needs the password
+	if emailChanged {
+		form.Required("email_password")
+
+		err = repo.checkCurrentPassword(r, form, "email_password", before)
+
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+	}
+
+	if form.Valid() && emailChanged {
+		other, err := repo.DB.GetUserByEmail(email)
+
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			helpers.ServerError(w, err)
+			return
+		}
+
+		if err == nil && other.ID != user.ID {
+			form.Errors.Add("email", "This email belongs to another user")
+		}
+	}
+
+	if !form.Valid() {
+		shown := user
+		shown.Email = email
+		repo.renderAccount(w, r, shown, form)
+		return
+	}
+
+	err = repo.DB.UpdateUser(user)
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	repo.audit(r, models.AuditUpdate, models.EntityUser, user.ID, before, user)
+
+	if emailChanged {
+		err = repo.sendEmailChange(r, user, email)
+
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+
+		repo.App.Session.Put(r.Context(), "flash", fmt.Sprintf("Your profile is saved, confirm %s with the link sent to it", email))
+		http.Redirect(w, r, "/admin/account", http.StatusSeeOther)
+		return
+	}
+
+	repo.App.Session.Put(r.Context(), "flash", "Your profile is saved")
+	http.Redirect(w, r, "/admin/account", http.StatusSeeOther)
+}
+
+// checkCurrentPassword adds an error to the form's field if it isn't the user's password, wrong passwords count as
+// failed logins, so they can't be guessed with a stolen session
+func (repo *Repository) checkCurrentPassword(r *http.Request, form *forms.Form, field string, user models.User) error {
+	ip := repo.clientIP(r)
+	account, byIP, err := repo.DB.LoginFailuresSince(user.Email, ip, time.Now().Add(-loginLockout))
+
+	if err != nil {
+		return err
+	}
+
+	if wait := time.Until(nextLoginAt(account, byIP)); wait > 0 {
+		form.Errors.Add(field, fmt.Sprintf("Too many wrong passwords, try again in %s", waitText(wait)))
+		return nil
+	}
+
+	if !form.Has(field) {
+		return nil
+	}
+
+	_, _, err = repo.DB.Authenticate(user.Email, form.Get(field))
+
+	if err != nil {
+		form.Errors.Add(field, "The password is wrong")
+		return repo.loginFailed(user.Email, ip, account)
+	}
+
+	return nil
+}
+
+// sendEmailChange emails a link to the new email, the user's email changes when the link is opened, the current email
+// is told about the change, so the user notices changes someone else asked for
+func (repo *Repository) sendEmailChange(r *http.Request, user models.User, email string) error {
+	token, err := helpers.RandomToken()
+
+	if err != nil {
+		return err
+	}
+
+	err = repo.DB.InsertUserToken(models.UserToken{
+		UserID:    user.ID,
+		Token:     token,
+		Purpose:   models.TokenEmailChange,
+		Email:     email,
+		ExpiresAt: time.Now().Add(emailChangeTTL),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/user/confirm-email?token=%s", repo.siteURL(), token)
+
+	htmlMessage := fmt.Sprintf(`
+		<strong>Confirm Your Email</strong>
+		<br>
+		Dear %s,
+		<br>
+		Please confirm this is your new email for the staff area of the bookings site with the link below.
+		<br>
+		<a href="%s">%s</a>
+		<br>
+		The link can be used once, until %s.
+	`, user.FirstName+" "+user.LastName, link, link, time.Now().Add(emailChangeTTL).Format("2006-01-02 15:04"))
+
+	repo.App.MailChan <- models.MailData{
+		To:       email,
+		From:     "me@here.com",
+		Subject:  "Confirm Your Email",
+		Content:  htmlMessage,
+		Template: "basic.gohtml",
+	}
+
+	noticeMessage := fmt.Sprintf(`
+		<strong>Email Change Requested</strong>
+		<br>
+		Dear %s,
+		<br>
+		A change of the email of your account to %s was requested, it is changed when the link sent to the new email is
+		opened.
+		<br>
+		If it wasn't you, someone may know your password, please set a new one with the link below.
+		<br>
+		<a href="%s/user/forgot-password">%s/user/forgot-password</a>
+	`, user.FirstName+" "+user.LastName, html.EscapeString(email), repo.siteURL(), repo.siteURL())
+
+	repo.App.MailChan <- models.MailData{
+		To:       user.Email,
+		From:     "me@here.com",
+		Subject:  "Email Change Requested",
+		Content:  noticeMessage,
+		Template: "basic.gohtml",
+	}
+
+	return nil
+}
+
+// AdminPostAccountPassword changes the current user's password if the current password is right, the user's other
+// sessions are logged out
+func (repo *Repository) AdminPostAccountPassword(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	user := helpers.CurrentUser(r)
+
+	form := forms.New(r.PostForm)
+	form.Required("current_password", "password", "password_confirmation")
+	form.MinLength("password", minPasswordLength)
+	form.IsPassword("password", user.FirstName, user.LastName, user.Email)
+	form.Matches("password_confirmation", "password")
+
+	err = repo.checkCurrentPassword(r, form, "current_password", user)
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	if !form.Valid() {
+		repo.renderAccount(w, r, user, form)
+		return
+	}
+
+	err = repo.DB.ChangePassword(user.ID, r.Form.Get("password"))
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	updated, err := repo.DB.GetUserById(user.ID)
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	// this session gets the new password's fingerprint, so Auth logs out only the user's other sessions
+	_ = repo.App.Session.RenewToken(r.Context())
+	repo.App.Session.Put(r.Context(), "password_fingerprint", helpers.PasswordFingerprint(updated.Password))
+
+	repo.audit(r, models.AuditChangePassword, models.EntityUser, user.ID, nil, nil)
+
+	repo.App.Session.Put(r.Context(), "flash", "Your password is changed, your other sessions are logged out")
+	http.Redirect(w, r, "/admin/account", http.StatusSeeOther)
+}
+
+// ConfirmEmail changes the user's email to the email the link was sent to
+func (repo *Repository) ConfirmEmail(w http.ResponseWriter, r *http.Request) {
+	redirect := "/user/login"
+
+	if helpers.IsAuthenticated(r) {
+		redirect = "/admin/account"
+	}
+
+	token, err := repo.DB.GetUserToken(r.URL.Query().Get("token"))
+
+	if err != nil || token.Purpose != models.TokenEmailChange {
+		repo.App.Session.Put(r.Context(), "error", "The link is invalid or expired, ask for a new one")
+		http.Redirect(w, r, redirect, http.StatusSeeOther)
+		return
+	}
+
+	// another user could have taken the email after the link was sent
+	other, err := repo.DB.GetUserByEmail(token.Email)
+
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	if err == nil && other.ID != token.UserID {
+		repo.App.Session.Put(r.Context(), "error", "This email belongs to another user")
+		http.Redirect(w, r, redirect, http.StatusSeeOther)
+		return
+	}
+
+	err = repo.DB.ConfirmEmailWithToken(token)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		repo.App.Session.Put(r.Context(), "error", "The link is invalid or expired, ask for a new one")
+		http.Redirect(w, r, redirect, http.StatusSeeOther)
+		return
+	}
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	after := token.User
+	after.Email = token.Email
+	repo.audit(r.WithContext(helpers.WithUser(r.Context(), token.User)), models.AuditChangeEmail, models.EntityUser,
+		token.UserID, token.User, after)
+
+	repo.App.Session.Put(r.Context(), "flash", fmt.Sprintf("Your email is changed to %s", token.Email))
+	http.Redirect(w, r, redirect, http.StatusSeeOther)
+}
+
+// ShowSetPassword shows the form to set a password with an invitation or reset link
+func (repo *Repository) ShowSetPassword(w http.ResponseWriter, r *http.Request) {
+	token, err := repo.DB.GetUserToken(r.URL.Query().Get("token"))
+
+	// email change tokens only confirm emails
+	if err != nil || token.Purpose == models.TokenEmailChange {
+		repo.App.Session.Put(r.Context(), "error", "The link is invalid or expired, ask for a new one")
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["token"] = token
+
+	utils.Template(w, r, "set-password.page.gohtml", &models.TemplateData{
+		Data: data,
+		Form: forms.New(nil),
+	})
+}
+
+// PostSetPassword sets the user's password with the token, the token can't be used again
+func (repo *Repository) PostSetPassword(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	token, err := repo.DB.GetUserToken(r.Form.Get("token"))
+
+	// email change tokens only confirm emails
+	if err != nil || token.Purpose == models.TokenEmailChange {
+		repo.App.Session.Put(r.Context(), "error", "The link is invalid or expired, ask for a new one")
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("password", "password_confirmation")
+	form.MinLength("password", minPasswordLength)
+	form.IsPassword("password", token.User.FirstName, token.User.LastName, token.User.Email)
+	form.Matches("password_confirmation", "password")
+
+	if !form.Valid() {
+		data := make(map[string]interface{})
+		data["token"] = token
+
+		utils.Template(w, r, "set-password.page.gohtml", &models.TemplateData{
+			Data: data,
+			Form: form,
+		})
+		return
+	}
+
+	err = repo.DB.SetPasswordWithToken(token, r.Form.Get("password"))
+
+	if errors.Is(err, sql.ErrNoRows) {
+		repo.App.Session.Put(r.Context(), "error", "The link is invalid or expired, ask for a new one")
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	// sessions keep the fingerprint of the old password, so Auth ends the user's other sessions
+	repo.App.Session.Put(r.Context(), "flash", "Your password is set, you can log in now")
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
+
+// ShowForgotPassword shows the form to request a password reset link
+func (repo *Repository) ShowForgotPassword(w http.ResponseWriter, r *http.Request) {
+	utils.Template(w, r, "forgot-password.page.gohtml", &models.TemplateData{
+		Form: forms.New(nil),
+	})
+}
+
+// PostForgotPassword emails a password reset link to the user with the email, the response is the same whether the
+// email belongs to a user or not, so it can't be used to find out who has an account. Requests are throttled by IP
+// address with the failed logins
+func (repo *Repository) PostForgotPassword(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("email")
+	form.IsEmail("email")
+
+	if !form.Valid() {
+		utils.Template(w, r, "forgot-password.page.gohtml", &models.TemplateData{
+			Form: form,
+		})
+		return
+	}
+
+	email := r.Form.Get("email")
+	ip := repo.clientIP(r)
+	_, byIP, err := repo.DB.LoginFailuresSince(email, ip, time.Now().Add(-loginLockout))
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	if wait := time.Until(ipLoginLimits.nextAt(byIP)); wait > 0 {
+		repo.App.Session.Put(r.Context(), "error", fmt.Sprintf("Too many requests, try again in %s", waitText(wait)))
+		http.Redirect(w, r, "/user/forgot-password", http.StatusSeeOther)
+		return
+	}
+
+	// requests are counted as failed logins of the IP address only, so a client asking for many links is slowed down
+	// like one guessing passwords, but nobody's account can be locked with them
+	err = repo.DB.InsertLoginFailure("", ip)
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	// the account is looked up and emailed in the background, so the response takes the same time whether the email
+	// belongs to a user or not
+	go repo.sendForgotPassword(email)
+
+	repo.App.Session.Put(r.Context(), "flash", "If the email belongs to an account, a link to reset the password is sent to it")
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
+
+// sendForgotPassword emails a password reset link if the email belongs to an active user, errors are only logged,
+// telling them apart would tell which emails have accounts
+func (repo *Repository) sendForgotPassword(email string) {
+	user, err := repo.DB.GetUserByEmail(email)
+
+	if err == nil && user.Active == 1 {
+		err = repo.sendUserToken(user, models.TokenForgotPassword)
+	}
+
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		repo.App.ErrorLog.Println("cannot send password reset link:", err)
+	}
+}
+
+// the issuer authenticator apps show for the codes, and how many recovery codes users get
+const (
+	twoFactorIssuer   = "Bookings"
+	recoveryCodeCount = 10
+)
+
+// recoveryCodeAlphabet has no characters that look alike, so the codes are easy to type from paper
+const recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+// newRecoveryCodes returns random recovery codes formatted like abcde-fghjk
+func newRecoveryCodes() ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+
+	for n := range codes {
+		b := make([]byte, 10)
+
+		_, err := rand.Read(b)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range b {
+			b[i] = recoveryCodeAlphabet[int(b[i])%len(recoveryCodeAlphabet)]
+		}
+
+		codes[n] = string(b[:5]) + "-" + string(b[5:])
+	}
+
+	return codes, nil
+}
+
+// normalizeCodes returns the recovery codes the way they are stored, so they match the codes users enter
+func normalizeCodes(codes []string) []string {
+	normalized := make([]string, len(codes))
+
+	for i, code := range codes {
+		normalized[i] = normalizeCode(code)
+	}
+
+	return normalized
+}
+
+// AdminTwoFactor shows the current user's two-factor authentication, users without it get a QR code to set it up
+func (repo *Repository) AdminTwoFactor(w http.ResponseWriter, r *http.Request) {
+	repo.renderTwoFactor(w, r, forms.New(nil), nil)
+}
+
+// renderTwoFactor shows the two-factor authentication page, the recovery codes are shown only once after they are made
+func (repo *Repository) renderTwoFactor(w http.ResponseWriter, r *http.Request, form *forms.Form, recoveryCodes []string) {
+	user := helpers.CurrentUser(r)
+
+	data := make(map[string]interface{})
+	data["required"] = helpers.RequiresTwoFactor(user)
+	data["recovery_codes"] = recoveryCodes
+
+	if user.TwoFactorEnabled() {
+		count, err := repo.DB.CountRecoveryCodes(user.ID)
+
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+
+		data["recovery_count"] = count
+	} else {
+		key, err := repo.pendingTwoFactorKey(r, user)
+
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+
+		img, err := key.Image(200, 200)
+
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+
+		var buf bytes.Buffer
+
+		err = png.Encode(&buf, img)
+
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+
+		// the QR code is a generated image, so it is safe to use as a data URL
+		data["qr_code"] = template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()))
+		data["secret"] = key.Secret()
+	}
+
+	utils.Template(w, r, "admin-two-factor.page.gohtml", &models.TemplateData{
+		Data: data,
+		Form: form,
+	})
+}
+
+// pendingTwoFactorKey returns the key the user is setting up, the key is kept in the session until the user confirms
+// it with a code, so reloading the page doesn't change the QR code
+func (repo *Repository) pendingTwoFactorKey(r *http.Request, user models.User) (*otp.Key, error) {
+	if u := repo.App.Session.GetString(r.Context(), "two_factor_key"); u != "" {
+		return otp.NewKeyFromURL(u)
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      twoFactorIssuer,
+		AccountName: user.Email,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	repo.App.Session.Put(r.Context(), "two_factor_key", key.URL())
+
+	return key, nil
+}
+
+// AdminPostTwoFactor turns on the current user's two-factor authentication if the code of the new key is valid, and
+// shows the recovery codes
+func (repo *Repository) AdminPostTwoFactor(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	user := helpers.CurrentUser(r)
+
+	if user.TwoFactorEnabled() {
+		http.Redirect(w, r, "/admin/two-factor", http.StatusSeeOther)
+		return
+	}
+
+	key, err := repo.pendingTwoFactorKey(r, user)
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("code")
+
+	if form.Valid() && !totp.Validate(normalizeCode(r.Form.Get("code")), key.Secret()) {
+		form.Errors.Add("code", "Invalid code, check the time of your device")
+	}
+
+	if !form.Valid() {
+		repo.renderTwoFactor(w, r, form, nil)
+		return
+	}
+
+	codes, err := newRecoveryCodes()
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	err = repo.DB.EnableTwoFactor(user.ID, key.Secret(), normalizeCodes(codes))
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	repo.App.Session.Remove(r.Context(), "two_factor_key")
+	repo.audit(r, models.AuditEnableTwoFactor, models.EntityUser, user.ID, nil, nil)
+
+	user.TOTPSecret = key.Secret()
+	r = r.WithContext(helpers.WithUser(r.Context(), user))
+
+	repo.App.Session.Put(r.Context(), "flash", "Two-factor authentication is on, save your recovery codes")
+	repo.renderTwoFactor(w, r, forms.New(nil), codes)
+}
+
+// AdminPostRecoveryCodes replaces the current user's recovery codes with new ones if the code of the app is valid
+func (repo *Repository) AdminPostRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	user := helpers.CurrentUser(r)
+
+	if !user.TwoFactorEnabled() || !totp.Validate(normalizeCode(r.Form.Get("code")), user.TOTPSecret) {
+		repo.App.Session.Put(r.Context(), "error", "Invalid code")
+		http.Redirect(w, r, "/admin/two-factor", http.StatusSeeOther)
+		return
+	}
+
+	codes, err := newRecoveryCodes()
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	err = repo.DB.ReplaceRecoveryCodes(user.ID, normalizeCodes(codes))
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	repo.audit(r, models.AuditNewRecoveryCodes, models.EntityUser, user.ID, nil, nil)
+
+	repo.App.Session.Put(r.Context(), "flash", "New recovery codes are made, the old ones can't be used anymore")
+	repo.renderTwoFactor(w, r, forms.New(nil), codes)
+}
+
+// AdminPostDisableTwoFactor turns off the current user's two-factor authentication if the code of the app is valid,
+// users whose roles require it can't turn it off
+func (repo *Repository) AdminPostDisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	user := helpers.CurrentUser(r)
+
+	if helpers.RequiresTwoFactor(user) {
+		repo.App.Session.Put(r.Context(), "error", "Your role requires two-factor authentication")
+		http.Redirect(w, r, "/admin/two-factor", http.StatusSeeOther)
+		return
+	}
+
+	if !user.TwoFactorEnabled() || !totp.Validate(normalizeCode(r.Form.Get("code")), user.TOTPSecret) {
+		repo.App.Session.Put(r.Context(), "error", "Invalid code")
+		http.Redirect(w, r, "/admin/two-factor", http.StatusSeeOther)
+		return
+	}
+
+	err = repo.DB.DisableTwoFactor(user.ID)
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	repo.audit(r, models.AuditDisableTwoFactor, models.EntityUser, user.ID, nil, nil)
+
+	repo.App.Session.Put(r.Context(), "warning", "Two-factor authentication is off")
+	http.Redirect(w, r, "/admin/two-factor", http.StatusSeeOther)
+}
+
+// AdminPostResetTwoFactor turns off a user's two-factor authentication, so users that lost their devices and recovery
+// codes can log in with their passwords and set it up again
+func (repo *Repository) AdminPostResetTwoFactor(w http.ResponseWriter, r *http.Request) {
+	exploded := strings.Split(r.RequestURI, "/")
+	id, err := strconv.Atoi(exploded[3])
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	user, err := repo.DB.GetUserById(id)
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	err = repo.DB.DisableTwoFactor(id)
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	repo.audit(r, models.AuditDisableTwoFactor, models.EntityUser, id, nil, nil)
+
+	repo.App.Session.Put(r.Context(), "warning", fmt.Sprintf("Two-factor authentication of %s %s is off", user.FirstName, user.LastName))
+	http.Redirect(w, r, fmt.Sprintf("/admin/users/%d/show", id), http.StatusSeeOther)
+}
+
+// AdminWebhooks shows all webhook endpoints in admin dashboard
+func (repo *Repository) AdminWebhooks(w http.ResponseWriter, r *http.Request) {
+	endpoints, err := repo.DB.AllWebhookEndpoints()
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["endpoints"] = endpoints
+
+	utils.Template(w, r, "admin-webhooks.page.gohtml", &models.TemplateData{
+		Data: data,
+	})
+}
+
+// AdminShowWebhook shows a webhook endpoint and its delivery log, id 0 shows an empty form for a new endpoint
+func (repo *Repository) AdminShowWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	endpoint := models.WebhookEndpoint{Active: 1}
+	var deliveries []models.WebhookDelivery
+
+	if id > 0 {
+		endpoint, err = repo.DB.GetWebhookEndpointById(id)
+
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+
+		deliveries, err = repo.DB.WebhookDeliveriesForEndpoint(id, 50)
+
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+	}
+
+	data := make(map[string]interface{})
+	data["endpoint"] = endpoint
+	data["deliveries"] = deliveries
+	data["events"] = models.EventNames
+
+	utils.Template(w, r, "admin-webhook-detail.page.gohtml", &models.TemplateData{
+		Data: data,
+		Form: forms.New(nil),
+	})
+}
+
+// AdminPostShowWebhook creates or updates a webhook endpoint according to form
+func (repo *Repository) AdminPostShowWebhook(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	exploded := strings.Split(r.RequestURI, "/")
+
+	id, err := strconv.Atoi(exploded[3])
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	endpoint := models.WebhookEndpoint{ID: id}
+	var old interface{}
+
+	if id > 0 {
+		endpoint, err = repo.DB.GetWebhookEndpointById(id)
+
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+
+		old = endpoint
+	}
+
+	endpoint.URL = r.Form.Get("url")
+	endpoint.Events = nil
+	for _, name := range models.EventNames {
+		if r.Form.Get(fmt.Sprintf("event_%s", name)) != "" {
+			endpoint.Events = append(endpoint.Events, name)
+		}
+	}
+
+	endpoint.Active = 0
+	if r.Form.Get("active") != "" {
+		endpoint.Active = 1
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("url")
+	form.IsURL("url")
+
+	if len(endpoint.Events) == 0 {
+		form.Errors.Add("events", "Choose at least one event")
+	}
+
+	if !form.Valid() {
+		data := make(map[string]interface{})
+		data["endpoint"] = endpoint
+		data["events"] = models.EventNames
+
+		utils.Template(w, r, "admin-webhook-detail.page.gohtml", &models.TemplateData{
+			Data: data,
+			Form: form,
+		})
+		return
+	}
+
+	// a new secret is generated for new endpoints, or when the admin asks for it
+	if endpoint.Secret == "" || r.Form.Get("rotate_secret") != "" {
+		endpoint.Secret, err = webhooks.NewSecret()
+
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+	}
+
+	action := models.AuditUpdate
+
+	if id > 0 {
+		err = repo.DB.UpdateWebhookEndpoint(endpoint)
+	} else {
+		action = models.AuditCreate
+		id, err = repo.DB.InsertWebhookEndpoint(endpoint)
+		endpoint.ID = id
+	}
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	repo.audit(r, action, models.EntityWebhook, id, old, endpoint)
+
+	repo.App.Session.Put(r.Context(), "flash", "Webhook saved")
+	http.Redirect(w, r, fmt.Sprintf("/admin/webhooks/%d/show", id), http.StatusSeeOther)
+}
+
+// AdminPostDeleteWebhook deletes a webhook endpoint from DB
+func (repo *Repository) AdminPostDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	exploded := strings.Split(r.RequestURI, "/")
+	id, err := strconv.Atoi(exploded[3])
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	endpoint, err := repo.DB.GetWebhookEndpointById(id)
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	err = repo.DB.DeleteWebhookEndpoint(id)
 
-	nextMonth := next.Format("01")
-	nextMonthYear := next.Format("2006")
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
 
-	previousMonth := previous.Format("01")
-	previousMonthYear := previous.Format("2006")
+	repo.audit(r, models.AuditDelete, models.EntityWebhook, id, endpoint, nil)
 
-	stringMap := make(map[string]string)
-	stringMap["next_month"] = nextMonth
-	stringMap["next_month_year"] = nextMonthYear
-	stringMap["previous_month"] = previousMonth
-	stringMap["previous_month_year"] = previousMonthYear
+	repo.App.Session.Put(r.Context(), "warning", "Webhook deleted successfully")
+	http.Redirect(w, r, "/admin/webhooks", http.StatusSeeOther)
+}
 
-	stringMap["this_month"] = now.Format("01")
-	stringMap["this_year"] = now.Format("2006")
+// RoomICalFeed serves a room's reservations and blocks as an iCalendar feed, the feed is found by its secret token
+func (repo *Repository) RoomICalFeed(w http.ResponseWriter, r *http.Request) {
+	roomID, err := strconv.Atoi(chi.URLParam(r, "id"))
 
-	currentYear, currentMonth, _ := now.Date()
-	currentLocation := now.Location()
-	firstOfMonth := time.Date(currentYear, currentMonth, 1, 0, 0, 0, 0, currentLocation)
-	// here we add 1 month and reduce 1 day so we got the last day of the month
-	lastOfMonth := firstOfMonth.AddDate(0, 1, -1)
+	if err != nil {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
 
-	intMap := make(map[string]int)
-	intMap["days_in_month"] = lastOfMonth.Day()
+	feed, err := repo.DB.GetICalFeedByToken(r.URL.Query().Get("token"))
 
-	rooms, err := repo.DB.AllRooms()
+	if err != nil || feed.RoomID != roomID {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+
+	// calendar clients don't need old stays, so we only send the ones ended in last year
+	restrictions, err := repo.DB.GetRestrictionsForRoomFeed(roomID, time.Now().AddDate(-1, 0, 0))
 
 	if err != nil {
 		helpers.ServerError(w, err)
 		return
 	}
 
-	data["rooms"] = rooms
+	cal := ical.Calendar{
+		ProdID: "-//Fort Smythe//Bookings//EN",
+		Name:   feed.Room.RoomName,
+		Events: feedEvents(restrictions, feed.IncludeGuests == 1),
+	}
 
-	// first we range over rooms
-	for _, x := range rooms {
-		reservationMap := make(map[string]int)
-		blockMap := make(map[string]int)
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=room-%d.ics", roomID))
 
-		// then we range over for each room and set their reservation and block values to 0
-		for d := firstOfMonth; d.Before(lastOfMonth); d = d.AddDate(0, 0, 1) {
-			reservationMap[d.Format("2006-01-2")] = 0
-			blockMap[d.Format("2006-01-2")] = 0
+	err = cal.Encode(w)
 
-		}
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+}
 
-		// than we check for given room's restrictions and put them in a slice
-		restrictions, err := repo.DB.GetRestrictionsForRoomByDate(x.ID, firstOfMonth, lastOfMonth)
+// feedEvents turns room restrictions into calendar events, guest names are only shown in staff feeds
+func feedEvents(restrictions []models.RoomRestriction, includeGuests bool) []ical.Event {
+	var events []ical.Event
+	now := time.Now()
 
-		if err != nil {
-			helpers.ServerError(w, err)
-			return
+	for _, x := range restrictions {
+		// uid of a reservation doesn't depend on its restriction, so calendar clients keep the same event when
+		// the reservation's dates change
+		e := ical.Event{
+			Start:        x.StartDate,
+			End:          x.EndDate,
+			Stamp:        now,
+			LastModified: x.UpdatedAt,
+			Summary:      "Not available",
 		}
 
-		// then we range over the slice and update restrictions according to if the restriction is a reservation or a block
-		for _, res := range restrictions {
-			if res.ReservationID > 0 {
-				for d := res.StartDate; d.Before(res.EndDate); d = d.AddDate(0, 0, 1) {
-					reservationMap[d.Format("2006-01-2")] = res.ReservationID
-				}
-			} else {
-				blockMap[res.StartDate.Format("2006-01-2")] = res.ID
+		if x.ReservationID > 0 {
+			e.UID = fmt.Sprintf("reservation-%d@fort-smythe", x.ReservationID)
+			if !x.Reservation.UpdatedAt.IsZero() {
+				e.LastModified = x.Reservation.UpdatedAt
+			}
+			if includeGuests {
+				e.Summary = fmt.Sprintf("Reservation: %s %s", x.Reservation.FirstName, x.Reservation.LastName)
+			}
+		} else {
+			e.UID = fmt.Sprintf("block-%d@fort-smythe", x.ID)
+			if includeGuests {
+				e.Summary = x.Restriction.RestrictionName
 			}
 		}
 
-		data[fmt.Sprintf("reservation_map_%d", x.ID)] = reservationMap
-		data[fmt.Sprintf("block_map_%d", x.ID)] = blockMap
+		events = append(events, e)
+	}
 
-		repo.App.Session.Put(r.Context(), fmt.Sprintf("block_map_%d", x.ID), blockMap)
+	return events
+}
+
+// AdminICalFeeds shows all calendar feeds and lets admins create new ones
+func (repo *Repository) AdminICalFeeds(w http.ResponseWriter, r *http.Request) {
+	feeds, err := repo.DB.AllICalFeeds()
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
 	}
 
-	utils.Template(w, r, "admin-reservations-calendar.page.gohtml", &models.TemplateData{
+	rooms, err := repo.DB.AllRooms()
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	stringMap := make(map[string]string)
+	stringMap["base_url"] = repo.siteURL()
+
+	data := make(map[string]interface{})
+	data["feeds"] = feeds
+	data["rooms"] = rooms
+
+	utils.Template(w, r, "admin-ical-feeds.page.gohtml", &models.TemplateData{
 		StringMap: stringMap,
 		Data:      data,
-		IntMap:    intMap,
+		Form:      forms.New(nil),
 	})
 }
 
-// AdminPostReservationsCalendar handles the changes that made on calendar page
-func (repo *Repository) AdminPostReservationsCalendar(w http.ResponseWriter, r *http.Request) {
+// AdminPostICalFeeds creates a new calendar feed with a new secret token for a room
+func (repo *Repository) AdminPostICalFeeds(w http.ResponseWriter, r *http.Request) {
 	err := r.ParseForm()
 
 	if err != nil {
@@ -905,8 +4712,74 @@ func (repo *Repository) AdminPostReservationsCalendar(w http.ResponseWriter, r *
 		return
 	}
 
-	year, _ := strconv.Atoi(r.Form.Get("y"))
-	month, _ := strconv.Atoi(r.Form.Get("m"))
+	roomID, err := strconv.Atoi(r.Form.Get("room_id"))
+
+	if err != nil {
+		repo.App.Session.Put(r.Context(), "error", "invalid room id")
+		http.Redirect(w, r, "/admin/ical-feeds", http.StatusSeeOther)
+		return
+	}
+
+	token, err := helpers.RandomToken()
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	feed := models.ICalFeed{
+		RoomID: roomID,
+		Token:  token,
+	}
+
+	if r.Form.Get("include_guests") != "" {
+		feed.IncludeGuests = 1
+	}
+
+	feed.ID, err = repo.DB.InsertICalFeed(feed)
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	repo.audit(r, models.AuditCreate, models.EntityICalFeed, feed.ID, nil, feed)
+
+	repo.App.Session.Put(r.Context(), "flash", "Calendar feed created")
+	http.Redirect(w, r, "/admin/ical-feeds", http.StatusSeeOther)
+}
+
+// AdminPostDeleteICalFeed deletes a calendar feed, so its URL stops working
+func (repo *Repository) AdminPostDeleteICalFeed(w http.ResponseWriter, r *http.Request) {
+	exploded := strings.Split(r.RequestURI, "/")
+	id, err := strconv.Atoi(exploded[3])
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	err = repo.DB.DeleteICalFeed(id)
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	repo.audit(r, models.AuditDelete, models.EntityICalFeed, id, nil, nil)
+
+	repo.App.Session.Put(r.Context(), "warning", "Calendar feed deleted successfully")
+	http.Redirect(w, r, "/admin/ical-feeds", http.StatusSeeOther)
+}
+
+// AdminICalImports shows all calendar imports with their last sync results
+func (repo *Repository) AdminICalImports(w http.ResponseWriter, r *http.Request) {
+	imports, err := repo.DB.AllICalImports()
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
 
 	rooms, err := repo.DB.AllRooms()
 
@@ -915,49 +4788,148 @@ func (repo *Repository) AdminPostReservationsCalendar(w http.ResponseWriter, r *
 		return
 	}
 
-	form := forms.New(r.PostForm)
+	data := make(map[string]interface{})
+	data["imports"] = imports
+	data["rooms"] = rooms
 
-	// this handles removed blocks
-	for _, x := range rooms {
-		curMap := repo.App.Session.Get(r.Context(), fmt.Sprintf("block_map_%d", x.ID)).(map[string]int)
-		for name, value := range curMap {
-			if val, ok := curMap[name]; ok {
-				if val > 0 {
-					if !form.Has(fmt.Sprintf("remove_block_%d_%s", x.ID, name)) {
-						err := repo.DB.RemoveBlockForRoom(value)
-						if err != nil {
-							helpers.ServerError(w, err)
-							return
-						}
-					}
-				}
-			}
-		}
+	utils.Template(w, r, "admin-ical-imports.page.gohtml", &models.TemplateData{
+		Data: data,
+		Form: forms.New(nil),
+	})
+}
+
+// AdminPostICalImports creates a new calendar import from a URL or an uploaded file, and syncs it immediately
+func (repo *Repository) AdminPostICalImports(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseMultipartForm(maxUploadSize)
+
+	if err != nil && err != http.ErrNotMultipart {
+		helpers.ServerError(w, err)
+		return
 	}
 
-	// this handles new blocks
-	for name := range r.PostForm {
-		if strings.HasPrefix(name, "add_block") {
-			exploded := strings.Split(name, "_")
-			roomID, _ := strconv.Atoi(exploded[2])
-			// insert a new restriction
+	roomID, err := strconv.Atoi(r.Form.Get("room_id"))
 
-			date, err := time.Parse("2006-01-2", exploded[len(exploded)-1])
+	if err != nil {
+		repo.App.Session.Put(r.Context(), "error", "invalid room id")
+		http.Redirect(w, r, "/admin/ical-imports", http.StatusSeeOther)
+		return
+	}
 
-			if err != nil {
-				helpers.ServerError(w, err)
-				return
-			}
+	i := models.ICalImport{
+		RoomID: roomID,
+		Name:   r.Form.Get("name"),
+		URL:    strings.TrimSpace(r.Form.Get("url")),
+	}
 
-			err = repo.DB.InsertBlockForRoom(roomID, date)
+	if i.URL == "" {
+		file, _, err := r.FormFile("file")
 
-			if err != nil {
-				helpers.ServerError(w, err)
-				return
-			}
+		if err != nil {
+			repo.App.Session.Put(r.Context(), "error", "Enter a feed URL or choose a file")
+			http.Redirect(w, r, "/admin/ical-imports", http.StatusSeeOther)
+			return
+		}
+
+		defer file.Close()
+
+		content, err := io.ReadAll(file)
+
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+
+		i.Content = string(content)
+	} else {
+		form := forms.New(r.Form)
+		form.IsURL("url")
+
+		if !form.Valid() {
+			repo.App.Session.Put(r.Context(), "error", "Invalid feed URL")
+			http.Redirect(w, r, "/admin/ical-imports", http.StatusSeeOther)
+			return
 		}
 	}
 
-	repo.App.Session.Put(r.Context(), "flash", "Chages saved")
-	http.Redirect(w, r, fmt.Sprintf("/admin/reservations-calendar?y=%d&m=%d", year, month), http.StatusSeeOther)
+	i.ID, err = repo.DB.InsertICalImport(i)
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	repo.audit(r, models.AuditCreate, models.EntityICalImport, i.ID, nil, i)
+
+	repo.syncICalImport(w, r, i)
+}
+
+// AdminPostSyncICalImport syncs a calendar import without waiting for the periodic sync
+func (repo *Repository) AdminPostSyncICalImport(w http.ResponseWriter, r *http.Request) {
+	exploded := strings.Split(r.RequestURI, "/")
+	id, err := strconv.Atoi(exploded[3])
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	i, err := repo.DB.GetICalImportById(id)
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	repo.syncICalImport(w, r, i)
+}
+
+// syncICalImport syncs the import and redirects back to the imports page with the result
+func (repo *Repository) syncICalImport(w http.ResponseWriter, r *http.Request, i models.ICalImport) {
+	result, err := icalsync.NewSyncer(repo.App, repo.DB).Sync(i)
+
+	if err == nil {
+		repo.audit(r, models.AuditSync, models.EntityICalImport, i.ID, nil, result)
+	}
+
+	switch {
+	case err != nil:
+		repo.App.Session.Put(r.Context(), "error", fmt.Sprintf("Sync failed: %s", err))
+	case len(result.Conflicts) > 0:
+		repo.App.Session.Put(r.Context(), "warning", fmt.Sprintf("Synced with %d conflicts", len(result.Conflicts)))
+	default:
+		repo.App.Session.Put(r.Context(), "flash", fmt.Sprintf("Synced: %d created, %d updated, %d removed",
+			result.Created, result.Updated, result.Removed))
+	}
+
+	http.Redirect(w, r, "/admin/ical-imports", http.StatusSeeOther)
+}
+
+// AdminPostDeleteICalImport deletes a calendar import with its blocks
+func (repo *Repository) AdminPostDeleteICalImport(w http.ResponseWriter, r *http.Request) {
+	exploded := strings.Split(r.RequestURI, "/")
+	id, err := strconv.Atoi(exploded[3])
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	i, err := repo.DB.GetICalImportById(id)
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	err = repo.DB.DeleteICalImport(id)
+
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	repo.audit(r, models.AuditDelete, models.EntityICalImport, id, i, nil)
+
+	repo.App.Session.Put(r.Context(), "warning", "Calendar import deleted successfully")
+	http.Redirect(w, r, "/admin/ical-imports", http.StatusSeeOther)
 }
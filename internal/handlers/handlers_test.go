@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"net/http/httptest"
@@ -12,7 +13,11 @@ import (
 	"testing"
 	"time"
 
+	"github.com/burakkarasel/bookings/internal/helpers"
 	"github.com/burakkarasel/bookings/internal/models"
+	"github.com/burakkarasel/bookings/internal/repository/dbrepo"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
 )
 
 // theTests holds our test cases
@@ -107,6 +112,210 @@ var theTests = []struct {
 		method:             "GET",
 		expectedStatusCode: http.StatusOK,
 	},
+	{
+		name:               "webhooks",
+		url:                "/admin/webhooks",
+		method:             "GET",
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:               "new webhook",
+		url:                "/admin/webhooks/0/show",
+		method:             "GET",
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:               "show webhook",
+		url:                "/admin/webhooks/1/show",
+		method:             "GET",
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:               "show non existent webhook",
+		url:                "/admin/webhooks/3/show",
+		method:             "GET",
+		expectedStatusCode: http.StatusInternalServerError,
+	},
+	{
+		name:               "ical feeds",
+		url:                "/admin/ical-feeds",
+		method:             "GET",
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:               "ical imports",
+		url:                "/admin/ical-imports",
+		method:             "GET",
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:               "import reservations",
+		url:                "/admin/reservations-import",
+		method:             "GET",
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:               "create reservation",
+		url:                "/admin/reservations-create",
+		method:             "GET",
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:               "rooms",
+		url:                "/admin/rooms",
+		method:             "GET",
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:               "new room",
+		url:                "/admin/rooms/0/show",
+		method:             "GET",
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:               "room detail",
+		url:                "/admin/rooms/2/show",
+		method:             "GET",
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:               "unknown room detail",
+		url:                "/admin/rooms/3/show",
+		method:             "GET",
+		expectedStatusCode: http.StatusInternalServerError,
+	},
+	{
+		name:               "move room",
+		url:                "/admin/move-rooms/1/do?dir=up",
+		method:             "GET",
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:               "users",
+		url:                "/admin/users",
+		method:             "GET",
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:               "invite user",
+		url:                "/admin/users/0/show",
+		method:             "GET",
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:               "user detail",
+		url:                "/admin/users/2/show",
+		method:             "GET",
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:               "unknown user",
+		url:                "/admin/users/3/show",
+		method:             "GET",
+		expectedStatusCode: http.StatusInternalServerError,
+	},
+	{
+		name:               "set password",
+		url:                "/user/set-password?token=valid-token",
+		method:             "GET",
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:               "set password invalid token",
+		url:                "/user/set-password?token=expired",
+		method:             "GET",
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:               "audit log",
+		url:                "/admin/audit-log",
+		method:             "GET",
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:               "reservations timeline",
+		url:                "/admin/reservations-timeline",
+		method:             "GET",
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:               "admin-guests",
+		url:                "/admin/guests",
+		method:             "GET",
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:               "admin-guests-search",
+		url:                "/admin/guests?q=smith&tag=VIP",
+		method:             "GET",
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:               "admin-show-guest",
+		url:                "/admin/guests/1/show",
+		method:             "GET",
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:               "admin-show-guest-missing",
+		url:                "/admin/guests/9/show",
+		method:             "GET",
+		expectedStatusCode: http.StatusInternalServerError,
+	},
+	{
+		name:               "admin-daily-report",
+		url:                "/admin/reports-daily",
+		method:             "GET",
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:               "admin-daily-report-date",
+		url:                "/admin/reports-daily?date=2022-08-01",
+		method:             "GET",
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:               "admin-search",
+		url:                "/admin/search?q=smith",
+		method:             "GET",
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:               "admin-search-short",
+		url:                "/admin/search?q=s",
+		method:             "GET",
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:               "admin-search-error",
+		url:                "/admin/search?q=fail",
+		method:             "GET",
+		expectedStatusCode: http.StatusInternalServerError,
+	},
+	{
+		name:               "forgot-password",
+		url:                "/user/forgot-password",
+		method:             "GET",
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:               "login-two-factor",
+		url:                "/user/login/two-factor",
+		method:             "GET",
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:               "account",
+		url:                "/admin/account",
+		method:             "GET",
+		expectedStatusCode: http.StatusOK,
+	},
+	{
+		name:               "confirm-email",
+		url:                "/user/confirm-email?token=email-token",
+		method:             "GET",
+		expectedStatusCode: http.StatusOK,
+	},
 }
 
 // TestGetHandlers is our test func for handlers, it tests only our render handlers
@@ -836,6 +1045,99 @@ func TestRepository_AdminPostShowReservationDetail(t *testing.T) {
 	}
 }
 
+// TestRepository_AdminPostShowReservationDetailDates tests changing the dates and the room of a reservation
+func TestRepository_AdminPostShowReservationDetailDates(t *testing.T) {
+	posted := func(changes map[string]string) url.Values {
+		values := url.Values{
+			"first_name":   {"John"},
+			"last_name":    {"Smith"},
+			"email":        {"john@smith.com"},
+			"room_id":      {"1"},
+			"start_date":   {"2050-01-01"},
+			"end_date":     {"2050-01-03"},
+			"notify_guest": {"1"},
+		}
+
+		for k, v := range changes {
+			values.Set(k, v)
+		}
+
+		return values
+	}
+
+	var tests = []struct {
+		name               string
+		postedData         url.Values
+		expectedStatusCode int
+		expectedLocation   string
+		expectedBody       string
+	}{
+		{
+			name:               "valid move",
+			postedData:         posted(nil),
+			expectedStatusCode: http.StatusSeeOther,
+			expectedLocation:   "/admin/reservations-all",
+		},
+		{
+			name:               "invalid date",
+			postedData:         posted(map[string]string{"start_date": "01/01/2050"}),
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "Invalid date",
+		},
+		{
+			name:               "departure before arrival",
+			postedData:         posted(map[string]string{"start_date": "2050-01-05"}),
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "Departure must be after arrival",
+		},
+		{
+			name:               "unknown room",
+			postedData:         posted(map[string]string{"room_id": "3"}),
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "Unknown room",
+		},
+		{
+			name:               "room is not available",
+			postedData:         posted(map[string]string{"room_id": "2", "start_date": "2030-01-02", "end_date": "2030-01-04"}),
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "The room is not available for these dates",
+		},
+		{
+			name:               "update error",
+			postedData:         posted(map[string]string{"last_name": "Fail"}),
+			expectedStatusCode: http.StatusSeeOther,
+			expectedLocation:   "/admin/reservations/all/1/show",
+		},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest("POST", "/admin/reservations/all/1/show", strings.NewReader(tt.postedData.Encode()))
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+		req.RequestURI = "/admin/reservations/all/1/show"
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminPostShowReservationDetail)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != tt.expectedStatusCode {
+			t.Errorf("for %s: got status code %d, wanted %d", tt.name, rr.Code, tt.expectedStatusCode)
+		}
+
+		if tt.expectedLocation != "" {
+			actualLocation, _ := rr.Result().Location()
+			if actualLocation.String() != tt.expectedLocation {
+				t.Errorf("for %s: got location %s, wanted %s", tt.name, actualLocation.String(), tt.expectedLocation)
+			}
+		}
+
+		if tt.expectedBody != "" && !strings.Contains(rr.Body.String(), tt.expectedBody) {
+			t.Errorf("for %s: expected body to contain %s", tt.name, tt.expectedBody)
+		}
+	}
+}
+
 // TestRepository_AdminProcessedReservation tests AdminProcessedReservation handler
 func TestRepository_AdminProcessedReservation(t *testing.T) {
 	var tests = []struct {
@@ -939,3 +1241,2390 @@ func TestRepository_AdminDeleteResevation(t *testing.T) {
 		}
 	}
 }
+
+// TestRepository_AdminPostShowWebhook tests AdminPostShowWebhook handler
+func TestRepository_AdminPostShowWebhook(t *testing.T) {
+	var tests = []struct {
+		name               string
+		url                string
+		postedData         url.Values
+		expectedLocation   string
+		expectedStatusCode int
+	}{
+		{
+			name: "valid new",
+			url:  "/admin/webhooks/0",
+			postedData: url.Values{
+				"url":                       {"https://here.com/hook"},
+				"event_reservation.created": {"1"},
+				"active":                    {"1"},
+			},
+			expectedLocation:   "/admin/webhooks/1/show",
+			expectedStatusCode: http.StatusSeeOther,
+		},
+		{
+			name: "valid update",
+			url:  "/admin/webhooks/2",
+			postedData: url.Values{
+				"url":               {"https://here.com/hook"},
+				"event_block.added": {"1"},
+				"rotate_secret":     {"1"},
+			},
+			expectedLocation:   "/admin/webhooks/2/show",
+			expectedStatusCode: http.StatusSeeOther,
+		},
+		{
+			name: "invalid url",
+			url:  "/admin/webhooks/0",
+			postedData: url.Values{
+				"url":                       {"here"},
+				"event_reservation.created": {"1"},
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name: "no events",
+			url:  "/admin/webhooks/0",
+			postedData: url.Values{
+				"url": {"https://here.com/hook"},
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name: "non existent webhook",
+			url:  "/admin/webhooks/3",
+			postedData: url.Values{
+				"url":                       {"https://here.com/hook"},
+				"event_reservation.created": {"1"},
+			},
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+		{
+			name: "insert error",
+			url:  "/admin/webhooks/0",
+			postedData: url.Values{
+				"url":                       {"http://fail.com"},
+				"event_reservation.created": {"1"},
+			},
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+		{
+			name:               "invalid id",
+			url:                "/admin/webhooks/x",
+			postedData:         url.Values{},
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest("POST", tt.url, strings.NewReader(tt.postedData.Encode()))
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+		req.RequestURI = tt.url
+
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminPostShowWebhook)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != tt.expectedStatusCode {
+			t.Errorf("for %s: got status code %d, wanted %d", tt.name, rr.Code, tt.expectedStatusCode)
+		}
+
+		if tt.expectedLocation != "" {
+			actualLocation, _ := rr.Result().Location()
+			if actualLocation.String() != tt.expectedLocation {
+				t.Errorf("for %s: got location %s, wanted %s", tt.name, actualLocation.String(), tt.expectedLocation)
+			}
+		}
+	}
+}
+
+// TestRepository_AdminPostDeleteWebhook tests AdminPostDeleteWebhook handler
+func TestRepository_AdminPostDeleteWebhook(t *testing.T) {
+	req, _ := http.NewRequest("POST", "/admin/delete-webhooks/1", nil)
+	ctx := getCtx(req)
+	req = req.WithContext(ctx)
+	req.RequestURI = "/admin/delete-webhooks/1"
+
+	rr := httptest.NewRecorder()
+
+	handler := http.HandlerFunc(Repo.AdminPostDeleteWebhook)
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusSeeOther {
+		t.Errorf("AdminPostDeleteWebhook handler returned wrong status code: got %d, wanted %d", rr.Code, http.StatusSeeOther)
+	}
+
+	actualLocation, _ := rr.Result().Location()
+	if actualLocation.String() != "/admin/webhooks" {
+		t.Errorf("AdminPostDeleteWebhook handler redirected to %s, wanted /admin/webhooks", actualLocation.String())
+	}
+}
+
+// TestRepository_RoomICalFeed tests RoomICalFeed handler
+func TestRepository_RoomICalFeed(t *testing.T) {
+	var tests = []struct {
+		name               string
+		url                string
+		expectedStatusCode int
+		expectedBody       string
+		unexpectedBody     string
+	}{
+		{
+			name:               "public feed",
+			url:                "/ical/rooms/1.ics?token=public",
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "UID:reservation-1@fort-smythe",
+			unexpectedBody:     "Smith",
+		},
+		{
+			name:               "staff feed",
+			url:                "/ical/rooms/1.ics?token=staff",
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "SUMMARY:Reservation: John Smith",
+		},
+		{
+			name:               "invalid token",
+			url:                "/ical/rooms/1.ics?token=invalid",
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			name:               "token of another room",
+			url:                "/ical/rooms/2.ics?token=public",
+			expectedStatusCode: http.StatusNotFound,
+		},
+	}
+
+	routes := getRoutes()
+	ts := httptest.NewTLSServer(routes)
+	defer ts.Close()
+
+	for _, tt := range tests {
+		resp, err := ts.Client().Get(ts.URL + tt.url)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != tt.expectedStatusCode {
+			t.Errorf("for %s: got status code %d, wanted %d", tt.name, resp.StatusCode, tt.expectedStatusCode)
+		}
+
+		if tt.expectedBody != "" && !strings.Contains(string(body), tt.expectedBody) {
+			t.Errorf("for %s: expected body to contain %s", tt.name, tt.expectedBody)
+		}
+
+		if tt.unexpectedBody != "" && strings.Contains(string(body), tt.unexpectedBody) {
+			t.Errorf("for %s: body shouldn't contain %s", tt.name, tt.unexpectedBody)
+		}
+	}
+}
+
+// TestRepository_AdminPostICalFeeds tests AdminPostICalFeeds handler
+func TestRepository_AdminPostICalFeeds(t *testing.T) {
+	var tests = []struct {
+		name               string
+		postedData         url.Values
+		expectedStatusCode int
+	}{
+		{
+			name:               "valid",
+			postedData:         url.Values{"room_id": {"1"}, "include_guests": {"1"}},
+			expectedStatusCode: http.StatusSeeOther,
+		},
+		{
+			name:               "invalid room id",
+			postedData:         url.Values{"room_id": {"x"}},
+			expectedStatusCode: http.StatusSeeOther,
+		},
+		{
+			name:               "insert error",
+			postedData:         url.Values{"room_id": {"3"}},
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest("POST", "/admin/ical-feeds", strings.NewReader(tt.postedData.Encode()))
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminPostICalFeeds)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != tt.expectedStatusCode {
+			t.Errorf("for %s: got status code %d, wanted %d", tt.name, rr.Code, tt.expectedStatusCode)
+		}
+	}
+}
+
+// TestRepository_AdminPostDeleteICalFeed tests AdminPostDeleteICalFeed handler
+func TestRepository_AdminPostDeleteICalFeed(t *testing.T) {
+	req, _ := http.NewRequest("POST", "/admin/delete-ical-feeds/1", nil)
+	ctx := getCtx(req)
+	req = req.WithContext(ctx)
+	req.RequestURI = "/admin/delete-ical-feeds/1"
+
+	rr := httptest.NewRecorder()
+
+	handler := http.HandlerFunc(Repo.AdminPostDeleteICalFeed)
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusSeeOther {
+		t.Errorf("AdminPostDeleteICalFeed handler returned wrong status code: got %d, wanted %d", rr.Code, http.StatusSeeOther)
+	}
+
+	actualLocation, _ := rr.Result().Location()
+	if actualLocation.String() != "/admin/ical-feeds" {
+		t.Errorf("AdminPostDeleteICalFeed handler redirected to %s, wanted /admin/ical-feeds", actualLocation.String())
+	}
+}
+
+// TestRepository_AdminPostICalImports tests AdminPostICalImports handler with a feed served by a test server
+func TestRepository_AdminPostICalImports(t *testing.T) {
+	feed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:1@external\r\nDTSTART;VALUE=DATE:20220801\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"))
+	}))
+	defer feed.Close()
+
+	var tests = []struct {
+		name               string
+		postedData         url.Values
+		expectedStatusCode int
+		expectedLocation   string
+	}{
+		{
+			name:               "valid url",
+			postedData:         url.Values{"room_id": {"1"}, "name": {"Airbnb"}, "url": {feed.URL}},
+			expectedStatusCode: http.StatusSeeOther,
+			expectedLocation:   "/admin/ical-imports",
+		},
+		{
+			name:               "invalid url",
+			postedData:         url.Values{"room_id": {"1"}, "url": {"feed"}},
+			expectedStatusCode: http.StatusSeeOther,
+			expectedLocation:   "/admin/ical-imports",
+		},
+		{
+			name:               "missing url and file",
+			postedData:         url.Values{"room_id": {"1"}},
+			expectedStatusCode: http.StatusSeeOther,
+			expectedLocation:   "/admin/ical-imports",
+		},
+		{
+			name:               "invalid room id",
+			postedData:         url.Values{"room_id": {"x"}},
+			expectedStatusCode: http.StatusSeeOther,
+			expectedLocation:   "/admin/ical-imports",
+		},
+		{
+			name:               "insert error",
+			postedData:         url.Values{"room_id": {"3"}, "url": {feed.URL}},
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest("POST", "/admin/ical-imports", strings.NewReader(tt.postedData.Encode()))
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminPostICalImports)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != tt.expectedStatusCode {
+			t.Errorf("for %s: got status code %d, wanted %d", tt.name, rr.Code, tt.expectedStatusCode)
+		}
+
+		if tt.expectedLocation != "" {
+			actualLocation, _ := rr.Result().Location()
+			if actualLocation.String() != tt.expectedLocation {
+				t.Errorf("for %s: got location %s, wanted %s", tt.name, actualLocation.String(), tt.expectedLocation)
+			}
+		}
+	}
+}
+
+// TestRepository_AdminPostSyncICalImport tests AdminPostSyncICalImport handler
+func TestRepository_AdminPostSyncICalImport(t *testing.T) {
+	var tests = []struct {
+		name               string
+		url                string
+		expectedStatusCode int
+	}{
+		{
+			name:               "valid",
+			url:                "/admin/sync-ical-imports/1",
+			expectedStatusCode: http.StatusSeeOther,
+		},
+		{
+			name:               "non existent import",
+			url:                "/admin/sync-ical-imports/3",
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest("POST", tt.url, nil)
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+		req.RequestURI = tt.url
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminPostSyncICalImport)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != tt.expectedStatusCode {
+			t.Errorf("for %s: got status code %d, wanted %d", tt.name, rr.Code, tt.expectedStatusCode)
+		}
+	}
+}
+
+// TestRepository_AdminPostDeleteICalImport tests AdminPostDeleteICalImport handler
+func TestRepository_AdminPostDeleteICalImport(t *testing.T) {
+	req, _ := http.NewRequest("POST", "/admin/delete-ical-imports/1", nil)
+	ctx := getCtx(req)
+	req = req.WithContext(ctx)
+	req.RequestURI = "/admin/delete-ical-imports/1"
+
+	rr := httptest.NewRecorder()
+
+	handler := http.HandlerFunc(Repo.AdminPostDeleteICalImport)
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusSeeOther {
+		t.Errorf("AdminPostDeleteICalImport handler returned wrong status code: got %d, wanted %d", rr.Code, http.StatusSeeOther)
+	}
+
+	actualLocation, _ := rr.Result().Location()
+	if actualLocation.String() != "/admin/ical-imports" {
+		t.Errorf("AdminPostDeleteICalImport handler redirected to %s, wanted /admin/ical-imports", actualLocation.String())
+	}
+}
+
+// TestRepository_AdminExportReservations tests AdminExportReservations handler
+func TestRepository_AdminExportReservations(t *testing.T) {
+	var tests = []struct {
+		name               string
+		url                string
+		expectedStatusCode int
+		expectedBody       string
+		unexpectedBody     string
+	}{
+		{
+			name:               "all columns",
+			url:                "/admin/reservations-export",
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "ID,First Name,Last Name,Email,Phone,Room,Arrival,Departure,Nights,Processed,Booked At,Notes\r\n",
+		},
+		{
+			name:               "chosen columns",
+			url:                "/admin/reservations-export?columns=last_name&columns=phone&status=new",
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "Last Name,Phone\r\nSmith,'=1+2\r\n",
+			unexpectedBody:     "john@smith.com",
+		},
+		{
+			name:               "invalid date",
+			url:                "/admin/reservations-export?start=invalid",
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "invalid status",
+			url:                "/admin/reservations-export?status=invalid",
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "database error",
+			url:                "/admin/reservations-export?room_id=3",
+			expectedStatusCode: http.StatusOK,
+			unexpectedBody:     "Smith",
+		},
+	}
+
+	routes := getRoutes()
+	ts := httptest.NewTLSServer(routes)
+	defer ts.Close()
+
+	for _, tt := range tests {
+		resp, err := ts.Client().Get(ts.URL + tt.url)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != tt.expectedStatusCode {
+			t.Errorf("for %s: got status code %d, wanted %d", tt.name, resp.StatusCode, tt.expectedStatusCode)
+		}
+
+		if tt.expectedStatusCode == http.StatusOK && resp.Header.Get("Content-Type") != "text/csv; charset=utf-8" {
+			t.Errorf("for %s: got content type %s", tt.name, resp.Header.Get("Content-Type"))
+		}
+
+		if tt.expectedBody != "" && !strings.Contains(string(body), tt.expectedBody) {
+			t.Errorf("for %s: expected body to contain %q, got %q", tt.name, tt.expectedBody, string(body))
+		}
+
+		if tt.unexpectedBody != "" && strings.Contains(string(body), tt.unexpectedBody) {
+			t.Errorf("for %s: body shouldn't contain %s", tt.name, tt.unexpectedBody)
+		}
+	}
+}
+
+// TestRepository_AdminPostImportReservations tests AdminPostImportReservations handler, the testing repo has no rooms
+// so every row is invalid
+func TestRepository_AdminPostImportReservations(t *testing.T) {
+	var tests = []struct {
+		name               string
+		postedData         url.Values
+		expectedStatusCode int
+		expectedLocation   string
+		expectedBody       string
+	}{
+		{
+			name:               "preview",
+			postedData:         url.Values{"action": {"preview"}, "content": {"type,room,start_date,end_date\nblock,1,2022-08-01,2022-08-02\n"}},
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "unknown room",
+		},
+		{
+			name:               "import invalid rows",
+			postedData:         url.Values{"action": {"import"}, "content": {"type,room,start_date,end_date\nblock,1,2022-08-01,2022-08-02\n"}},
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "Fix the rows with errors",
+		},
+		{
+			name:               "missing column",
+			postedData:         url.Values{"action": {"preview"}, "content": {"type,room\nblock,1\n"}},
+			expectedStatusCode: http.StatusSeeOther,
+			expectedLocation:   "/admin/reservations-import",
+		},
+		{
+			name:               "no rows",
+			postedData:         url.Values{"action": {"preview"}, "content": {"type,room,start_date,end_date\n"}},
+			expectedStatusCode: http.StatusSeeOther,
+			expectedLocation:   "/admin/reservations-import",
+		},
+		{
+			name:               "missing file",
+			postedData:         url.Values{"action": {"preview"}},
+			expectedStatusCode: http.StatusSeeOther,
+			expectedLocation:   "/admin/reservations-import",
+		},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest("POST", "/admin/reservations-import", strings.NewReader(tt.postedData.Encode()))
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminPostImportReservations)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != tt.expectedStatusCode {
+			t.Errorf("for %s: got status code %d, wanted %d", tt.name, rr.Code, tt.expectedStatusCode)
+		}
+
+		if tt.expectedLocation != "" {
+			actualLocation, _ := rr.Result().Location()
+			if actualLocation.String() != tt.expectedLocation {
+				t.Errorf("for %s: got location %s, wanted %s", tt.name, actualLocation.String(), tt.expectedLocation)
+			}
+		}
+
+		if tt.expectedBody != "" && !strings.Contains(rr.Body.String(), tt.expectedBody) {
+			t.Errorf("for %s: expected body to contain %s", tt.name, tt.expectedBody)
+		}
+	}
+}
+
+// TestRepository_AdminLiveEvents tests AdminLiveEvents handler by publishing an event while a client is listening
+func TestRepository_AdminLiveEvents(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/admin/events", nil)
+	ctx, cancel := context.WithCancel(getCtx(req))
+	req = req.WithContext(ctx)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(Repo.AdminLiveEvents)
+
+	done := make(chan bool)
+	go func() {
+		handler.ServeHTTP(rr, req)
+		done <- true
+	}()
+
+	for i := 0; app.Broker.Clients() == 0; i++ {
+		if i > 100 {
+			t.Fatal("client didn't subscribe to live events")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	app.Broker.Publish(models.Event{Name: models.EventReservationCreated, Data: models.Reservation{ID: 1}})
+
+	// the handler writes the event before it waits again, so the client leaves after receiving it
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	<-done
+
+	if rr.Header().Get("Content-Type") != "text/event-stream" {
+		t.Errorf("got content type %s, wanted text/event-stream", rr.Header().Get("Content-Type"))
+	}
+
+	body := rr.Body.String()
+
+	for _, expected := range []string{
+		"event: stats\ndata: {\"new_reservations\":2}\n\n",
+		"data: {\"event\":\"reservation.created\"",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected body to contain %q, got %q", expected, body)
+		}
+	}
+
+	if strings.Count(body, "event: stats") != 2 {
+		t.Errorf("expected stats to be sent again after the reservation event, got %q", body)
+	}
+
+	if app.Broker.Clients() != 0 {
+		t.Error("expected client to be unsubscribed")
+	}
+}
+
+// TestFillWeeks checks if weeks without bookings are added with zero count
+func TestFillWeeks(t *testing.T) {
+	firstWeek := time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC)
+
+	weeks := fillWeeks([]models.WeeklyCount{
+		{WeekStart: time.Date(2022, 8, 15, 0, 0, 0, 0, time.UTC), Count: 4},
+	}, firstWeek, 3)
+
+	expected := []int{0, 0, 4}
+
+	if len(weeks) != len(expected) {
+		t.Fatalf("got %d weeks, wanted %d", len(weeks), len(expected))
+	}
+
+	for i, count := range expected {
+		if weeks[i].Count != count {
+			t.Errorf("week %d: got %d, wanted %d", i, weeks[i].Count, count)
+		}
+	}
+
+	if !weeks[1].WeekStart.Equal(time.Date(2022, 8, 8, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("got second week %s, wanted 2022-08-08", weeks[1].WeekStart)
+	}
+}
+
+// TestRepository_AdminAllReservations tests AdminAllReservations handler with filters
+func TestRepository_AdminAllReservations(t *testing.T) {
+	var tests = []struct {
+		name               string
+		url                string
+		expectedStatusCode int
+		expectedLocation   string
+		expectedBody       string
+	}{
+		{
+			name:               "filtered and sorted",
+			url:                "/admin/reservations-all?q=smith&room_id=1&status=new&sort=last_name&dir=desc&page=2&per_page=10",
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "/admin/reservations-all?dir=desc&amp;page=3&amp;per_page=10&amp;q=smith&amp;room_id=1&amp;sort=last_name&amp;status=new",
+		},
+		{
+			name:               "invalid sort column",
+			url:                "/admin/reservations-all?sort=password",
+			expectedStatusCode: http.StatusSeeOther,
+			expectedLocation:   "/admin/reservations-all",
+		},
+		{
+			name:               "invalid page",
+			url:                "/admin/reservations-all?page=x",
+			expectedStatusCode: http.StatusSeeOther,
+			expectedLocation:   "/admin/reservations-all",
+		},
+		{
+			name:               "database error",
+			url:                "/admin/reservations-all?room_id=3",
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest("GET", tt.url, nil)
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminAllReservations)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != tt.expectedStatusCode {
+			t.Errorf("for %s: got status code %d, wanted %d", tt.name, rr.Code, tt.expectedStatusCode)
+		}
+
+		if tt.expectedLocation != "" {
+			actualLocation, _ := rr.Result().Location()
+			if actualLocation.String() != tt.expectedLocation {
+				t.Errorf("for %s: got location %s, wanted %s", tt.name, actualLocation.String(), tt.expectedLocation)
+			}
+		}
+
+		if tt.expectedBody != "" && !strings.Contains(rr.Body.String(), tt.expectedBody) {
+			t.Errorf("for %s: expected body to contain %s", tt.name, tt.expectedBody)
+		}
+	}
+}
+
+// TestRepository_AdminPostBulkReservations tests AdminPostBulkReservations handler with each action and role
+func TestRepository_AdminPostBulkReservations(t *testing.T) {
+	var tests = []struct {
+		name               string
+		accessLevel        int
+		postedData         url.Values
+		expectedStatusCode int
+		expectedLocation   string
+		expectedKey        string
+		expectedMessage    string
+	}{
+		{
+			name:               "mark processed",
+			accessLevel:        models.RoleFrontDesk,
+			postedData:         url.Values{"src": {"new"}, "action": {"process"}, "ids": {"1", "2", "2"}},
+			expectedStatusCode: http.StatusSeeOther,
+			expectedLocation:   "/admin/reservations-new",
+			expectedKey:        "flash",
+			expectedMessage:    "2 reservations marked as processed",
+		},
+		{
+			name:               "no action",
+			accessLevel:        models.RoleFrontDesk,
+			postedData:         url.Values{"ids": {"1"}},
+			expectedStatusCode: http.StatusSeeOther,
+			expectedLocation:   "/admin/reservations-all",
+			expectedKey:        "error",
+			expectedMessage:    "Choose an action",
+		},
+		{
+			name:               "no permission",
+			accessLevel:        models.RoleFrontDesk,
+			postedData:         url.Values{"action": {"cancel"}, "ids": {"1"}, "reason": {"Overbooked"}},
+			expectedStatusCode: http.StatusSeeOther,
+			expectedLocation:   "/admin/reservations-all",
+			expectedKey:        "error",
+			expectedMessage:    "permission",
+		},
+		{
+			name:               "no reservations",
+			accessLevel:        models.RoleFrontDesk,
+			postedData:         url.Values{"action": {"process"}},
+			expectedStatusCode: http.StatusSeeOther,
+			expectedLocation:   "/admin/reservations-all",
+			expectedKey:        "error",
+			expectedMessage:    "Choose at least one reservation",
+		},
+		{
+			name:               "invalid id",
+			accessLevel:        models.RoleFrontDesk,
+			postedData:         url.Values{"action": {"process"}, "ids": {"x"}},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "missing reservation",
+			accessLevel:        models.RoleFrontDesk,
+			postedData:         url.Values{"action": {"process"}, "ids": {"1", "3"}},
+			expectedStatusCode: http.StatusSeeOther,
+			expectedLocation:   "/admin/reservations-all",
+			expectedKey:        "error",
+			expectedMessage:    "nothing is changed",
+		},
+		{
+			name:               "cancel without reason",
+			accessLevel:        models.RoleManager,
+			postedData:         url.Values{"action": {"cancel"}, "ids": {"1"}},
+			expectedStatusCode: http.StatusSeeOther,
+			expectedLocation:   "/admin/reservations-all",
+			expectedKey:        "error",
+			expectedMessage:    "reason",
+		},
+		{
+			name:               "cancel",
+			accessLevel:        models.RoleManager,
+			postedData:         url.Values{"action": {"cancel"}, "ids": {"1", "2"}, "reason": {"Overbooked"}, "notify_guest": {"1"}},
+			expectedStatusCode: http.StatusSeeOther,
+			expectedLocation:   "/admin/reservations-all",
+			expectedKey:        "warning",
+			expectedMessage:    "2 reservations cancelled",
+		},
+		{
+			name:               "email without subject",
+			accessLevel:        models.RoleFrontDesk,
+			postedData:         url.Values{"action": {"email"}, "ids": {"1"}, "message": {"Hello {first_name}"}},
+			expectedStatusCode: http.StatusSeeOther,
+			expectedLocation:   "/admin/reservations-all",
+			expectedKey:        "error",
+			expectedMessage:    "subject",
+		},
+		{
+			name:               "email",
+			accessLevel:        models.RoleFrontDesk,
+			postedData:         url.Values{"action": {"email"}, "ids": {"1", "2"}, "subject": {"Your stay"}, "message": {"Hello {first_name}"}},
+			expectedStatusCode: http.StatusSeeOther,
+			expectedLocation:   "/admin/reservations-all",
+			expectedKey:        "flash",
+			expectedMessage:    "Email sent to 2 guests",
+		},
+		{
+			name:               "export",
+			accessLevel:        models.RoleFrontDesk,
+			postedData:         url.Values{"action": {"export"}, "ids": {"1"}},
+			expectedStatusCode: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest("POST", "/admin/reservations-bulk", strings.NewReader(tt.postedData.Encode()))
+		ctx := helpers.WithUser(getCtx(req), models.User{ID: 1, AccessLevel: tt.accessLevel})
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminPostBulkReservations)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != tt.expectedStatusCode {
+			t.Errorf("for %s: got status code %d, wanted %d", tt.name, rr.Code, tt.expectedStatusCode)
+		}
+
+		if tt.expectedLocation != "" {
+			actualLocation, _ := rr.Result().Location()
+			if actualLocation.String() != tt.expectedLocation {
+				t.Errorf("for %s: got location %s, wanted %s", tt.name, actualLocation.String(), tt.expectedLocation)
+			}
+		}
+
+		if tt.expectedKey != "" {
+			message := session.PopString(ctx, tt.expectedKey)
+			if !strings.Contains(message, tt.expectedMessage) {
+				t.Errorf("for %s: got %s %q, wanted it to contain %q", tt.name, tt.expectedKey, message, tt.expectedMessage)
+			}
+		}
+
+		if tt.name == "export" && !strings.HasPrefix(rr.Header().Get("Content-Type"), "text/csv") {
+			t.Errorf("for %s: got content type %s, wanted text/csv", tt.name, rr.Header().Get("Content-Type"))
+		}
+	}
+}
+
+// TestReservationPlaceholders checks if the placeholders of the bulk emails are replaced with the reservation's details
+func TestReservationPlaceholders(t *testing.T) {
+	res := models.Reservation{
+		ID:        7,
+		FirstName: "John",
+		StartDate: time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC),
+		Room:      models.Room{RoomName: "Major's Suite"},
+	}
+
+	got := reservationPlaceholders(res).Replace("Dear {first_name}, see you on {start_date} in {room} ({id}) {unknown}")
+	expected := "Dear John, see you on 2022-08-01 in Major's Suite (7) {unknown}"
+
+	if got != expected {
+		t.Errorf("got %q, wanted %q", got, expected)
+	}
+}
+
+// TestRepository_AdminReservationsTimeline tests AdminReservationsTimeline handler with its ranges
+func TestRepository_AdminReservationsTimeline(t *testing.T) {
+	var tests = []struct {
+		name               string
+		url                string
+		expectedStatusCode int
+		expectedLocation   string
+		expectedBody       string
+	}{
+		{
+			name:               "two weeks",
+			url:                "/admin/reservations-timeline?start=2022-08-01&days=14",
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       `<a href="/admin/reservations/all/1/show">John Smith</a>`,
+		},
+		{
+			name:               "invalid start",
+			url:                "/admin/reservations-timeline?start=08/01/2022",
+			expectedStatusCode: http.StatusSeeOther,
+			expectedLocation:   "/admin/reservations-timeline",
+		},
+		{
+			name:               "too long",
+			url:                "/admin/reservations-timeline?days=365",
+			expectedStatusCode: http.StatusSeeOther,
+			expectedLocation:   "/admin/reservations-timeline",
+		},
+		{
+			name:               "too short",
+			url:                "/admin/reservations-timeline?days=1",
+			expectedStatusCode: http.StatusSeeOther,
+			expectedLocation:   "/admin/reservations-timeline",
+		},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest("GET", tt.url, nil)
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminReservationsTimeline)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != tt.expectedStatusCode {
+			t.Errorf("for %s: got status code %d, wanted %d", tt.name, rr.Code, tt.expectedStatusCode)
+		}
+
+		if tt.expectedLocation != "" {
+			actualLocation, _ := rr.Result().Location()
+			if actualLocation.String() != tt.expectedLocation {
+				t.Errorf("for %s: got location %s, wanted %s", tt.name, actualLocation.String(), tt.expectedLocation)
+			}
+		}
+
+		if tt.expectedBody != "" && !strings.Contains(rr.Body.String(), tt.expectedBody) {
+			t.Errorf("for %s: expected body to contain %s", tt.name, tt.expectedBody)
+		}
+	}
+}
+
+// TestTimelineBars checks if the restrictions are cut off at the edges of the range
+func TestTimelineBars(t *testing.T) {
+	start := time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC)
+	day := func(d int) time.Time {
+		return start.AddDate(0, 0, d)
+	}
+
+	bars := timelineBars([]models.RoomRestriction{
+		{StartDate: day(-3), EndDate: day(2), ReservationID: 1, Reservation: models.Reservation{FirstName: "John", LastName: "Smith"}},
+		{StartDate: day(4), EndDate: day(5), RestrictionID: 2},
+		{StartDate: day(10), EndDate: day(20), ReservationID: 2, Reservation: models.Reservation{Processed: 1}},
+		{StartDate: day(12), EndDate: day(13), ICalImportID: 1},
+		{StartDate: day(14), EndDate: day(16), ReservationID: 3},
+	}, start, 14)
+
+	expected := []timelineBar{
+		{Column: 1, Span: 2, Label: "John Smith", URL: "/admin/reservations/all/1/show", Kind: "reservation", ContinuesLeft: true},
+		{Column: 5, Span: 1, Label: "Blocked", Kind: "block"},
+		{Column: 11, Span: 4, URL: "/admin/reservations/all/2/show", Kind: "processed", ContinuesOn: true},
+		{Column: 13, Span: 1, Label: "External", Kind: "external"},
+	}
+
+	if len(bars) != len(expected) {
+		t.Fatalf("got %d bars, wanted %d", len(bars), len(expected))
+	}
+
+	for n, bar := range bars {
+		if bar != expected[n] {
+			t.Errorf("bar %d: got %+v, wanted %+v", n, bar, expected[n])
+		}
+	}
+}
+
+// TestRepository_AdminAuditLog tests AdminAuditLog handler with filters
+func TestRepository_AdminAuditLog(t *testing.T) {
+	var tests = []struct {
+		name               string
+		url                string
+		expectedStatusCode int
+		expectedLocation   string
+		expectedBody       string
+	}{
+		{
+			name:               "filtered",
+			url:                "/admin/audit-log?user_id=1&entity=reservation&start=2022-08-01&end=2022-08-31",
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "John Smith",
+		},
+		{
+			name:               "unknown entity",
+			url:                "/admin/audit-log?entity=passwords",
+			expectedStatusCode: http.StatusSeeOther,
+			expectedLocation:   "/admin/audit-log",
+		},
+		{
+			name:               "invalid date",
+			url:                "/admin/audit-log?start=08/01/2022",
+			expectedStatusCode: http.StatusSeeOther,
+			expectedLocation:   "/admin/audit-log",
+		},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest("GET", tt.url, nil)
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminAuditLog)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != tt.expectedStatusCode {
+			t.Errorf("for %s: got status code %d, wanted %d", tt.name, rr.Code, tt.expectedStatusCode)
+		}
+
+		if tt.expectedLocation != "" {
+			actualLocation, _ := rr.Result().Location()
+			if actualLocation.String() != tt.expectedLocation {
+				t.Errorf("for %s: got location %s, wanted %s", tt.name, actualLocation.String(), tt.expectedLocation)
+			}
+		}
+
+		if tt.expectedBody != "" && !strings.Contains(rr.Body.String(), tt.expectedBody) {
+			t.Errorf("for %s: expected body to contain %s", tt.name, tt.expectedBody)
+		}
+	}
+}
+
+// TestClientIP checks if the port is removed from the request's address, and the configured header is used behind a proxy
+func TestClientIP(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/", nil)
+
+	req.RemoteAddr = "192.0.2.1:1234"
+	if ip := Repo.clientIP(req); ip != "192.0.2.1" {
+		t.Errorf("got %s, wanted 192.0.2.1", ip)
+	}
+
+	req.RemoteAddr = "[2001:db8::1]:1234"
+	if ip := Repo.clientIP(req); ip != "2001:db8::1" {
+		t.Errorf("got %s, wanted 2001:db8::1", ip)
+	}
+
+	req.Header.Set("X-Forwarded-For", "203.0.113.9, 198.51.100.7")
+	if ip := Repo.clientIP(req); ip != "2001:db8::1" {
+		t.Errorf("got %s, wanted the header to be ignored when it isn't configured", ip)
+	}
+
+	app.IPHeader = "X-Forwarded-For"
+	defer func() { app.IPHeader = "" }()
+
+	if ip := Repo.clientIP(req); ip != "198.51.100.7" {
+		t.Errorf("got %s, wanted the address the proxy added, 198.51.100.7", ip)
+	}
+
+	req.Header.Del("X-Forwarded-For")
+	if ip := Repo.clientIP(req); ip != "2001:db8::1" {
+		t.Errorf("got %s, wanted the connection's address without the header", ip)
+	}
+}
+
+// TestRepository_AdminPostUnlockLogins tests AdminPostUnlockLogins handler
+func TestRepository_AdminPostUnlockLogins(t *testing.T) {
+	var tests = []struct {
+		name            string
+		postedData      url.Values
+		expectedSession string
+	}{
+		{"email", url.Values{"email": {"locked@here.com"}}, "flash"},
+		{"ip", url.Values{"ip": {"10.0.0.1"}}, "flash"},
+		{"nothing", url.Values{}, "error"},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest("POST", "/admin/unlock-logins", strings.NewReader(tt.postedData.Encode()))
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminPostUnlockLogins)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusSeeOther {
+			t.Errorf("for %s: got status code %d, wanted %d", tt.name, rr.Code, http.StatusSeeOther)
+		}
+
+		if session.PopString(ctx, tt.expectedSession) == "" {
+			t.Errorf("for %s: expected a %s message in the session", tt.name, tt.expectedSession)
+		}
+	}
+}
+
+// TestPageLinks checks if pages far from the current page are replaced with gaps
+func TestPageLinks(t *testing.T) {
+	u, _ := url.Parse("/admin/reservations-all?q=smith&page=6")
+
+	links := pageLinks(u, 6, 12)
+
+	var numbers []int
+	for _, l := range links {
+		numbers = append(numbers, l.Number)
+	}
+
+	expected := fmt.Sprint([]int{1, 0, 4, 5, 6, 7, 8, 0, 12})
+
+	if fmt.Sprint(numbers) != expected {
+		t.Errorf("got pages %v, wanted %s", numbers, expected)
+	}
+
+	if !links[4].Active || links[4].URL != "/admin/reservations-all?page=6&q=smith" {
+		t.Errorf("got wrong current page link %+v", links[4])
+	}
+
+	if len(pageLinks(u, 1, 0)) != 0 {
+		t.Error("expected no links without pages")
+	}
+}
+
+// TestRepository_AdminPostNewReservation tests AdminPostNewReservation handler
+func TestRepository_AdminPostNewReservation(t *testing.T) {
+	valid := func(changes map[string]string) url.Values {
+		values := url.Values{
+			"first_name": {"John"},
+			"last_name":  {"Smith"},
+			"email":      {"john@smith.com"},
+			"room_id":    {"1"},
+			"start_date": {"2050-01-01"},
+			"end_date":   {"2050-01-03"},
+			"send_email": {"1"},
+		}
+
+		for k, v := range changes {
+			if v == "" {
+				values.Del(k)
+			} else {
+				values.Set(k, v)
+			}
+		}
+
+		return values
+	}
+
+	var tests = []struct {
+		name               string
+		postedData         url.Values
+		expectedStatusCode int
+		expectedLocation   string
+		expectedBody       string
+	}{
+		{
+			name:               "valid reservation",
+			postedData:         valid(nil),
+			expectedStatusCode: http.StatusSeeOther,
+			expectedLocation:   "/admin/reservations/all/1/show",
+		},
+		{
+			name:               "walk-in without email",
+			postedData:         valid(map[string]string{"email": "", "send_email": ""}),
+			expectedStatusCode: http.StatusSeeOther,
+			expectedLocation:   "/admin/reservations/all/1/show",
+		},
+		{
+			name:               "confirmation without email",
+			postedData:         valid(map[string]string{"email": ""}),
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "This field cannot be blank",
+		},
+		{
+			name:               "invalid dates",
+			postedData:         valid(map[string]string{"start_date": "2050-01-05"}),
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "Departure must be after arrival",
+		},
+		{
+			name:               "unknown room",
+			postedData:         valid(map[string]string{"room_id": "3"}),
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "Unknown room",
+		},
+		{
+			name:               "room is not available",
+			postedData:         valid(map[string]string{"room_id": "2", "start_date": "2030-01-02", "end_date": "2030-01-04"}),
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "The room is not available for these dates",
+		},
+		{
+			name:               "insert error",
+			postedData:         valid(map[string]string{"last_name": "Fail"}),
+			expectedStatusCode: http.StatusSeeOther,
+			expectedLocation:   "/admin/reservations-create",
+		},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest("POST", "/admin/reservations-create", strings.NewReader(tt.postedData.Encode()))
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminPostNewReservation)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != tt.expectedStatusCode {
+			t.Errorf("for %s: got status code %d, wanted %d", tt.name, rr.Code, tt.expectedStatusCode)
+		}
+
+		if tt.expectedLocation != "" {
+			actualLocation, _ := rr.Result().Location()
+			if actualLocation.String() != tt.expectedLocation {
+				t.Errorf("for %s: got location %s, wanted %s", tt.name, actualLocation.String(), tt.expectedLocation)
+			}
+		}
+
+		if tt.expectedBody != "" && !strings.Contains(rr.Body.String(), tt.expectedBody) {
+			t.Errorf("for %s: expected body to contain %s", tt.name, tt.expectedBody)
+		}
+	}
+}
+
+// TestRepository_AdminPostShowRoom tests AdminPostShowRoom handler
+func TestRepository_AdminPostShowRoom(t *testing.T) {
+	var tests = []struct {
+		name               string
+		url                string
+		postedData         url.Values
+		expectedStatusCode int
+		expectedLocation   string
+		expectedBody       string
+	}{
+		{
+			name:               "new room",
+			url:                "/admin/rooms/0",
+			postedData:         url.Values{"room_name": {"Colonel's Cabin"}, "active": {"1"}},
+			expectedStatusCode: http.StatusSeeOther,
+			expectedLocation:   "/admin/rooms/3/show",
+		},
+		{
+			name:               "rename room",
+			url:                "/admin/rooms/1",
+			postedData:         url.Values{"room_name": {"General's Quarters"}, "description": {"A room"}},
+			expectedStatusCode: http.StatusSeeOther,
+			expectedLocation:   "/admin/rooms/1/show",
+		},
+		{
+			name:               "missing name",
+			url:                "/admin/rooms/1",
+			postedData:         url.Values{"active": {"1"}},
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "This field cannot be blank",
+		},
+		{
+			name:               "deleted room",
+			url:                "/admin/rooms/1",
+			postedData:         url.Values{"room_name": {"Deleted"}, "active": {"1"}},
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			name:               "deactivate room with reservations",
+			url:                "/admin/rooms/2",
+			postedData:         url.Values{"room_name": {"Major's Suite"}},
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "The room has 1 upcoming reservations",
+		},
+		{
+			name:               "activate room with reservations",
+			url:                "/admin/rooms/2",
+			postedData:         url.Values{"room_name": {"Major's Suite"}, "active": {"1"}},
+			expectedStatusCode: http.StatusSeeOther,
+			expectedLocation:   "/admin/rooms/2/show",
+		},
+		{
+			name:               "unknown room",
+			url:                "/admin/rooms/3",
+			postedData:         url.Values{"room_name": {"Unknown"}},
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+		{
+			name:               "insert error",
+			url:                "/admin/rooms/0",
+			postedData:         url.Values{"room_name": {"Fail"}},
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest("POST", tt.url, strings.NewReader(tt.postedData.Encode()))
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+		req.RequestURI = tt.url
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminPostShowRoom)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != tt.expectedStatusCode {
+			t.Errorf("for %s: got status code %d, wanted %d", tt.name, rr.Code, tt.expectedStatusCode)
+		}
+
+		if tt.expectedLocation != "" {
+			actualLocation, _ := rr.Result().Location()
+			if actualLocation.String() != tt.expectedLocation {
+				t.Errorf("for %s: got location %s, wanted %s", tt.name, actualLocation.String(), tt.expectedLocation)
+			}
+		}
+
+		if tt.expectedBody != "" && !strings.Contains(rr.Body.String(), tt.expectedBody) {
+			t.Errorf("for %s: expected body to contain %s", tt.name, tt.expectedBody)
+		}
+	}
+}
+
+// TestRepository_AdminPostShowUser tests AdminPostShowUser handler
+func TestRepository_AdminPostShowUser(t *testing.T) {
+	var tests = []struct {
+		name               string
+		url                string
+		postedData         url.Values
+		expectedStatusCode int
+		expectedLocation   string
+		expectedBody       string
+	}{
+		{
+			name:               "invite user",
+			url:                "/admin/users/0",
+			postedData:         url.Values{"first_name": {"John"}, "last_name": {"Smith"}, "email": {"john@here.com"}, "access_level": {"1"}},
+			expectedStatusCode: http.StatusSeeOther,
+			expectedLocation:   "/admin/users/3/show",
+		},
+		{
+			name:               "update user",
+			url:                "/admin/users/2",
+			postedData:         url.Values{"first_name": {"Jane"}, "last_name": {"Doe"}, "email": {"taken@here.com"}, "access_level": {"2"}},
+			expectedStatusCode: http.StatusSeeOther,
+			expectedLocation:   "/admin/users/2/show",
+		},
+		{
+			name:               "email taken",
+			url:                "/admin/users/0",
+			postedData:         url.Values{"first_name": {"John"}, "last_name": {"Smith"}, "email": {"taken@here.com"}, "access_level": {"1"}},
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "A user with this email already exists",
+		},
+		{
+			name:               "invalid access level",
+			url:                "/admin/users/0",
+			postedData:         url.Values{"first_name": {"John"}, "last_name": {"Smith"}, "email": {"john@here.com"}, "access_level": {"9"}},
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "Choose a role",
+		},
+		{
+			name:               "missing fields",
+			url:                "/admin/users/0",
+			postedData:         url.Values{"access_level": {"1"}},
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "This field cannot be blank",
+		},
+		{
+			name:               "unknown user",
+			url:                "/admin/users/3",
+			postedData:         url.Values{"first_name": {"John"}, "last_name": {"Smith"}, "email": {"john@here.com"}, "access_level": {"1"}},
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+		{
+			name:               "insert error",
+			url:                "/admin/users/0",
+			postedData:         url.Values{"first_name": {"John"}, "last_name": {"Fail"}, "email": {"john@here.com"}, "access_level": {"1"}},
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest("POST", tt.url, strings.NewReader(tt.postedData.Encode()))
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+		req.RequestURI = tt.url
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminPostShowUser)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != tt.expectedStatusCode {
+			t.Errorf("for %s: got status code %d, wanted %d", tt.name, rr.Code, tt.expectedStatusCode)
+		}
+
+		if tt.expectedLocation != "" {
+			actualLocation, _ := rr.Result().Location()
+			if actualLocation.String() != tt.expectedLocation {
+				t.Errorf("for %s: got location %s, wanted %s", tt.name, actualLocation.String(), tt.expectedLocation)
+			}
+		}
+
+		if tt.expectedBody != "" && !strings.Contains(rr.Body.String(), tt.expectedBody) {
+			t.Errorf("for %s: expected body to contain %s", tt.name, tt.expectedBody)
+		}
+	}
+}
+
+// TestRepository_AdminPostShowUserDeactivateSelf checks if users can't deactivate their own accounts
+func TestRepository_AdminPostShowUserDeactivateSelf(t *testing.T) {
+	postedData := url.Values{"first_name": {"Jane"}, "last_name": {"Doe"}, "email": {"jane@here.com"}, "access_level": {"1"}}
+
+	req, _ := http.NewRequest("POST", "/admin/users/2", strings.NewReader(postedData.Encode()))
+	ctx := getCtx(req)
+	req = req.WithContext(ctx)
+	req.RequestURI = "/admin/users/2"
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	session.Put(ctx, "user_id", 2)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(Repo.AdminPostShowUser)
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("got status code %d, wanted %d", rr.Code, http.StatusOK)
+	}
+
+	if !strings.Contains(rr.Body.String(), "deactivate your own account") {
+		t.Error("expected an error for deactivating own account")
+	}
+}
+
+// TestRepository_PostSetPassword tests PostSetPassword handler
+func TestRepository_PostSetPassword(t *testing.T) {
+	var tests = []struct {
+		name               string
+		postedData         url.Values
+		expectedStatusCode int
+		expectedLocation   string
+		expectedBody       string
+	}{
+		{
+			name:               "valid password",
+			postedData:         url.Values{"token": {"valid-token"}, "password": {"correct-horse"}, "password_confirmation": {"correct-horse"}},
+			expectedStatusCode: http.StatusSeeOther,
+			expectedLocation:   "/user/login",
+		},
+		{
+			name:               "short password",
+			postedData:         url.Values{"token": {"valid-token"}, "password": {"short"}, "password_confirmation": {"short"}},
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "This field must be at least 8 characters long",
+		},
+		{
+			name:               "common password",
+			postedData:         url.Values{"token": {"valid-token"}, "password": {"password123"}, "password_confirmation": {"password123"}},
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "The password is too easy to guess",
+		},
+		{
+			name:               "password with name",
+			postedData:         url.Values{"token": {"valid-token"}, "password": {"jane-doe-2022"}, "password_confirmation": {"jane-doe-2022"}},
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "contain your name or email",
+		},
+		{
+			name:               "different confirmation",
+			postedData:         url.Values{"token": {"valid-token"}, "password": {"correct-horse"}, "password_confirmation": {"correct-cow"}},
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "The values don&#39;t match",
+		},
+		{
+			name:               "invalid token",
+			postedData:         url.Values{"token": {"expired"}, "password": {"correct-horse"}, "password_confirmation": {"correct-horse"}},
+			expectedStatusCode: http.StatusSeeOther,
+			expectedLocation:   "/user/login",
+		},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest("POST", "/user/set-password", strings.NewReader(tt.postedData.Encode()))
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.PostSetPassword)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != tt.expectedStatusCode {
+			t.Errorf("for %s: got status code %d, wanted %d", tt.name, rr.Code, tt.expectedStatusCode)
+		}
+
+		if tt.expectedLocation != "" {
+			actualLocation, _ := rr.Result().Location()
+			if actualLocation.String() != tt.expectedLocation {
+				t.Errorf("for %s: got location %s, wanted %s", tt.name, actualLocation.String(), tt.expectedLocation)
+			}
+		}
+
+		if tt.expectedBody != "" && !strings.Contains(rr.Body.String(), tt.expectedBody) {
+			t.Errorf("for %s: expected body to contain %s", tt.name, tt.expectedBody)
+		}
+	}
+}
+
+// TestRepository_AdminPostShowGuest tests AdminPostShowGuest handler
+func TestRepository_AdminPostShowGuest(t *testing.T) {
+	var tests = []struct {
+		name               string
+		url                string
+		postedData         url.Values
+		expectedStatusCode int
+		expectedLocation   string
+		expectedBody       string
+	}{
+		{
+			name: "valid",
+			url:  "/admin/guests/1",
+			postedData: url.Values{"first_name": {"John"}, "last_name": {"Smith"}, "email": {"john@smith.com"},
+				"tags": {"VIP", "Allergy", "Vegan, late checkout"}, "notes": {"Gluten free"}},
+			expectedStatusCode: http.StatusSeeOther,
+			expectedLocation:   "/admin/guests/1/show",
+		},
+		{
+			name:               "missing name",
+			url:                "/admin/guests/1",
+			postedData:         url.Values{"first_name": {"John"}},
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "This field cannot be blank",
+		},
+		{
+			name:               "email of another guest",
+			url:                "/admin/guests/1",
+			postedData:         url.Values{"first_name": {"John"}, "last_name": {"Smith"}, "email": {"Jane@Doe.com"}},
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "Guest #2 has this email",
+		},
+		{
+			name:               "unknown guest",
+			url:                "/admin/guests/9",
+			postedData:         url.Values{"first_name": {"John"}, "last_name": {"Smith"}},
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+		{
+			name:               "update error",
+			url:                "/admin/guests/1",
+			postedData:         url.Values{"first_name": {"John"}, "last_name": {"Fail"}},
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest("POST", tt.url, strings.NewReader(tt.postedData.Encode()))
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+		req.RequestURI = tt.url
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminPostShowGuest)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != tt.expectedStatusCode {
+			t.Errorf("for %s: got status code %d, wanted %d", tt.name, rr.Code, tt.expectedStatusCode)
+		}
+
+		if tt.expectedLocation != "" {
+			actualLocation, _ := rr.Result().Location()
+			if actualLocation.String() != tt.expectedLocation {
+				t.Errorf("for %s: got location %s, wanted %s", tt.name, actualLocation.String(), tt.expectedLocation)
+			}
+		}
+
+		if tt.expectedBody != "" && !strings.Contains(rr.Body.String(), tt.expectedBody) {
+			t.Errorf("for %s: expected body to contain %s", tt.name, tt.expectedBody)
+		}
+	}
+}
+
+// TestRepository_AdminPostMergeGuest tests AdminPostMergeGuest handler
+func TestRepository_AdminPostMergeGuest(t *testing.T) {
+	var tests = []struct {
+		name               string
+		duplicate          string
+		expectedStatusCode int
+		expectedSession    string
+	}{
+		{"merge", "2", http.StatusSeeOther, "flash"},
+		{"same guest", "1", http.StatusSeeOther, "error"},
+		{"no duplicate", "", http.StatusSeeOther, "error"},
+		{"unknown duplicate", "9", http.StatusInternalServerError, ""},
+	}
+
+	for _, tt := range tests {
+		postedData := url.Values{"duplicate": {tt.duplicate}}
+
+		req, _ := http.NewRequest("POST", "/admin/merge-guests/1", strings.NewReader(postedData.Encode()))
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+		req.RequestURI = "/admin/merge-guests/1"
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminPostMergeGuest)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != tt.expectedStatusCode {
+			t.Errorf("for %s: got status code %d, wanted %d", tt.name, rr.Code, tt.expectedStatusCode)
+		}
+
+		if tt.expectedSession != "" && session.PopString(ctx, tt.expectedSession) == "" {
+			t.Errorf("for %s: expected a %s message in the session", tt.name, tt.expectedSession)
+		}
+	}
+}
+
+// TestParseTags checks if the tags are trimmed and deduplicated
+func TestParseTags(t *testing.T) {
+	tags := parseTags("VIP, Allergy,, vip ,Late checkout")
+	expected := []string{"VIP", "Allergy", "Late checkout"}
+
+	if strings.Join(tags, "|") != strings.Join(expected, "|") {
+		t.Errorf("got %v, wanted %v", tags, expected)
+	}
+}
+
+// TestRepository_AdminPostReservationNote tests AdminPostReservationNote handler
+func TestRepository_AdminPostReservationNote(t *testing.T) {
+	var tests = []struct {
+		name               string
+		note               string
+		expectedStatusCode int
+		expectedSession    string
+	}{
+		{"valid", "Late arrival ~11pm", http.StatusSeeOther, "flash"},
+		{"empty", "  ", http.StatusSeeOther, "error"},
+		{"insert error", "Fail", http.StatusInternalServerError, ""},
+	}
+
+	for _, tt := range tests {
+		postedData := url.Values{"note": {tt.note}, "year": {"2022"}, "month": {"08"}}
+
+		req, _ := http.NewRequest("POST", "/admin/reservations/cal/1/notes", strings.NewReader(postedData.Encode()))
+		ctx := getCtx(req)
+		ctx = helpers.WithUser(ctx, models.User{ID: 1, AccessLevel: models.RoleFrontDesk})
+		req = req.WithContext(ctx)
+		req.RequestURI = "/admin/reservations/cal/1/notes"
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminPostReservationNote)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != tt.expectedStatusCode {
+			t.Errorf("for %s: got status code %d, wanted %d", tt.name, rr.Code, tt.expectedStatusCode)
+		}
+
+		if tt.expectedStatusCode == http.StatusSeeOther {
+			actualLocation, _ := rr.Result().Location()
+			if actualLocation.String() != "/admin/reservations/cal/1/show?y=2022&m=08" {
+				t.Errorf("for %s: got location %s", tt.name, actualLocation.String())
+			}
+		}
+
+		if tt.expectedSession != "" && session.PopString(ctx, tt.expectedSession) == "" {
+			t.Errorf("for %s: expected a %s message in the session", tt.name, tt.expectedSession)
+		}
+	}
+}
+
+// TestRepository_AdminShowReservationDetailNotes checks if the notes are listed with their authors
+func TestRepository_AdminShowReservationDetailNotes(t *testing.T) {
+	ts := httptest.NewTLSServer(getRoutes())
+	defer ts.Close()
+
+	resp, err := ts.Client().Get(ts.URL + "/admin/reservations/all/1/show")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+
+	for _, expected := range []string{"Admin User", "Late arrival ~11pm", "Deleted user"} {
+		if !strings.Contains(string(body), expected) {
+			t.Errorf("expected body to contain %s", expected)
+		}
+	}
+}
+
+// TestDailyRooms checks if the restrictions are sorted into arrivals, departures, stay-overs and blocks of the day
+func TestDailyRooms(t *testing.T) {
+	day := time.Date(2022, 8, 10, 0, 0, 0, 0, time.UTC)
+
+	rooms := dailyRooms([]models.TimelineRoom{
+		{
+			Room: models.Room{ID: 1, RoomName: "General's Quarters", Active: 1},
+			Restrictions: []models.RoomRestriction{
+				{ID: 1, ReservationID: 1, StartDate: day.AddDate(0, 0, -3), EndDate: day},
+				{ID: 2, ReservationID: 2, StartDate: day, EndDate: day.AddDate(0, 0, 2)},
+			},
+		},
+		{
+			Room: models.Room{ID: 2, RoomName: "Major's Suite", Active: 1},
+			Restrictions: []models.RoomRestriction{
+				{ID: 3, ReservationID: 3, StartDate: day.AddDate(0, 0, -1), EndDate: day.AddDate(0, 0, 1)},
+			},
+		},
+		{
+			Room: models.Room{ID: 3, RoomName: "Blocked Room", Active: 1},
+			Restrictions: []models.RoomRestriction{
+				{ID: 4, RestrictionID: 2, StartDate: day.AddDate(0, 0, -1), EndDate: day.AddDate(0, 0, 1)},
+			},
+		},
+		{
+			Room: models.Room{ID: 4, RoomName: "Empty Room", Active: 1},
+		},
+		{
+			Room: models.Room{ID: 5, RoomName: "Closed Room"},
+		},
+	}, day)
+
+	expected := []struct {
+		housekeeping string
+		vacant       bool
+	}{
+		{models.HousekeepingTurnover, false},
+		{models.HousekeepingStayOver, false},
+		{models.HousekeepingBlocked, false},
+		{models.HousekeepingNone, true},
+	}
+
+	if len(rooms) != len(expected) {
+		t.Fatalf("got %d rooms, wanted %d", len(rooms), len(expected))
+	}
+
+	for n, d := range rooms {
+		if d.Housekeeping() != expected[n].housekeeping || d.Vacant() != expected[n].vacant {
+			t.Errorf("room %s: got %s and vacant %t, wanted %s and vacant %t", d.Room.RoomName, d.Housekeeping(),
+				d.Vacant(), expected[n].housekeeping, expected[n].vacant)
+		}
+	}
+
+	if rooms[0].Departure.ID != 1 || rooms[0].Arrival.ID != 2 {
+		t.Errorf("expected reservation 1 to leave and 2 to arrive, got %+v", rooms[0])
+	}
+}
+
+// TestRepository_AdminDailyReportCSV checks if the daily report is downloaded as CSV
+func TestRepository_AdminDailyReportCSV(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/admin/reports-daily?date=2022-08-01&format=csv", nil)
+	ctx := getCtx(req)
+	req = req.WithContext(ctx)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(Repo.AdminDailyReport)
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("got status code %d, wanted %d", rr.Code, http.StatusOK)
+	}
+
+	if rr.Header().Get("Content-Disposition") != "attachment; filename=daily-report-2022-08-01.csv" {
+		t.Errorf("got content disposition %s", rr.Header().Get("Content-Disposition"))
+	}
+
+	if !strings.Contains(rr.Body.String(), "General's Quarters,Stay-over service,,,,John Smith,2022-08-03,no") {
+		t.Errorf("expected a stay-over row, got %q", rr.Body.String())
+	}
+}
+
+// TestRepository_AdminDailyReportInvalidDate checks if invalid dates are rejected
+func TestRepository_AdminDailyReportInvalidDate(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/admin/reports-daily?date=08/01/2022", nil)
+	ctx := getCtx(req)
+	req = req.WithContext(ctx)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(Repo.AdminDailyReport)
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusSeeOther {
+		t.Errorf("got status code %d, wanted %d", rr.Code, http.StatusSeeOther)
+	}
+
+	if session.PopString(ctx, "error") != "Invalid date" {
+		t.Error("expected an error in the session")
+	}
+}
+
+// TestRepository_AdminSearch checks if the results are grouped by their types
+func TestRepository_AdminSearch(t *testing.T) {
+	var tests = []struct {
+		name        string
+		url         string
+		expected    []string
+		notExpected []string
+	}{
+		{"reservations and guests", "/admin/search?q=smith", []string{"Reservations (1)", "Guests (1)", "John Smith"}, []string{"Rooms ("}},
+		{"reservation number", "/admin/search?q=%232", []string{"Reservations (1)", "Jane Doe"}, []string{"Guests ("}},
+		{"room", "/admin/search?q=general", []string{"Rooms (1)"}, []string{"Reservations ("}},
+		{"nothing found", "/admin/search?q=nobody", []string{"Nothing found"}, nil},
+		{"too short", "/admin/search?q=j", []string{"Enter at least 2 characters"}, nil},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest("GET", tt.url, nil)
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminSearch)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("for %s: got status code %d, wanted %d", tt.name, rr.Code, http.StatusOK)
+		}
+
+		for _, s := range tt.expected {
+			if !strings.Contains(rr.Body.String(), s) {
+				t.Errorf("for %s: expected body to contain %s", tt.name, s)
+			}
+		}
+
+		for _, s := range tt.notExpected {
+			if strings.Contains(rr.Body.String(), s) {
+				t.Errorf("for %s: expected body not to contain %s", tt.name, s)
+			}
+		}
+	}
+}
+
+// TestRepository_PostForgotPassword checks if the response is the same for emails with and without accounts
+func TestRepository_PostForgotPassword(t *testing.T) {
+	var tests = []struct {
+		name               string
+		email              string
+		remoteAddr         string
+		expectedStatusCode int
+		expectedLocation   string
+		expectedBody       string
+	}{
+		{"user", "taken@here.com", "192.0.2.1:1234", http.StatusSeeOther, "/user/login", ""},
+		{"no user", "nobody@here.com", "192.0.2.1:1234", http.StatusSeeOther, "/user/login", ""},
+		{"invalid email", "nobody", "192.0.2.1:1234", http.StatusOK, "", "Invalid email address"},
+		{"locked ip", "taken@here.com", "10.0.0.1:1234", http.StatusSeeOther, "/user/forgot-password", ""},
+	}
+
+	var flashes []string
+
+	for _, tt := range tests {
+		postedData := url.Values{"email": {tt.email}}
+
+		req, _ := http.NewRequest("POST", "/user/forgot-password", strings.NewReader(postedData.Encode()))
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+		req.RemoteAddr = tt.remoteAddr
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.PostForgotPassword)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != tt.expectedStatusCode {
+			t.Errorf("for %s: got status code %d, wanted %d", tt.name, rr.Code, tt.expectedStatusCode)
+		}
+
+		if tt.expectedLocation != "" {
+			actualLocation, _ := rr.Result().Location()
+			if actualLocation.String() != tt.expectedLocation {
+				t.Errorf("for %s: got location %s, wanted %s", tt.name, actualLocation.String(), tt.expectedLocation)
+			}
+		}
+
+		if tt.expectedLocation == "/user/login" {
+			flashes = append(flashes, session.PopString(ctx, "flash"))
+		}
+
+		if tt.expectedLocation == "/user/forgot-password" && !strings.Contains(session.PopString(ctx, "error"), "Too many requests") {
+			t.Errorf("for %s: expected a too many requests error", tt.name)
+		}
+
+		if tt.expectedBody != "" && !strings.Contains(rr.Body.String(), tt.expectedBody) {
+			t.Errorf("for %s: expected body to contain %s", tt.name, tt.expectedBody)
+		}
+	}
+
+	if flashes[0] == "" || flashes[0] != flashes[1] {
+		t.Errorf("expected the same message for emails with and without accounts, got %q", flashes)
+	}
+}
+
+// TestRepository_PostShowLoginTwoFactor tests that users with two-factor authentication must enter a code after the password
+func TestRepository_PostShowLoginTwoFactor(t *testing.T) {
+	postedData := url.Values{"email": {"two-factor@here.com"}, "password": {"password"}}
+
+	req, _ := http.NewRequest("POST", "/user/login", strings.NewReader(postedData.Encode()))
+	ctx := getCtx(req)
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(Repo.PostShowLogin)
+	handler.ServeHTTP(rr, req)
+
+	actualLocation, _ := rr.Result().Location()
+	if actualLocation.String() != "/user/login/two-factor" {
+		t.Errorf("got location %s, wanted /user/login/two-factor", actualLocation.String())
+	}
+
+	if session.Exists(ctx, "user_id") {
+		t.Error("expected the user not to be logged in before entering a code")
+	}
+
+	if session.GetInt(ctx, "two_factor_user_id") != 2 {
+		t.Error("expected the user to wait for a code")
+	}
+}
+
+// TestRepository_PostLoginTwoFactor tests PostLoginTwoFactor handler
+func TestRepository_PostLoginTwoFactor(t *testing.T) {
+	validCode, _ := totp.GenerateCode(dbrepo.TestTOTPSecret, time.Now())
+
+	var tests = []struct {
+		name               string
+		code               string
+		userID             int
+		started            time.Time
+		attempts           int
+		expectedStatusCode int
+		expectedLocation   string
+		expectedBody       string
+		loggedIn           bool
+	}{
+		{"valid code", validCode, 2, time.Now(), 0, http.StatusSeeOther, "/", "", true},
+		{"valid code with spaces", validCode[:3] + " " + validCode[3:], 2, time.Now(), 0, http.StatusSeeOther, "/", "", true},
+		{"recovery code", "ABCDE-23456", 2, time.Now(), 0, http.StatusSeeOther, "/", "", true},
+		{"used recovery code", "fghjk-23456", 2, time.Now(), 0, http.StatusOK, "", "Invalid code", false},
+		{"invalid code", "000000", 2, time.Now(), 0, http.StatusOK, "", "Invalid code", false},
+		{"too many attempts", "000000", 2, time.Now(), 4, http.StatusSeeOther, "/user/login", "", false},
+		{"expired", validCode, 2, time.Now().Add(-time.Hour), 0, http.StatusSeeOther, "/user/login", "", false},
+		{"no password", validCode, 0, time.Now(), 0, http.StatusSeeOther, "/user/login", "", false},
+	}
+
+	// the code step is throttled like the password, a locked IP address can't log in with a valid code
+	req, _ := http.NewRequest("POST", "/user/login/two-factor", strings.NewReader(url.Values{"code": {validCode}}.Encode()))
+	ctx := getCtx(req)
+	req = req.WithContext(ctx)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	session.Put(ctx, "two_factor_user_id", 2)
+	session.Put(ctx, "two_factor_started", time.Now().Unix())
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(Repo.PostLoginTwoFactor).ServeHTTP(rr, req)
+
+	if msg := session.PopString(ctx, "error"); !strings.HasPrefix(msg, "Too many failed logins") || session.Exists(ctx, "user_id") {
+		t.Errorf("expected a locked IP address not to log in with a code, got error %q", msg)
+	}
+
+	for _, tt := range tests {
+		postedData := url.Values{"code": {tt.code}}
+
+		req, _ := http.NewRequest("POST", "/user/login/two-factor", strings.NewReader(postedData.Encode()))
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		if tt.userID > 0 {
+			session.Put(ctx, "two_factor_user_id", tt.userID)
+			session.Put(ctx, "two_factor_started", tt.started.Unix())
+			session.Put(ctx, "two_factor_attempts", tt.attempts)
+		}
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.PostLoginTwoFactor)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != tt.expectedStatusCode {
+			t.Errorf("for %s: got status code %d, wanted %d", tt.name, rr.Code, tt.expectedStatusCode)
+		}
+
+		if tt.expectedLocation != "" {
+			actualLocation, _ := rr.Result().Location()
+			if actualLocation.String() != tt.expectedLocation {
+				t.Errorf("for %s: got location %s, wanted %s", tt.name, actualLocation.String(), tt.expectedLocation)
+			}
+		}
+
+		if tt.expectedBody != "" && !strings.Contains(rr.Body.String(), tt.expectedBody) {
+			t.Errorf("for %s: expected body to contain %s", tt.name, tt.expectedBody)
+		}
+
+		if session.Exists(ctx, "user_id") != tt.loggedIn {
+			t.Errorf("for %s: expected logged in to be %t", tt.name, tt.loggedIn)
+		}
+	}
+}
+
+// TestRepository_AdminPostTwoFactor tests turning on two-factor authentication with the code of the QR code's key
+func TestRepository_AdminPostTwoFactor(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/admin/two-factor", nil)
+	ctx := helpers.WithUser(getCtx(req), models.User{ID: 1, Email: "me@here.com"})
+	req = req.WithContext(ctx)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(Repo.AdminTwoFactor)
+	handler.ServeHTTP(rr, req)
+
+	if !strings.Contains(rr.Body.String(), "data:image/png;base64,") {
+		t.Error("expected a QR code")
+	}
+
+	key, err := otp.NewKeyFromURL(session.GetString(ctx, "two_factor_key"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	validCode, _ := totp.GenerateCode(key.Secret(), time.Now())
+
+	var tests = []struct {
+		name         string
+		code         string
+		expectedBody string
+	}{
+		{"invalid code", "000000", "Invalid code"},
+		{"valid code", validCode, "Save these recovery codes"},
+	}
+
+	for _, tt := range tests {
+		postedData := url.Values{"code": {tt.code}}
+
+		req, _ := http.NewRequest("POST", "/admin/two-factor", strings.NewReader(postedData.Encode()))
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminPostTwoFactor)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("for %s: got status code %d, wanted %d", tt.name, rr.Code, http.StatusOK)
+		}
+
+		if !strings.Contains(rr.Body.String(), tt.expectedBody) {
+			t.Errorf("for %s: expected body to contain %s", tt.name, tt.expectedBody)
+		}
+	}
+
+	if session.Exists(ctx, "two_factor_key") {
+		t.Error("expected the pending key to be removed after two-factor authentication is on")
+	}
+}
+
+// TestRepository_AdminPostDisableTwoFactor tests AdminPostDisableTwoFactor handler
+func TestRepository_AdminPostDisableTwoFactor(t *testing.T) {
+	validCode, _ := totp.GenerateCode(dbrepo.TestTOTPSecret, time.Now())
+
+	var tests = []struct {
+		name        string
+		code        string
+		roles       []int
+		expectedKey string
+	}{
+		{"valid code", validCode, nil, "warning"},
+		{"invalid code", "000000", nil, "error"},
+		{"required by role", validCode, []int{models.RoleOwner}, "error"},
+	}
+
+	for _, tt := range tests {
+		app.TwoFactorRoles = tt.roles
+		postedData := url.Values{"code": {tt.code}}
+
+		req, _ := http.NewRequest("POST", "/admin/two-factor/disable", strings.NewReader(postedData.Encode()))
+		ctx := helpers.WithUser(getCtx(req), models.User{ID: 2, AccessLevel: models.RoleOwner, TOTPSecret: dbrepo.TestTOTPSecret})
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminPostDisableTwoFactor)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusSeeOther {
+			t.Errorf("for %s: got status code %d, wanted %d", tt.name, rr.Code, http.StatusSeeOther)
+		}
+
+		if session.PopString(ctx, tt.expectedKey) == "" {
+			t.Errorf("for %s: expected a %s message", tt.name, tt.expectedKey)
+		}
+	}
+
+	app.TwoFactorRoles = nil
+}
+
+// TestNewRecoveryCodes tests that recovery codes are unique and match the codes users enter
+func TestNewRecoveryCodes(t *testing.T) {
+	codes, err := newRecoveryCodes()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(codes) != recoveryCodeCount {
+		t.Errorf("got %d codes, wanted %d", len(codes), recoveryCodeCount)
+	}
+
+	seen := make(map[string]bool)
+
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' {
+			t.Errorf("unexpected code format %s", code)
+		}
+
+		if seen[code] {
+			t.Errorf("duplicate code %s", code)
+		}
+
+		seen[code] = true
+
+		if normalizeCode(strings.ToUpper(code)) != normalizeCodes([]string{code})[0] {
+			t.Errorf("expected %s to match when typed in upper case", code)
+		}
+	}
+}
+
+// TestRepository_PostShowLoginFailures tests that logins slow down and lock out after failures
+func TestRepository_PostShowLoginFailures(t *testing.T) {
+	var tests = []struct {
+		name          string
+		email         string
+		password      string
+		remoteAddr    string
+		expectedError string
+	}{
+		{"locked account", "locked@here.com", "password", "192.0.2.1:1234", "Too many failed logins, try again in 15 minutes"},
+		{"delayed account", "slow@here.com", "password", "192.0.2.1:1234", "Too many failed logins, try again in 4 seconds"},
+		{"locked IP address", "me@here.ca", "password", "10.0.0.1:1234", "Too many failed logins"},
+		{"failure that locks the account", "taken@here.com", "wrong", "192.0.2.1:1234", "Invalid login credentials"},
+	}
+
+	for _, tt := range tests {
+		postedData := url.Values{"email": {tt.email}, "password": {tt.password}}
+
+		req, _ := http.NewRequest("POST", "/user/login", strings.NewReader(postedData.Encode()))
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+		req.RemoteAddr = tt.remoteAddr
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.PostShowLogin)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusSeeOther {
+			t.Errorf("for %s: got status code %d, wanted %d", tt.name, rr.Code, http.StatusSeeOther)
+		}
+
+		if msg := session.PopString(ctx, "error"); !strings.HasPrefix(msg, tt.expectedError) {
+			t.Errorf("for %s: got error %q, wanted %q", tt.name, msg, tt.expectedError)
+		}
+
+		if session.Exists(ctx, "user_id") {
+			t.Errorf("for %s: expected the user not to be logged in", tt.name)
+		}
+	}
+}
+
+// TestNextLoginAt tests the delays and lockouts after failed logins
+func TestNextLoginAt(t *testing.T) {
+	last := time.Date(2050, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	var tests = []struct {
+		name     string
+		account  int
+		byIP     int
+		expected time.Time
+	}{
+		{"no failures", 0, 0, time.Time{}},
+		{"few failures", 2, 2, time.Time{}},
+		{"first delay", 3, 0, last.Add(time.Second)},
+		{"doubled delay", 5, 0, last.Add(4 * time.Second)},
+		{"longest delay", 9, 0, last.Add(time.Minute)},
+		{"locked account", 10, 0, last.Add(loginLockout)},
+		{"delayed IP address", 0, 21, last.Add(2 * time.Second)},
+		{"locked IP address", 2, 100, last.Add(loginLockout)},
+	}
+
+	for _, tt := range tests {
+		got := nextLoginAt(models.LoginFailures{Count: tt.account, Last: last}, models.LoginFailures{Count: tt.byIP, Last: last})
+
+		if !got.Equal(tt.expected) {
+			t.Errorf("for %s: got %s, wanted %s", tt.name, got, tt.expected)
+		}
+	}
+}
+
+// TestRepository_AdminPostAccount tests AdminPostAccount handler
+func TestRepository_AdminPostAccount(t *testing.T) {
+	var tests = []struct {
+		name               string
+		postedData         url.Values
+		expectedStatusCode int
+		expectedFlash      string
+		expectedBody       string
+	}{
+		{
+			name:               "name change",
+			postedData:         url.Values{"first_name": {"Johnny"}, "last_name": {"Smith"}, "email": {"john@here.com"}},
+			expectedStatusCode: http.StatusSeeOther,
+			expectedFlash:      "Your profile is saved",
+		},
+		{
+			name:               "email change",
+			postedData:         url.Values{"first_name": {"John"}, "last_name": {"Smith"}, "email": {"johnny@here.com"}, "email_password": {"password"}},
+			expectedStatusCode: http.StatusSeeOther,
+			expectedFlash:      "Your profile is saved, confirm johnny@here.com with the link sent to it",
+		},
+		{
+			name:               "email change without password",
+			postedData:         url.Values{"first_name": {"John"}, "last_name": {"Smith"}, "email": {"johnny@here.com"}},
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "This field cannot be blank",
+		},
+		{
+			name:               "email change with wrong password",
+			postedData:         url.Values{"first_name": {"John"}, "last_name": {"Smith"}, "email": {"johnny@here.com"}, "email_password": {"wrong"}},
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "The password is wrong",
+		},
+		{
+			name:               "email of another user",
+			postedData:         url.Values{"first_name": {"John"}, "last_name": {"Smith"}, "email": {"taken@here.com"}, "email_password": {"password"}},
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "This email belongs to another user",
+		},
+		{
+			name:               "missing name",
+			postedData:         url.Values{"first_name": {""}, "last_name": {"Smith"}, "email": {"john@here.com"}},
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "This field cannot be blank",
+		},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest("POST", "/admin/account", strings.NewReader(tt.postedData.Encode()))
+		ctx := helpers.WithUser(getCtx(req), models.User{ID: 1, FirstName: "John", LastName: "Smith", Email: "john@here.com", Active: 1})
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminPostAccount)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != tt.expectedStatusCode {
+			t.Errorf("for %s: got status code %d, wanted %d", tt.name, rr.Code, tt.expectedStatusCode)
+		}
+
+		if flash := session.PopString(ctx, "flash"); flash != tt.expectedFlash {
+			t.Errorf("for %s: got flash %q, wanted %q", tt.name, flash, tt.expectedFlash)
+		}
+
+		if tt.expectedBody != "" && !strings.Contains(rr.Body.String(), tt.expectedBody) {
+			t.Errorf("for %s: expected body to contain %s", tt.name, tt.expectedBody)
+		}
+	}
+}
+
+// TestRepository_AdminPostAccountPassword tests AdminPostAccountPassword handler
+func TestRepository_AdminPostAccountPassword(t *testing.T) {
+	var tests = []struct {
+		name               string
+		email              string
+		postedData         url.Values
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{
+			name:               "valid password",
+			email:              "john@here.com",
+			postedData:         url.Values{"current_password": {"password"}, "password": {"correct-horse"}, "password_confirmation": {"correct-horse"}},
+			expectedStatusCode: http.StatusSeeOther,
+		},
+		{
+			name:               "wrong current password",
+			email:              "john@here.com",
+			postedData:         url.Values{"current_password": {"wrong"}, "password": {"correct-horse"}, "password_confirmation": {"correct-horse"}},
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "The password is wrong",
+		},
+		{
+			name:               "too many wrong passwords",
+			email:              "locked@here.com",
+			postedData:         url.Values{"current_password": {"password"}, "password": {"correct-horse"}, "password_confirmation": {"correct-horse"}},
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "Too many wrong passwords",
+		},
+		{
+			name:               "password with name",
+			email:              "john@here.com",
+			postedData:         url.Values{"current_password": {"password"}, "password": {"john-smith-22"}, "password_confirmation": {"john-smith-22"}},
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "contain your name or email",
+		},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest("POST", "/admin/account/password", strings.NewReader(tt.postedData.Encode()))
+		ctx := helpers.WithUser(getCtx(req), models.User{ID: 1, FirstName: "John", LastName: "Smith", Email: tt.email, Active: 1})
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminPostAccountPassword)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != tt.expectedStatusCode {
+			t.Errorf("for %s: got status code %d, wanted %d", tt.name, rr.Code, tt.expectedStatusCode)
+		}
+
+		if tt.expectedBody != "" && !strings.Contains(rr.Body.String(), tt.expectedBody) {
+			t.Errorf("for %s: expected body to contain %s", tt.name, tt.expectedBody)
+		}
+
+		if tt.expectedStatusCode == http.StatusSeeOther && !session.Exists(ctx, "password_fingerprint") {
+			t.Errorf("for %s: expected the session to keep the new password's fingerprint", tt.name)
+		}
+	}
+}
+
+// TestRepository_ConfirmEmail tests that only email change links change emails
+func TestRepository_ConfirmEmail(t *testing.T) {
+	var tests = []struct {
+		name        string
+		token       string
+		expectedKey string
+	}{
+		{"email change link", "email-token", "flash"},
+		{"invitation link", "valid-token", "error"},
+		{"invalid link", "expired", "error"},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest("GET", "/user/confirm-email?token="+tt.token, nil)
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.ConfirmEmail)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusSeeOther {
+			t.Errorf("for %s: got status code %d, wanted %d", tt.name, rr.Code, http.StatusSeeOther)
+		}
+
+		if session.PopString(ctx, tt.expectedKey) == "" {
+			t.Errorf("for %s: expected a %s message", tt.name, tt.expectedKey)
+		}
+	}
+
+	// email change links can't set passwords
+	req, _ := http.NewRequest("GET", "/user/set-password?token=email-token", nil)
+	ctx := getCtx(req)
+	req = req.WithContext(ctx)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(Repo.ShowSetPassword)
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusSeeOther {
+		t.Errorf("got status code %d for a password link with an email change token, wanted %d", rr.Code, http.StatusSeeOther)
+	}
+}
+
+// TestNotifyEscapesHTML tests that admin pages escape the messages they show, since they can contain names guests typed
+func TestNotifyEscapesHTML(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/admin/dashboard", nil)
+	ctx := getCtx(req)
+	req = req.WithContext(ctx)
+	session.Put(ctx, "warning", `New reservation: <img src=x onerror=alert(1)> Smith`)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(Repo.AdminDashboard)
+	handler.ServeHTTP(rr, req)
+
+	body := rr.Body.String()
+
+	if strings.Contains(body, "<img src=x") {
+		t.Error("expected the markup in the message to be escaped")
+	}
+
+	if !strings.Contains(body, "text: escapeHTML(msg)") {
+		t.Error("expected notify to escape its messages before notie renders them")
+	}
+}
+
+// TestRepository_AdminPostResetTwoFactor tests AdminPostResetTwoFactor handler
+func TestRepository_AdminPostResetTwoFactor(t *testing.T) {
+	var tests = []struct {
+		name               string
+		url                string
+		expectedStatusCode int
+		expectedLocation   string
+	}{
+		{"user", "/admin/reset-two-factor/2", http.StatusSeeOther, "/admin/users/2/show"},
+		{"missing user", "/admin/reset-two-factor/3", http.StatusInternalServerError, ""},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest("POST", tt.url, nil)
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+		req.RequestURI = tt.url
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminPostResetTwoFactor)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != tt.expectedStatusCode {
+			t.Errorf("for %s: got status code %d, wanted %d", tt.name, rr.Code, tt.expectedStatusCode)
+		}
+
+		if tt.expectedLocation != "" {
+			actualLocation, _ := rr.Result().Location()
+			if actualLocation.String() != tt.expectedLocation {
+				t.Errorf("for %s: got location %s, wanted %s", tt.name, actualLocation.String(), tt.expectedLocation)
+			}
+		}
+	}
+}
+
+// TestRepository_AdminPostResetUserPassword tests AdminPostResetUserPassword handler
+func TestRepository_AdminPostResetUserPassword(t *testing.T) {
+	var tests = []struct {
+		name               string
+		url                string
+		expectedStatusCode int
+		expectedLocation   string
+	}{
+		{"user", "/admin/reset-users/2", http.StatusSeeOther, "/admin/users/2/show"},
+		{"missing user", "/admin/reset-users/3", http.StatusInternalServerError, ""},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest("POST", tt.url, nil)
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+		req.RequestURI = tt.url
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminPostResetUserPassword)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != tt.expectedStatusCode {
+			t.Errorf("for %s: got status code %d, wanted %d", tt.name, rr.Code, tt.expectedStatusCode)
+		}
+
+		if tt.expectedLocation != "" {
+			actualLocation, _ := rr.Result().Location()
+			if actualLocation.String() != tt.expectedLocation {
+				t.Errorf("for %s: got location %s, wanted %s", tt.name, actualLocation.String(), tt.expectedLocation)
+			}
+		}
+	}
+}
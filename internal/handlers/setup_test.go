@@ -13,6 +13,8 @@ import (
 
 	"github.com/alexedwards/scs/v2"
 	"github.com/burakkarasel/bookings/internal/config"
+	"github.com/burakkarasel/bookings/internal/helpers"
+	"github.com/burakkarasel/bookings/internal/live"
 	"github.com/burakkarasel/bookings/internal/models"
 	"github.com/burakkarasel/bookings/internal/utils"
 	"github.com/go-chi/chi"
@@ -35,6 +37,7 @@ var functions = template.FuncMap{
 func TestMain(m *testing.M) {
 	// main.go
 	app.InProduction = false
+	app.BaseURL = "https://bookings.test"
 
 	// We used gob here to keep non-primitive types in our session
 	gob.Register(models.Reservation{})
@@ -65,6 +68,14 @@ func TestMain(m *testing.M) {
 
 	listenForMail()
 
+	eventChan := make(chan models.Event)
+	app.EventChan = eventChan
+	defer close(eventChan)
+
+	app.Broker = live.NewBroker()
+
+	listenForEvents()
+
 	tc, err := CreateTestTemplateCache()
 
 	if err != nil {
@@ -80,6 +91,7 @@ func TestMain(m *testing.M) {
 	NewHandlers(repo)
 
 	utils.NewRenderer(&app)
+	helpers.NewHelpers(&app)
 
 	os.Exit(m.Run())
 }
@@ -117,22 +129,82 @@ func getRoutes() http.Handler {
 	mux.Get("/user/login", Repo.ShowLogin)
 	mux.Post("/user/login", Repo.PostShowLogin)
 	mux.Get("/user/logout", Repo.Logout)
+	mux.Get("/user/set-password", Repo.ShowSetPassword)
+	mux.Post("/user/set-password", Repo.PostSetPassword)
+	mux.Get("/user/forgot-password", Repo.ShowForgotPassword)
+	mux.Post("/user/forgot-password", Repo.PostForgotPassword)
+	mux.Get("/user/login/two-factor", Repo.ShowLoginTwoFactor)
+	mux.Post("/user/login/two-factor", Repo.PostLoginTwoFactor)
+	mux.Get("/user/confirm-email", Repo.ConfirmEmail)
+
+	mux.Get("/ical/rooms/{id}.ics", Repo.RoomICalFeed)
 
 	fileServer := http.FileServer(http.Dir("./static/"))
 	mux.Handle("/static/*", http.StripPrefix("/static", fileServer))
 
 	mux.Get("/admin/dashboard", Repo.AdminDashboard)
+	mux.Get("/admin/events", Repo.AdminLiveEvents)
+	mux.Get("/admin/account", Repo.AdminAccount)
+	mux.Post("/admin/account", Repo.AdminPostAccount)
+	mux.Post("/admin/account/password", Repo.AdminPostAccountPassword)
+	mux.Get("/admin/two-factor", Repo.AdminTwoFactor)
+	mux.Post("/admin/two-factor", Repo.AdminPostTwoFactor)
+	mux.Post("/admin/two-factor/recovery-codes", Repo.AdminPostRecoveryCodes)
+	mux.Post("/admin/two-factor/disable", Repo.AdminPostDisableTwoFactor)
 
 	mux.Get("/admin/reservations-new", Repo.AdminNewReservations)
 	mux.Get("/admin/reservations-all", Repo.AdminAllReservations)
+	mux.Post("/admin/reservations-bulk", Repo.AdminPostBulkReservations)
+	mux.Get("/admin/reservations-create", Repo.AdminNewReservation)
+	mux.Post("/admin/reservations-create", Repo.AdminPostNewReservation)
+	mux.Get("/admin/reservations-export", Repo.AdminExportReservations)
+	mux.Get("/admin/reservations-import", Repo.AdminImportReservations)
+	mux.Post("/admin/reservations-import", Repo.AdminPostImportReservations)
 	mux.Get("/admin/reservations-calendar", Repo.AdminReservationsCalendar)
 	mux.Post("/admin/reservations-calendar", Repo.AdminPostReservationsCalendar)
+	mux.Get("/admin/reservations-timeline", Repo.AdminReservationsTimeline)
+	mux.Get("/admin/reports-daily", Repo.AdminDailyReport)
+	mux.Get("/admin/search", Repo.AdminSearch)
 
 	mux.Get("/admin/process-reservations/{src}/{id}/do", Repo.AdminProcessedReservation)
 	mux.Get("/admin/delete-reservations/{src}/{id}/do", Repo.AdminDeleteReservation)
 
 	mux.Get("/admin/reservations/{src}/{id}/show", Repo.AdminShowReservationDetail)
 	mux.Post("/admin/reservations/{src}/{id}", Repo.AdminPostShowReservationDetail)
+	mux.Post("/admin/reservations/{src}/{id}/notes", Repo.AdminPostReservationNote)
+
+	mux.Get("/admin/guests", Repo.AdminGuests)
+	mux.Get("/admin/guests/{id}/show", Repo.AdminShowGuest)
+	mux.Post("/admin/guests/{id}", Repo.AdminPostShowGuest)
+	mux.Post("/admin/merge-guests/{id}", Repo.AdminPostMergeGuest)
+
+	mux.Get("/admin/rooms", Repo.AdminRooms)
+	mux.Get("/admin/rooms/{id}/show", Repo.AdminShowRoom)
+	mux.Post("/admin/rooms/{id}", Repo.AdminPostShowRoom)
+	mux.Get("/admin/move-rooms/{id}/do", Repo.AdminMoveRoom)
+
+	mux.Get("/admin/users", Repo.AdminUsers)
+	mux.Get("/admin/users/{id}/show", Repo.AdminShowUser)
+	mux.Post("/admin/users/{id}", Repo.AdminPostShowUser)
+	mux.Post("/admin/reset-users/{id}", Repo.AdminPostResetUserPassword)
+	mux.Post("/admin/reset-two-factor/{id}", Repo.AdminPostResetTwoFactor)
+	mux.Post("/admin/unlock-logins", Repo.AdminPostUnlockLogins)
+
+	mux.Get("/admin/webhooks", Repo.AdminWebhooks)
+	mux.Get("/admin/webhooks/{id}/show", Repo.AdminShowWebhook)
+	mux.Post("/admin/webhooks/{id}", Repo.AdminPostShowWebhook)
+	mux.Post("/admin/delete-webhooks/{id}", Repo.AdminPostDeleteWebhook)
+
+	mux.Get("/admin/ical-feeds", Repo.AdminICalFeeds)
+	mux.Post("/admin/ical-feeds", Repo.AdminPostICalFeeds)
+	mux.Post("/admin/delete-ical-feeds/{id}", Repo.AdminPostDeleteICalFeed)
+
+	mux.Get("/admin/ical-imports", Repo.AdminICalImports)
+	mux.Post("/admin/ical-imports", Repo.AdminPostICalImports)
+	mux.Post("/admin/sync-ical-imports/{id}", Repo.AdminPostSyncICalImport)
+	mux.Post("/admin/delete-ical-imports/{id}", Repo.AdminPostDeleteICalImport)
+
+	mux.Get("/admin/audit-log", Repo.AdminAuditLog)
 
 	return mux
 }
@@ -200,3 +272,13 @@ func listenForMail() {
 		}
 	}()
 }
+
+// listenForEvents lets us to implement event functionality to our test file
+func listenForEvents() {
+	go func() {
+		for {
+			e := <-app.EventChan
+			app.Broker.Publish(e)
+		}
+	}()
+}
@@ -1,11 +1,16 @@
 package helpers
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"net/http"
 	"runtime/debug"
 
 	"github.com/burakkarasel/bookings/internal/config"
+	"github.com/burakkarasel/bookings/internal/models"
 )
 
 var app *config.AppConfig
@@ -33,3 +38,53 @@ func IsAuthenticated(r *http.Request) bool {
 	exists := app.Session.Exists(r.Context(), "user_id")
 	return exists
 }
+
+// contextKey is the type of the keys we put into request contexts
+type contextKey string
+
+// userContextKey is the key of the logged-in user in request contexts
+const userContextKey contextKey = "user"
+
+// WithUser returns a copy of the context that carries the logged-in user
+func WithUser(ctx context.Context, u models.User) context.Context {
+	return context.WithValue(ctx, userContextKey, u)
+}
+
+// CurrentUser returns the logged-in user that is loaded into the request's context by the Auth middleware, it returns
+// an empty user with no permissions for the public pages
+func CurrentUser(r *http.Request) models.User {
+	u, _ := r.Context().Value(userContextKey).(models.User)
+	return u
+}
+
+// RandomToken returns a random hex encoded string, we use it for secrets and tokens that are shared in URLs
+func RandomToken() (string, error) {
+	b := make([]byte, 32)
+
+	_, err := rand.Read(b)
+
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// PasswordFingerprint returns a short hash of the user's password hash, sessions keep it when users log in, so they end
+// when the password changes
+func PasswordFingerprint(passwordHash string) string {
+	sum := sha256.Sum256([]byte(passwordHash))
+
+	return hex.EncodeToString(sum[:8])
+}
+
+// RequiresTwoFactor returns true if the user's role must use two-factor authentication
+func RequiresTwoFactor(u models.User) bool {
+	for _, accessLevel := range app.TwoFactorRoles {
+		if accessLevel == u.AccessLevel {
+			return true
+		}
+	}
+
+	return false
+}
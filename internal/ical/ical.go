@@ -0,0 +1,246 @@
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const dateLayout = "20060102"
+const dateTimeLayout = "20060102T150405Z"
+
+// maxLineLength is the maximum length of a content line in octets, longer lines are folded
+const maxLineLength = 75
+
+// Calendar holds the data of a VCALENDAR object
+type Calendar struct {
+	ProdID string
+	Name   string
+	Events []Event
+}
+
+// Event holds the data of an all day VEVENT object, End is exclusive like the end_date of a room restriction
+type Event struct {
+	UID          string
+	Summary      string
+	Description  string
+	Status       string
+	Start        time.Time
+	End          time.Time
+	Stamp        time.Time
+	LastModified time.Time
+}
+
+// Encode writes the calendar to w in iCalendar format
+func (c Calendar) Encode(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	writeLine(bw, "BEGIN:VCALENDAR")
+	writeLine(bw, "VERSION:2.0")
+	writeLine(bw, "PRODID:"+c.ProdID)
+	writeLine(bw, "CALSCALE:GREGORIAN")
+	writeLine(bw, "METHOD:PUBLISH")
+
+	if c.Name != "" {
+		writeLine(bw, "X-WR-CALNAME:"+Escape(c.Name))
+	}
+
+	for _, e := range c.Events {
+		writeLine(bw, "BEGIN:VEVENT")
+		writeLine(bw, "UID:"+e.UID)
+		writeLine(bw, "DTSTAMP:"+e.Stamp.UTC().Format(dateTimeLayout))
+		writeLine(bw, "DTSTART;VALUE=DATE:"+e.Start.Format(dateLayout))
+		writeLine(bw, "DTEND;VALUE=DATE:"+e.End.Format(dateLayout))
+		writeLine(bw, "SUMMARY:"+Escape(e.Summary))
+
+		if e.Description != "" {
+			writeLine(bw, "DESCRIPTION:"+Escape(e.Description))
+		}
+
+		if !e.LastModified.IsZero() {
+			writeLine(bw, "LAST-MODIFIED:"+e.LastModified.UTC().Format(dateTimeLayout))
+		}
+
+		writeLine(bw, "TRANSP:OPAQUE")
+		writeLine(bw, "END:VEVENT")
+	}
+
+	writeLine(bw, "END:VCALENDAR")
+
+	return bw.Flush()
+}
+
+// writeLine writes a content line ending with CRLF, and folds it if it is longer than 75 octets
+func writeLine(w *bufio.Writer, line string) {
+	for len(line) > maxLineLength {
+		// we don't want to split a multi byte character, so we move back to the start of it
+		cut := maxLineLength
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+
+		fmt.Fprintf(w, "%s\r\n", line[:cut])
+		// folded lines start with a space, and it counts in the length of the line
+		line = " " + line[cut:]
+	}
+
+	fmt.Fprintf(w, "%s\r\n", line)
+}
+
+// Escape escapes the characters that has a meaning in iCalendar text values
+func Escape(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+	return r.Replace(s)
+}
+
+// Parse reads the VEVENTs of an iCalendar stream, all day and timed events are both turned into dates
+func Parse(r io.Reader) ([]Event, error) {
+	lines, err := unfold(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var events []Event
+	var current *Event
+
+	for _, line := range lines {
+		name, params, value := splitProperty(line)
+
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			current = &Event{}
+		case name == "END" && value == "VEVENT":
+			if current == nil {
+				continue
+			}
+			if current.End.IsZero() || !current.End.After(current.Start) {
+				current.End = current.Start.AddDate(0, 0, 1)
+			}
+			if current.UID != "" && !current.Start.IsZero() {
+				events = append(events, *current)
+			}
+			current = nil
+		case current == nil:
+			continue
+		case name == "UID":
+			current.UID = value
+		case name == "SUMMARY":
+			current.Summary = Unescape(value)
+		case name == "DESCRIPTION":
+			current.Description = Unescape(value)
+		case name == "STATUS":
+			current.Status = strings.ToUpper(value)
+		case name == "DTSTART":
+			current.Start, err = parseDate(value, params)
+			if err != nil {
+				return nil, err
+			}
+		case name == "DTEND":
+			current.End, err = parseDate(value, params)
+			if err != nil {
+				return nil, err
+			}
+		case name == "LAST-MODIFIED":
+			current.LastModified, _ = parseDate(value, params)
+		}
+	}
+
+	return events, nil
+}
+
+// unfold reads the content lines, and joins the folded ones back together
+func unfold(r io.Reader) ([]string, error) {
+	var lines []string
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return lines, nil
+}
+
+// splitProperty splits a content line into its name, parameters and value, colons in quoted parameters are skipped
+func splitProperty(line string) (string, map[string]string, string) {
+	inQuotes := false
+	colon := -1
+
+	for i, c := range line {
+		if c == '"' {
+			inQuotes = !inQuotes
+		} else if c == ':' && !inQuotes {
+			colon = i
+			break
+		}
+	}
+
+	if colon < 0 {
+		return strings.ToUpper(line), nil, ""
+	}
+
+	parts := strings.Split(line[:colon], ";")
+	params := make(map[string]string)
+
+	for _, p := range parts[1:] {
+		if kv := strings.SplitN(p, "=", 2); len(kv) == 2 {
+			params[strings.ToUpper(kv[0])] = strings.Trim(kv[1], `"`)
+		}
+	}
+
+	return strings.ToUpper(parts[0]), params, line[colon+1:]
+}
+
+// parseDate parses DATE and DATE-TIME values, and returns the date of it in the event's time zone
+func parseDate(value string, params map[string]string) (time.Time, error) {
+	if params["VALUE"] == "DATE" || len(value) == len(dateLayout) {
+		return time.Parse(dateLayout, value)
+	}
+
+	loc := time.UTC
+	if tz, ok := params["TZID"]; ok {
+		if l, err := time.LoadLocation(tz); err == nil {
+			loc = l
+		}
+	}
+
+	var t time.Time
+	var err error
+
+	if strings.HasSuffix(value, "Z") {
+		t, err = time.Parse(dateTimeLayout, value)
+	} else {
+		t, err = time.ParseInLocation("20060102T150405", value, loc)
+	}
+
+	if err != nil {
+		return t, err
+	}
+
+	y, m, d := t.Date()
+
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC), nil
+}
+
+// Unescape turns escaped characters of a text value back to their original form
+func Unescape(s string) string {
+	r := strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+	return r.Replace(s)
+}
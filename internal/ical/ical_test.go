@@ -0,0 +1,164 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestCalendar_Encode checks if the calendar is written with CRLF line endings and all day events
+func TestCalendar_Encode(t *testing.T) {
+	cal := Calendar{
+		ProdID: "-//Fort Smythe//Bookings//EN",
+		Name:   "General's Quarters",
+		Events: []Event{
+			{
+				UID:     "reservation-1@fort-smythe",
+				Summary: "Smith, John",
+				Start:   time.Date(2022, 7, 20, 0, 0, 0, 0, time.UTC),
+				End:     time.Date(2022, 7, 23, 0, 0, 0, 0, time.UTC),
+				Stamp:   time.Date(2022, 7, 1, 10, 30, 0, 0, time.UTC),
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+
+	err := cal.Encode(&buf)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+
+	for _, expected := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"UID:reservation-1@fort-smythe\r\n",
+		"DTSTART;VALUE=DATE:20220720\r\n",
+		"DTEND;VALUE=DATE:20220723\r\n",
+		"DTSTAMP:20220701T103000Z\r\n",
+		"SUMMARY:Smith\\, John\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected output to contain %q, got %q", expected, out)
+		}
+	}
+}
+
+// TestWriteLine checks if long lines are folded at 75 octets
+func TestWriteLine(t *testing.T) {
+	var buf bytes.Buffer
+
+	cal := Calendar{ProdID: "x", Name: strings.Repeat("a", 200)}
+
+	err := cal.Encode(&buf)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range strings.Split(buf.String(), "\r\n") {
+		if len(line) > maxLineLength {
+			t.Errorf("line is longer than %d octets: %q", maxLineLength, line)
+		}
+	}
+}
+
+// TestEscape checks if special characters are escaped
+func TestEscape(t *testing.T) {
+	got := Escape("late arrival; room 1, 2\nthanks\\")
+	expected := `late arrival\; room 1\, 2\nthanks\\`
+
+	if got != expected {
+		t.Errorf("got %s, wanted %s", got, expected)
+	}
+}
+
+// TestParse checks if folded lines, all day and timed events are parsed
+func TestParse(t *testing.T) {
+	feed := "BEGIN:VCALENDAR\r\n" +
+		"VERSION:2.0\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:abc@airbnb.com\r\n" +
+		"DTSTART;VALUE=DATE:20220801\r\n" +
+		"DTEND;VALUE=DATE:20220805\r\n" +
+		"SUMMARY:Reserved\\, thanks\r\n" +
+		"DESCRIPTION:a very long description that is folded by\r\n" +
+		"  the publisher\r\n" +
+		"END:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:def@booking.com\r\n" +
+		"DTSTART;TZID=\"Europe/Istanbul\":20220810T140000\r\n" +
+		"DTEND:20220812T090000Z\r\n" +
+		"STATUS:cancelled\r\n" +
+		"END:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:ghi@booking.com\r\n" +
+		"DTSTART;VALUE=DATE:20220820\r\n" +
+		"END:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+
+	events, err := Parse(strings.NewReader(feed))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 3 {
+		t.Fatalf("got %d events, wanted 3", len(events))
+	}
+
+	if events[0].UID != "abc@airbnb.com" || events[0].Summary != "Reserved, thanks" {
+		t.Errorf("got wrong first event %+v", events[0])
+	}
+
+	if events[0].Description != "a very long description that is folded by the publisher" {
+		t.Errorf("got wrong unfolded description %q", events[0].Description)
+	}
+
+	if events[0].Start.Format(dateLayout) != "20220801" || events[0].End.Format(dateLayout) != "20220805" {
+		t.Errorf("got wrong dates %s - %s", events[0].Start, events[0].End)
+	}
+
+	if events[1].Start.Format(dateLayout) != "20220810" || events[1].End.Format(dateLayout) != "20220812" {
+		t.Errorf("got wrong dates for timed event %s - %s", events[1].Start, events[1].End)
+	}
+
+	if events[1].Status != "CANCELLED" {
+		t.Errorf("got status %s, wanted CANCELLED", events[1].Status)
+	}
+
+	// an event without an end lasts one day
+	if events[2].End.Format(dateLayout) != "20220821" {
+		t.Errorf("got end %s, wanted 20220821", events[2].End.Format(dateLayout))
+	}
+}
+
+// TestParseEncoded checks if a calendar we encode can be parsed back
+func TestParseEncoded(t *testing.T) {
+	cal := Calendar{
+		ProdID: "x",
+		Events: []Event{{
+			UID:     "block-1@fort-smythe",
+			Summary: "Owner; Block, " + strings.Repeat("long ", 30),
+			Start:   time.Date(2022, 7, 20, 0, 0, 0, 0, time.UTC),
+			End:     time.Date(2022, 7, 21, 0, 0, 0, 0, time.UTC),
+		}},
+	}
+
+	var buf bytes.Buffer
+	_ = cal.Encode(&buf)
+
+	events, err := Parse(&buf)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 1 || events[0].Summary != cal.Events[0].Summary {
+		t.Errorf("got %+v, wanted %+v", events, cal.Events)
+	}
+}
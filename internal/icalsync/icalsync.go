@@ -0,0 +1,194 @@
+package icalsync
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/burakkarasel/bookings/internal/config"
+	"github.com/burakkarasel/bookings/internal/ical"
+	"github.com/burakkarasel/bookings/internal/models"
+	"github.com/burakkarasel/bookings/internal/repository"
+)
+
+// maxFeedSize is the maximum size of a calendar we download, feeds of a single room are much smaller than this
+const maxFeedSize = 5 << 20
+
+// Syncer syncs external calendars into the External blocks of rooms
+type Syncer struct {
+	App    *config.AppConfig
+	DB     repository.DatabaseRepo
+	Client *http.Client
+}
+
+// Result holds what a sync changed, and the events that overlap with our reservations
+type Result struct {
+	Created   int
+	Updated   int
+	Removed   int
+	Conflicts []string
+}
+
+// NewSyncer creates a new syncer
+func NewSyncer(a *config.AppConfig, db repository.DatabaseRepo) *Syncer {
+	return &Syncer{
+		App:    a,
+		DB:     db,
+		Client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// SyncAll syncs all of the calendar imports, errors are saved to each import, so one broken feed doesn't stop others
+func (s *Syncer) SyncAll() {
+	imports, err := s.DB.AllICalImports()
+
+	if err != nil {
+		s.App.ErrorLog.Println(err)
+		return
+	}
+
+	for _, i := range imports {
+		_, err := s.Sync(i)
+
+		if err != nil {
+			s.App.ErrorLog.Printf("cannot sync calendar import %d: %s", i.ID, err)
+		}
+	}
+}
+
+// Sync reads the events of the import, and creates, moves or removes its blocks, so they match the events. The result
+// is saved to the import even if the sync fails
+func (s *Syncer) Sync(i models.ICalImport) (Result, error) {
+	result, err := s.sync(i)
+
+	i.LastSyncedAt = time.Now()
+	i.LastError = ""
+	i.Conflicts = result.Conflicts
+
+	if err != nil {
+		i.LastError = err.Error()
+	}
+
+	if updateErr := s.DB.UpdateICalImportSync(i); updateErr != nil && err == nil {
+		err = updateErr
+	}
+
+	return result, err
+}
+
+// sync does the actual work of Sync
+func (s *Syncer) sync(i models.ICalImport) (Result, error) {
+	var result Result
+
+	events, err := s.events(i)
+
+	if err != nil {
+		return result, err
+	}
+
+	blocks, err := s.DB.ExternalBlocksForImport(i.ID)
+
+	if err != nil {
+		return result, err
+	}
+
+	existing := make(map[string]models.RoomRestriction)
+	for _, b := range blocks {
+		existing[b.ExternalUID] = b
+	}
+
+	seen := make(map[string]bool)
+
+	for _, e := range events {
+		if e.Status == "CANCELLED" || seen[e.UID] {
+			continue
+		}
+		seen[e.UID] = true
+
+		if b, ok := existing[e.UID]; ok {
+			if !b.StartDate.Equal(e.Start) || !b.EndDate.Equal(e.End) {
+				err = s.DB.UpdateExternalBlockForRoom(b.ID, e.Start, e.End)
+				if err != nil {
+					return result, err
+				}
+				result.Updated++
+			}
+		} else {
+			err = s.DB.InsertExternalBlockForRoom(i.ID, i.RoomID, e.UID, e.Start, e.End)
+			if err != nil {
+				return result, err
+			}
+			result.Created++
+		}
+
+		conflicts, err := s.conflicts(i.RoomID, e)
+
+		if err != nil {
+			return result, err
+		}
+
+		result.Conflicts = append(result.Conflicts, conflicts...)
+	}
+
+	// the events that are not in the feed anymore are cancelled on the external site
+	for uid, b := range existing {
+		if seen[uid] {
+			continue
+		}
+
+		err = s.DB.RemoveBlockForRoom(b.ID)
+
+		if err != nil {
+			return result, err
+		}
+		result.Removed++
+	}
+
+	return result, nil
+}
+
+// events downloads the import's feed, or reads the uploaded file if it has no URL
+func (s *Syncer) events(i models.ICalImport) ([]ical.Event, error) {
+	if i.URL == "" {
+		return ical.Parse(strings.NewReader(i.Content))
+	}
+
+	resp, err := s.Client.Get(i.URL)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("feed responded with %s", resp.Status)
+	}
+
+	return ical.Parse(io.LimitReader(resp.Body, maxFeedSize))
+}
+
+// conflicts returns a message for each of our reservations that overlaps with the event
+func (s *Syncer) conflicts(roomID int, e ical.Event) ([]string, error) {
+	var conflicts []string
+
+	// restrictions are searched with an inclusive end, but the event's end is the day the guest leaves
+	restrictions, err := s.DB.GetRestrictionsForRoomByDate(roomID, e.Start, e.End.AddDate(0, 0, -1))
+
+	if err != nil {
+		return conflicts, err
+	}
+
+	for _, r := range restrictions {
+		if r.ReservationID == 0 {
+			continue
+		}
+
+		conflicts = append(conflicts, fmt.Sprintf("%s (%s - %s) overlaps with reservation #%d",
+			e.UID, e.Start.Format("2006-01-02"), e.End.Format("2006-01-02"), r.ReservationID))
+	}
+
+	return conflicts, nil
+}
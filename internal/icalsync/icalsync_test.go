@@ -0,0 +1,101 @@
+package icalsync
+
+import (
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/burakkarasel/bookings/internal/config"
+	"github.com/burakkarasel/bookings/internal/models"
+	"github.com/burakkarasel/bookings/internal/repository/dbrepo"
+)
+
+var testApp config.AppConfig
+
+// feed has a moved event, a new event and a cancelled event, the testing repo has the blocks moved@external and
+// removed@external for every import
+const feed = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:moved@external\r\n" +
+	"DTSTART;VALUE=DATE:20220802\r\n" +
+	"DTEND;VALUE=DATE:20220804\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:new@external\r\n" +
+	"DTSTART;VALUE=DATE:20220820\r\n" +
+	"DTEND;VALUE=DATE:20220822\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:cancelled@external\r\n" +
+	"DTSTART;VALUE=DATE:20220825\r\n" +
+	"DTEND;VALUE=DATE:20220826\r\n" +
+	"STATUS:CANCELLED\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+// TestMain builds environment to run our tests for icalsync.go
+func TestMain(m *testing.M) {
+	testApp.InfoLog = log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
+	testApp.ErrorLog = log.New(os.Stdout, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
+
+	os.Exit(m.Run())
+}
+
+// TestSyncer_Sync syncs a feed served by a test server
+func TestSyncer_Sync(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/calendar")
+		_, _ = w.Write([]byte(feed))
+	}))
+	defer srv.Close()
+
+	s := NewSyncer(&testApp, dbrepo.NewTestingRepo(&testApp))
+
+	result, err := s.Sync(models.ICalImport{ID: 1, RoomID: 1, URL: srv.URL})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Created != 1 || result.Updated != 1 || result.Removed != 1 {
+		t.Errorf("got created %d, updated %d, removed %d, wanted 1 for each", result.Created, result.Updated, result.Removed)
+	}
+
+	if len(result.Conflicts) != 0 {
+		t.Errorf("got %d conflicts, wanted none", len(result.Conflicts))
+	}
+}
+
+// TestSyncer_SyncConflicts syncs an uploaded file into a room that has a reservation at the same dates
+func TestSyncer_SyncConflicts(t *testing.T) {
+	s := NewSyncer(&testApp, dbrepo.NewTestingRepo(&testApp))
+
+	result, err := s.Sync(models.ICalImport{ID: 1, RoomID: 2, Content: feed})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Conflicts) != 2 {
+		t.Errorf("got %d conflicts, wanted 2", len(result.Conflicts))
+	}
+}
+
+// TestSyncer_SyncFeedError checks if an unreachable feed returns an error
+func TestSyncer_SyncFeedError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	s := NewSyncer(&testApp, dbrepo.NewTestingRepo(&testApp))
+
+	_, err := s.Sync(models.ICalImport{ID: 1, RoomID: 1, URL: srv.URL})
+
+	if err == nil {
+		t.Error("expected an error for a feed that responds with 404")
+	}
+}
@@ -0,0 +1,67 @@
+package live
+
+import (
+	"sync"
+
+	"github.com/burakkarasel/bookings/internal/models"
+)
+
+// clientBuffer is how many events a client can fall behind, events are dropped for slower clients
+const clientBuffer = 16
+
+// Broker passes the events to the admin pages that are listening for live updates
+type Broker struct {
+	mu      sync.Mutex
+	clients map[chan models.Event]bool
+}
+
+// NewBroker creates a new broker
+func NewBroker() *Broker {
+	return &Broker{
+		clients: make(map[chan models.Event]bool),
+	}
+}
+
+// Subscribe returns a channel that receives all events published after it, it must be unsubscribed when the client
+// leaves
+func (b *Broker) Subscribe() chan models.Event {
+	ch := make(chan models.Event, clientBuffer)
+
+	b.mu.Lock()
+	b.clients[ch] = true
+	b.mu.Unlock()
+
+	return ch
+}
+
+// Unsubscribe stops sending events to the channel and closes it
+func (b *Broker) Unsubscribe(ch chan models.Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.clients[ch] {
+		delete(b.clients, ch)
+		close(ch)
+	}
+}
+
+// Publish sends the event to all clients, it never blocks, so a stuck browser tab can't stop the webhooks
+func (b *Broker) Publish(e models.Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.clients {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// Clients returns the number of subscribed clients
+func (b *Broker) Clients() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return len(b.clients)
+}
@@ -0,0 +1,48 @@
+package live
+
+import (
+	"testing"
+
+	"github.com/burakkarasel/bookings/internal/models"
+)
+
+// TestBroker checks if events are sent to subscribed clients only
+func TestBroker(t *testing.T) {
+	b := NewBroker()
+
+	first := b.Subscribe()
+	second := b.Subscribe()
+
+	if b.Clients() != 2 {
+		t.Errorf("got %d clients, wanted 2", b.Clients())
+	}
+
+	b.Unsubscribe(second)
+	b.Unsubscribe(second)
+
+	b.Publish(models.Event{Name: models.EventBlockAdded})
+
+	e := <-first
+
+	if e.Name != models.EventBlockAdded {
+		t.Errorf("got event %s, wanted %s", e.Name, models.EventBlockAdded)
+	}
+
+	if _, ok := <-second; ok {
+		t.Error("expected unsubscribed channel to be closed")
+	}
+}
+
+// TestBroker_PublishSlowClient checks if publishing doesn't block when a client doesn't read its events
+func TestBroker_PublishSlowClient(t *testing.T) {
+	b := NewBroker()
+	ch := b.Subscribe()
+
+	for i := 0; i < clientBuffer*2; i++ {
+		b.Publish(models.Event{Name: models.EventBlockAdded})
+	}
+
+	if len(ch) != clientBuffer {
+		t.Errorf("got %d buffered events, wanted %d", len(ch), clientBuffer)
+	}
+}
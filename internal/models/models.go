@@ -1,6 +1,7 @@
 package models
 
 import (
+	"strings"
 	"time"
 )
 
@@ -10,57 +11,131 @@ type User struct {
 	FirstName   string
 	LastName    string
 	Email       string
-	Password    string
+	Password    string `json:"-"`
 	AccessLevel int
+	Active      int
+	TOTPSecret  string `json:"-"`
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
 }
 
-// Room is the room model
-type Room struct {
+// TwoFactorEnabled returns true if the user has to enter a code of an authenticator app after the password, the
+// secret is only saved after the user confirms it with a code
+func (u User) TwoFactorEnabled() bool {
+	return u.TOTPSecret != ""
+}
+
+// Invited returns true if the user hasn't set a password yet, invited users and users that are forced to reset
+// their passwords can't log in until they use their links
+func (u User) Invited() bool {
+	return u.Password == ""
+}
+
+// the purposes of user tokens, users ask for forgot password tokens themselves and their passwords keep working until
+// they set new ones, email change tokens confirm the new email of a user
+const (
+	TokenInvitation     = "invitation"
+	TokenPasswordReset  = "password_reset"
+	TokenForgotPassword = "forgot_password"
+	TokenEmailChange    = "email_change"
+)
+
+// UserToken is a single use token that lets a user set a password or confirm a new email, only the hash of the token
+// is stored, Email is the new email of email change tokens
+type UserToken struct {
 	ID        int
-	RoomName  string
+	UserID    int
+	Token     string
+	Purpose   string
+	Email     string
+	ExpiresAt time.Time
 	CreatedAt time.Time
 	UpdatedAt time.Time
+	User      User
+}
+
+// LoginFailures counts the failed logins of an email or an IP address, and has when the last one was
+type LoginFailures struct {
+	Email       string
+	IP          string
+	Count       int
+	Last        time.Time
+	LockedUntil time.Time
+}
+
+// Room is the room model
+type Room struct {
+	ID          int       `json:"id"`
+	RoomName    string    `json:"room_name"`
+	Description string    `json:"description"`
+	Active      int       `json:"-"`
+	SortOrder   int       `json:"-"`
+	CreatedAt   time.Time `json:"-"`
+	UpdatedAt   time.Time `json:"-"`
 }
 
 // Restriction is the restriction model
 type Restriction struct {
-	ID              int
-	RestrictionName string
-	CreatedAt       time.Time
-	UpdatedAt       time.Time
+	ID              int       `json:"id"`
+	RestrictionName string    `json:"restriction_name"`
+	CreatedAt       time.Time `json:"-"`
+	UpdatedAt       time.Time `json:"-"`
 }
 
 // Reservation is the reservation model
 type Reservation struct {
-	ID        int
-	FirstName string
-	LastName  string
-	Email     string
-	Phone     string
-	StartDate time.Time
-	EndDate   time.Time
-	RoomID    int
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	Processed int
-	Room      Room
+	ID        int       `json:"id"`
+	FirstName string    `json:"first_name"`
+	LastName  string    `json:"last_name"`
+	Email     string    `json:"email"`
+	Phone     string    `json:"phone"`
+	StartDate time.Time `json:"start_date"`
+	EndDate   time.Time `json:"end_date"`
+	RoomID    int       `json:"room_id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Processed int       `json:"processed"`
+	GuestID   int       `json:"guest_id"`
+	Room      Room      `json:"room"`
+	// Notes are internal to staff, they are only loaded for exports and never sent out
+	Notes []ReservationNote `json:"-"`
 }
 
-// RoomRestriction is the room restriction model
-type RoomRestriction struct {
+// ReservationNote is an internal note staff add to a reservation, User is the author and it is empty if the author
+// was deleted
+type ReservationNote struct {
 	ID            int
-	StartDate     time.Time
-	EndDate       time.Time
-	RoomID        int
-	RestrictionID int
 	ReservationID int
+	UserID        int
+	Body          string
 	CreatedAt     time.Time
 	UpdatedAt     time.Time
-	Room          Room
-	Reservation   Reservation
-	Restriction   Restriction
+	User          User
+}
+
+// Author returns the name of the note's author
+func (n ReservationNote) Author() string {
+	if n.User.ID == 0 {
+		return "Deleted user"
+	}
+	return n.User.FirstName + " " + n.User.LastName
+}
+
+// RoomRestriction is the room restriction model
+type RoomRestriction struct {
+	ID            int         `json:"id"`
+	StartDate     time.Time   `json:"start_date"`
+	EndDate       time.Time   `json:"end_date"`
+	RoomID        int         `json:"room_id"`
+	RestrictionID int         `json:"restriction_id"`
+	ReservationID int         `json:"reservation_id"`
+	ICalImportID  int         `json:"-"`
+	ExternalUID   string      `json:"-"`
+	CreatedAt     time.Time   `json:"-"`
+	UpdatedAt     time.Time   `json:"-"`
+	Room          Room        `json:"-"`
+	Reservation   Reservation `json:"-"`
+	Restriction   Restriction `json:"-"`
 }
 
 // MailData holds an email message's data
@@ -71,3 +146,370 @@ type MailData struct {
 	Content  string
 	Template string
 }
+
+// these are the names of the events we send to EventChan
+const (
+	EventReservationCreated   = "reservation.created"
+	EventReservationUpdated   = "reservation.updated"
+	EventReservationCancelled = "reservation.cancelled"
+	EventReservationProcessed = "reservation.processed"
+	EventBlockAdded           = "block.added"
+	EventBlockRemoved         = "block.removed"
+)
+
+// EventNames holds all of the event names, so admins can subscribe to them
+var EventNames = []string{
+	EventReservationCreated,
+	EventReservationUpdated,
+	EventReservationCancelled,
+	EventReservationProcessed,
+	EventBlockAdded,
+	EventBlockRemoved,
+}
+
+// Event holds a reservation or block event's data
+type Event struct {
+	Name      string      `json:"event"`
+	Data      interface{} `json:"data"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+// WebhookEndpoint is the webhook endpoint model
+type WebhookEndpoint struct {
+	ID        int
+	URL       string
+	Secret    string `json:"-"`
+	Events    []string
+	Active    int
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Subscribes returns true if the endpoint is subscribed to given event
+func (e WebhookEndpoint) Subscribes(event string) bool {
+	for _, x := range e.Events {
+		if x == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is the webhook delivery model, it holds the last attempt's result
+type WebhookDelivery struct {
+	ID         int
+	EndpointID int
+	Event      string
+	Payload    string
+	Attempts   int
+	StatusCode int
+	Response   string
+	Delivered  int
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// ICalFeed is the calendar feed model, it gives access to a room's calendar with a secret token
+type ICalFeed struct {
+	ID            int
+	RoomID        int
+	Token         string `json:"-"`
+	IncludeGuests int
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	Room          Room
+}
+
+// ICalImport is the calendar import model, it holds an external calendar that is synced into blocks of a room
+type ICalImport struct {
+	ID           int
+	RoomID       int
+	Name         string
+	URL          string
+	Content      string `json:"-"`
+	LastSyncedAt time.Time
+	LastError    string
+	Conflicts    []string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Room         Room
+}
+
+// ReservationFilter holds the filters, sorting and page of the admin reservation lists, zero values mean the filter
+// is not used
+type ReservationFilter struct {
+	IDs       []int
+	StartDate time.Time
+	EndDate   time.Time
+	RoomID    int
+	Status    string
+	Search    string
+	Sort      string
+	Direction string
+	Page      int
+	PerPage   int
+}
+
+// Offset returns how many reservations are skipped to reach the filter's page
+func (f ReservationFilter) Offset() int {
+	if f.Page < 1 {
+		return 0
+	}
+	return (f.Page - 1) * f.PerPage
+}
+
+// these are the statuses a ReservationFilter can filter by
+const (
+	StatusNew       = "new"
+	StatusProcessed = "processed"
+)
+
+// ReservationSortColumns are the columns reservation lists can be sorted by
+var ReservationSortColumns = []string{"id", "last_name", "room", "start_date", "end_date", "created_at"}
+
+// these are the directions a ReservationFilter can sort by
+const (
+	SortAsc  = "asc"
+	SortDesc = "desc"
+)
+
+// DashboardStats holds the numbers of a day shown in the admin dashboard
+type DashboardStats struct {
+	Arrivals   int
+	Departures int
+	InHouse    int
+	Pending    int
+}
+
+// RoomOccupancy holds how many nights of a room are reserved or blocked in a period
+type RoomOccupancy struct {
+	RoomID         int
+	RoomName       string
+	ReservedNights int
+	BlockedNights  int
+	Nights         int
+}
+
+// Percent returns the reserved nights as a percentage of the nights in the period
+func (o RoomOccupancy) Percent() int {
+	if o.Nights == 0 {
+		return 0
+	}
+	return o.ReservedNights * 100 / o.Nights
+}
+
+// WeeklyCount holds a count of the week that starts on Monday WeekStart
+type WeeklyCount struct {
+	WeekStart time.Time
+	Count     int
+}
+
+// AuditEntry is a change an admin made, Before and After hold the changed entity as JSON, they are empty when the
+// entity didn't exist before or after the change
+type AuditEntry struct {
+	ID        int
+	UserID    int
+	Action    string
+	Entity    string
+	EntityID  int
+	Before    string
+	After     string
+	IP        string
+	CreatedAt time.Time
+	User      User
+}
+
+// these are the actions recorded in the audit log
+const (
+	AuditCreate           = "create"
+	AuditUpdate           = "update"
+	AuditDelete           = "delete"
+	AuditProcess          = "process"
+	AuditImport           = "import"
+	AuditMerge            = "merge"
+	AuditSync             = "sync"
+	AuditReorder          = "reorder"
+	AuditResetPassword    = "reset_password"
+	AuditEnableTwoFactor  = "enable_two_factor"
+	AuditDisableTwoFactor = "disable_two_factor"
+	AuditNewRecoveryCodes = "new_recovery_codes"
+	AuditUnlock           = "unlock"
+	AuditLogin            = "login"
+	AuditChangePassword   = "change_password"
+	AuditChangeEmail      = "change_email"
+)
+
+// these are the entities recorded in the audit log
+const (
+	EntityReservation = "reservation"
+	EntityBlock       = "block"
+	EntityRoom        = "room"
+	EntityUser        = "user"
+	EntityWebhook     = "webhook"
+	EntityICalFeed    = "ical_feed"
+	EntityICalImport  = "ical_import"
+	EntityGuest       = "guest"
+	EntityNote        = "reservation_note"
+	EntityLogin       = "login"
+)
+
+// AuditEntities are the entities the audit log can be filtered by
+var AuditEntities = []string{EntityReservation, EntityNote, EntityGuest, EntityBlock, EntityRoom, EntityUser, EntityLogin,
+	EntityWebhook, EntityICalFeed, EntityICalImport}
+
+// AuditFilter holds the filters and page of the audit log, zero values mean the filter is not used
+type AuditFilter struct {
+	UserID    int
+	Action    string
+	Entity    string
+	StartDate time.Time
+	EndDate   time.Time
+	Page      int
+	PerPage   int
+}
+
+// Offset returns how many entries are skipped to reach the filter's page
+func (f AuditFilter) Offset() int {
+	if f.Page < 1 {
+		return 0
+	}
+	return (f.Page - 1) * f.PerPage
+}
+
+// SearchResults holds the reservations, guests and rooms that match an admin search
+type SearchResults struct {
+	Reservations []Reservation
+	Guests       []Guest
+	Rooms        []Room
+}
+
+// Count returns the number of all results
+func (s SearchResults) Count() int {
+	return len(s.Reservations) + len(s.Guests) + len(s.Rooms)
+}
+
+// DailyRoom is a room's row of the daily report, the restrictions of the room on the day are empty when there are none
+type DailyRoom struct {
+	Room      Room
+	Arrival   RoomRestriction
+	Departure RoomRestriction
+	StayOver  RoomRestriction
+	Block     RoomRestriction
+}
+
+// these are the housekeeping tasks of a room in the daily report
+const (
+	HousekeepingTurnover = "Turnover"
+	HousekeepingCheckout = "Checkout clean"
+	HousekeepingStayOver = "Stay-over service"
+	HousekeepingArrival  = "Inspect before arrival"
+	HousekeepingBlocked  = "Blocked"
+	HousekeepingNone     = "None"
+)
+
+// Housekeeping returns what housekeeping needs to do in the room on the day, a room that guests leave and arrive at
+// on the same day needs a turnover
+func (d DailyRoom) Housekeeping() string {
+	switch {
+	case d.Departure.ID > 0 && d.Arrival.ID > 0:
+		return HousekeepingTurnover
+	case d.Departure.ID > 0:
+		return HousekeepingCheckout
+	case d.StayOver.ID > 0:
+		return HousekeepingStayOver
+	case d.Arrival.ID > 0:
+		return HousekeepingArrival
+	case d.Block.ID > 0:
+		return HousekeepingBlocked
+	default:
+		return HousekeepingNone
+	}
+}
+
+// Vacant returns true if nobody stays in the room on the night of the day and it isn't blocked
+func (d DailyRoom) Vacant() bool {
+	return d.Arrival.ID == 0 && d.StayOver.ID == 0 && d.Block.ID == 0
+}
+
+// TimelineRoom is a row of the reservation timeline, a room with its reservations and blocks in a period
+type TimelineRoom struct {
+	Room         Room
+	Restrictions []RoomRestriction
+}
+
+// Guest is a person who made reservations, reservations with the same normalized email belong to the same guest
+type Guest struct {
+	ID        int
+	FirstName string
+	LastName  string
+	Email     string
+	Phone     string
+	Notes     string
+	Tags      []string
+	Stays     int
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// GuestTags are the tags suggested for guests, admins can use other tags too
+var GuestTags = []string{"VIP", "Allergy", "Returning", "Do not book"}
+
+// NormalizeEmail returns the email in the form guests are deduplicated by
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// GuestFilter holds the search, tag and page of the guest list, zero values mean the filter is not used
+type GuestFilter struct {
+	Search  string
+	Tag     string
+	Page    int
+	PerPage int
+}
+
+// Offset returns how many guests are skipped to reach the filter's page
+func (f GuestFilter) Offset() int {
+	if f.Page < 1 {
+		return 0
+	}
+	return (f.Page - 1) * f.PerPage
+}
+
+// MergeGuest returns the guest with the duplicate's notes and tags added, the duplicate's email and phone are used if
+// the guest doesn't have them
+func MergeGuest(guest, duplicate Guest) Guest {
+	if guest.Email == "" {
+		guest.Email = duplicate.Email
+	}
+
+	if guest.Phone == "" {
+		guest.Phone = duplicate.Phone
+	}
+
+	if duplicate.Notes != "" {
+		if guest.Notes != "" {
+			guest.Notes += "\n\n"
+		}
+		guest.Notes += duplicate.Notes
+	}
+
+	guest.Tags = append([]string(nil), guest.Tags...)
+	for _, tag := range duplicate.Tags {
+		if !guest.HasTag(tag) {
+			guest.Tags = append(guest.Tags, tag)
+		}
+	}
+
+	return guest
+}
+
+// HasTag returns true if the guest has the tag, tags are compared case insensitively
+func (g Guest) HasTag(tag string) bool {
+	for _, t := range g.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestMergeGuest checks if the duplicate's details fill in the guest's missing ones
+func TestMergeGuest(t *testing.T) {
+	guest := Guest{ID: 1, FirstName: "John", LastName: "Smith", Notes: "Late arrival", Tags: []string{"VIP"}}
+	duplicate := Guest{ID: 2, FirstName: "John", LastName: "Smith", Email: "john@smith.com", Phone: "555-1234",
+		Notes: "Gluten free", Tags: []string{"vip", "Allergy"}}
+
+	merged := MergeGuest(guest, duplicate)
+
+	if merged.ID != 1 || merged.Email != "john@smith.com" || merged.Phone != "555-1234" {
+		t.Errorf("expected the email and phone of the duplicate, got %+v", merged)
+	}
+
+	if merged.Notes != "Late arrival\n\nGluten free" {
+		t.Errorf("expected the notes to be joined, got %q", merged.Notes)
+	}
+
+	if !reflect.DeepEqual(merged.Tags, []string{"VIP", "Allergy"}) {
+		t.Errorf("expected the tags of both guests, got %v", merged.Tags)
+	}
+
+	if len(guest.Tags) != 1 {
+		t.Error("expected the guest's tags to be left unchanged")
+	}
+
+	merged = MergeGuest(Guest{Email: "jsmith@example.com"}, duplicate)
+
+	if merged.Email != "jsmith@example.com" {
+		t.Errorf("expected the guest's email to be kept, got %s", merged.Email)
+	}
+}
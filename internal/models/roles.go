@@ -0,0 +1,92 @@
+package models
+
+// the access levels of the roles, a user's role is stored as its access level
+const (
+	RoleViewer    = 1
+	RoleFrontDesk = 2
+	RoleManager   = 3
+	RoleOwner     = 4
+)
+
+// the permissions that the admin routes require
+const (
+	PermViewReservations   = "reservations.view"
+	PermEditReservations   = "reservations.edit"
+	PermDeleteReservations = "reservations.delete"
+	PermExportReservations = "reservations.export"
+	PermManageRooms        = "rooms.manage"
+	PermManageUsers        = "users.manage"
+	PermManageIntegrations = "integrations.manage"
+	PermViewAuditLog       = "audit.view"
+)
+
+// Role is a named set of permissions
+type Role struct {
+	AccessLevel int
+	Name        string
+	Permissions []string
+}
+
+// Roles are all roles from the least to the most privileged, each role has the permissions of the ones before it
+var Roles = []Role{
+	{
+		AccessLevel: RoleViewer,
+		Name:        "Viewer",
+		Permissions: []string{PermViewReservations},
+	},
+	{
+		AccessLevel: RoleFrontDesk,
+		Name:        "Front Desk",
+		Permissions: []string{PermViewReservations, PermEditReservations, PermExportReservations},
+	},
+	{
+		AccessLevel: RoleManager,
+		Name:        "Manager",
+		Permissions: []string{PermViewReservations, PermEditReservations, PermExportReservations,
+			PermDeleteReservations, PermManageRooms, PermManageIntegrations},
+	},
+	{
+		AccessLevel: RoleOwner,
+		Name:        "Owner",
+		Permissions: []string{PermViewReservations, PermEditReservations, PermExportReservations,
+			PermDeleteReservations, PermManageRooms, PermManageIntegrations, PermManageUsers, PermViewAuditLog},
+	},
+}
+
+// IsRole returns true if the access level belongs to one of the roles
+func IsRole(accessLevel int) bool {
+	for _, role := range Roles {
+		if role.AccessLevel == accessLevel {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RoleFor returns the role of the access level, unknown access levels have no permissions
+func RoleFor(accessLevel int) Role {
+	for _, role := range Roles {
+		if role.AccessLevel == accessLevel {
+			return role
+		}
+	}
+
+	return Role{AccessLevel: accessLevel, Name: "Unknown"}
+}
+
+// Role returns the user's role
+func (u User) Role() Role {
+	return RoleFor(u.AccessLevel)
+}
+
+// Can returns true if the user's role has the permission
+func (u User) Can(permission string) bool {
+	for _, p := range u.Role().Permissions {
+		if p == permission {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,44 @@
+package models
+
+import "testing"
+
+// TestUser_Can checks if users have the permissions of their roles only
+func TestUser_Can(t *testing.T) {
+	var tests = []struct {
+		accessLevel int
+		permission  string
+		expected    bool
+	}{
+		{RoleViewer, PermViewReservations, true},
+		{RoleViewer, PermEditReservations, false},
+		{RoleFrontDesk, PermEditReservations, true},
+		{RoleFrontDesk, PermDeleteReservations, false},
+		{RoleManager, PermDeleteReservations, true},
+		{RoleManager, PermManageUsers, false},
+		{RoleOwner, PermManageUsers, true},
+		{RoleManager, PermViewAuditLog, false},
+		{RoleOwner, PermViewAuditLog, true},
+		{0, PermViewReservations, false},
+	}
+
+	for _, tt := range tests {
+		u := User{AccessLevel: tt.accessLevel}
+
+		if u.Can(tt.permission) != tt.expected {
+			t.Errorf("access level %d with %s: got %v, wanted %v", tt.accessLevel, tt.permission, !tt.expected, tt.expected)
+		}
+	}
+}
+
+// TestRoles checks if each role has the permissions of the less privileged roles
+func TestRoles(t *testing.T) {
+	for n := 1; n < len(Roles); n++ {
+		u := User{AccessLevel: Roles[n].AccessLevel}
+
+		for _, p := range Roles[n-1].Permissions {
+			if !u.Can(p) {
+				t.Errorf("%s doesn't have %s of %s", Roles[n].Name, p, Roles[n-1].Name)
+			}
+		}
+	}
+}
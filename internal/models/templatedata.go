@@ -14,4 +14,11 @@ type TemplateData struct {
 	Error           string
 	Form            *forms.Form
 	IsAuthenticated int
+	User            User
+}
+
+// Can returns true if the logged-in user has the permission, templates use it to hide the actions the user can't
+// perform
+func (td *TemplateData) Can(permission string) bool {
+	return td.User.Can(permission)
 }
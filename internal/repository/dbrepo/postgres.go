@@ -2,30 +2,89 @@ package dbrepo
 
 import (
 	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/burakkarasel/bookings/internal/models"
+	"github.com/burakkarasel/bookings/internal/repository"
 	"golang.org/x/crypto/bcrypt"
 )
 
-func (repo *postgresDBRepo) AllUsers() bool {
-	return true
+// AllUsers returns all of the staff users from DB
+func (repo *postgresDBRepo) AllUsers() ([]models.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var users []models.User
+
+	query := `
+		select id, first_name, last_name, email, password, access_level, active, totp_secret, created_at, updated_at
+		from users
+		order by last_name, first_name
+	`
+
+	rows, err := repo.DB.QueryContext(ctx, query)
+
+	if err != nil {
+		return users, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var u models.User
+		err := rows.Scan(&u.ID, &u.FirstName, &u.LastName, &u.Email, &u.Password, &u.AccessLevel, &u.Active,
+			&u.TOTPSecret, &u.CreatedAt, &u.UpdatedAt)
+
+		if err != nil {
+			return users, err
+		}
+
+		users = append(users, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return users, err
+	}
+
+	return users, nil
 }
 
-// InsertReservation inserts a reservation into database
+// InsertReservation inserts a reservation into database, and links it to its guest
 func (repo *postgresDBRepo) InsertReservation(res models.Reservation) (int, error) {
 	// here we created a context to cancel this func with a timeout of 3 seconds, because we don't want it to run
 	// 5 minutes as we specified in our driver package
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
+	tx, err := repo.DB.BeginTx(ctx, nil)
+
+	if err != nil {
+		return 0, err
+	}
+
+	// rollback does nothing after the transaction is committed
+	defer tx.Rollback()
+
+	guestID, err := guestForReservationTx(ctx, tx, res)
+
+	if err != nil {
+		return 0, err
+	}
+
 	var newID int
 
 	statement := `insert into reservations (first_name, last_name, email, phone, start_date, end_date, 
-                          room_id, created_at, updated_at)
-                          values ($1, $2, $3, $4, $5, $6, $7, $8, $9) returning id`
-	err := repo.DB.QueryRowContext(ctx, statement,
+                          room_id, guest_id, created_at, updated_at)
+                          values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) returning id`
+	err = tx.QueryRowContext(ctx, statement,
 		res.FirstName,
 		res.LastName,
 		res.Email,
@@ -33,6 +92,7 @@ func (repo *postgresDBRepo) InsertReservation(res models.Reservation) (int, erro
 		res.StartDate,
 		res.EndDate,
 		res.RoomID,
+		guestID,
 		time.Now(),
 		time.Now(),
 	).Scan(&newID)
@@ -41,6 +101,12 @@ func (repo *postgresDBRepo) InsertReservation(res models.Reservation) (int, erro
 		return 0, err
 	}
 
+	err = tx.Commit()
+
+	if err != nil {
+		return 0, err
+	}
+
 	return newID, nil
 }
 
@@ -77,9 +143,10 @@ func (repo *postgresDBRepo) SearchAvailabilityByDatesByRoomID(start, end time.Ti
 
 	var numRows int
 
+	// inactive rooms aren't available for any dates
 	query := `
 		select 
-			count(id)
+			count(id) + (select count(id) from rooms where id = $1 and active = 0)
 		from 
 		    room_restrictions
 		where 
@@ -101,6 +168,35 @@ func (repo *postgresDBRepo) SearchAvailabilityByDatesByRoomID(start, end time.Ti
 	return false, nil
 }
 
+// SearchAvailabilityExcludingReservation returns true if the room is available between start and end, ignoring the
+// reservation's own restriction so it can be moved to overlapping dates
+func (repo *postgresDBRepo) SearchAvailabilityExcludingReservation(start, end time.Time, roomID, reservationID int) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var numRows int
+
+	query := `
+		select
+			count(id)
+		from
+		    room_restrictions
+		where
+		    room_id = $1 and
+		    $2 <= end_date and $3 >= start_date and
+		    (reservation_id is null or reservation_id <> $4)
+		`
+	row := repo.DB.QueryRowContext(ctx, query, roomID, start, end, reservationID)
+
+	err := row.Scan(&numRows)
+
+	if err != nil {
+		return false, err
+	}
+
+	return numRows == 0, nil
+}
+
 // SearchAvailabilityForAllRooms checks for all rooms restriction's in a given period of time and returns available rooms
 func (repo *postgresDBRepo) SearchAvailabilityForAllRooms(start, end time.Time) ([]models.Room, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
@@ -114,6 +210,7 @@ func (repo *postgresDBRepo) SearchAvailabilityForAllRooms(start, end time.Time)
 			from 
 				rooms r 
 			where 
+				r.active = 1 and
 				r.id not in (
 								select 
 									rr.room_id 
@@ -122,6 +219,7 @@ func (repo *postgresDBRepo) SearchAvailabilityForAllRooms(start, end time.Time)
 								where 
 								$1 <= rr.end_date and $2 >= rr.start_date 
 							)
+				order by r.sort_order, r.room_name
 			`
 	rows, err := repo.DB.QueryContext(ctx, query, start, end)
 
@@ -153,13 +251,13 @@ func (repo *postgresDBRepo) GetRoomById(id int) (models.Room, error) {
 	var room models.Room
 
 	query := `
-			select id, room_name, created_at, updated_at
+			select id, room_name, description, active, sort_order, created_at, updated_at
 			from rooms
 			where id = $1
 			`
 	row := repo.DB.QueryRowContext(ctx, query, id)
 
-	err := row.Scan(&room.ID, &room.RoomName, &room.CreatedAt, &room.UpdatedAt)
+	err := row.Scan(&room.ID, &room.RoomName, &room.Description, &room.Active, &room.SortOrder, &room.CreatedAt, &room.UpdatedAt)
 
 	if err != nil {
 		return room, err
@@ -176,13 +274,14 @@ func (repo *postgresDBRepo) GetUserById(id int) (models.User, error) {
 	var u models.User
 
 	query := `
-			select id, first_name, last_name, email, password, access_level, created_at, updated_at
+			select id, first_name, last_name, email, password, access_level, active, totp_secret, created_at, updated_at
 			from users
 			where id = $1
 	`
 
 	row := repo.DB.QueryRowContext(ctx, query, id)
-	err := row.Scan(&u.ID, &u.FirstName, &u.LastName, &u.Email, &u.Password, &u.AccessLevel, &u.CreatedAt, &u.UpdatedAt)
+	err := row.Scan(&u.ID, &u.FirstName, &u.LastName, &u.Email, &u.Password, &u.AccessLevel, &u.Active, &u.TOTPSecret,
+		&u.CreatedAt, &u.UpdatedAt)
 
 	if err != nil {
 		return u, err
@@ -197,11 +296,11 @@ func (repo *postgresDBRepo) UpdateUser(u models.User) error {
 	defer cancel()
 
 	query := `
-		update users set first_name = $1, last_name = $2, email = $3, access_level = $4, updated_at = $5
-		where id = $6
+		update users set first_name = $1, last_name = $2, email = $3, access_level = $4, active = $5, updated_at = $6
+		where id = $7
 	`
 
-	_, err := repo.DB.ExecContext(ctx, query, u.FirstName, u.LastName, u.Email, u.AccessLevel, time.Now(), u.ID)
+	_, err := repo.DB.ExecContext(ctx, query, u.FirstName, u.LastName, u.Email, u.AccessLevel, u.Active, time.Now(), u.ID)
 
 	if err != nil {
 		return err
@@ -218,8 +317,8 @@ func (repo *postgresDBRepo) Authenticate(email, testPassword string) (int, strin
 	var id int
 	var hashedPassword string
 
-	// first we check if email is valid
-	row := repo.DB.QueryRowContext(ctx, "select id, password from users where email = $1", email)
+	// first we check if email is valid, deactivated users can't log in
+	row := repo.DB.QueryRowContext(ctx, "select id, password from users where email = $1 and active = 1", email)
 	err := row.Scan(&id, &hashedPassword)
 
 	if err != nil {
@@ -238,115 +337,6 @@ func (repo *postgresDBRepo) Authenticate(email, testPassword string) (int, strin
 	return id, hashedPassword, nil
 }
 
-// AllReservations returns all of the reservations from DB as a slice
-func (repo *postgresDBRepo) AllReservations() ([]models.Reservation, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-
-	var reservations []models.Reservation
-
-	query := `
-		select r.id, r.first_name, r.last_name, r.email, r.phone, r.start_date, r.end_date, r.room_id, r.created_at, r.updated_at, r.processed, rm.id, rm.room_name
-		from reservations r
-		left join rooms rm on (r.room_id = rm.id)
-		order by r.start_date asc
-	`
-
-	rows, err := repo.DB.QueryContext(ctx, query)
-
-	if err != nil {
-		return reservations, err
-	}
-
-	defer rows.Close()
-
-	for rows.Next() {
-		var reservation models.Reservation
-		err := rows.Scan(
-			&reservation.ID,
-			&reservation.FirstName,
-			&reservation.LastName,
-			&reservation.Email,
-			&reservation.Phone,
-			&reservation.StartDate,
-			&reservation.EndDate,
-			&reservation.RoomID,
-			&reservation.CreatedAt,
-			&reservation.UpdatedAt,
-			&reservation.Processed,
-			&reservation.Room.ID,
-			&reservation.Room.RoomName,
-		)
-
-		if err != nil {
-			return reservations, err
-		}
-
-		reservations = append(reservations, reservation)
-	}
-
-	if err = rows.Err(); err != nil {
-		return reservations, err
-	}
-
-	return reservations, nil
-}
-
-// AllNewReservations returns all of the new reservations from DB as a slice
-func (repo *postgresDBRepo) AllNewReservations() ([]models.Reservation, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-
-	var reservations []models.Reservation
-
-	query := `
-		select r.id, r.first_name, r.last_name, r.email, r.phone, r.start_date, r.end_date, r.room_id, r.created_at, r.updated_at, r.processed, rm.id, rm.room_name
-		from reservations r
-		left join rooms rm on (r.room_id = rm.id)
-		where r.processed = 0
-		order by r.start_date asc
-	`
-
-	rows, err := repo.DB.QueryContext(ctx, query)
-
-	if err != nil {
-		return reservations, err
-	}
-
-	defer rows.Close()
-
-	for rows.Next() {
-		var reservation models.Reservation
-		err := rows.Scan(
-			&reservation.ID,
-			&reservation.FirstName,
-			&reservation.LastName,
-			&reservation.Email,
-			&reservation.Phone,
-			&reservation.StartDate,
-			&reservation.EndDate,
-			&reservation.RoomID,
-			&reservation.CreatedAt,
-			&reservation.UpdatedAt,
-			&reservation.Processed,
-			&reservation.Room.ID,
-			&reservation.Room.RoomName,
-		)
-
-		if err != nil {
-			return reservations, err
-		}
-
-		reservations = append(reservations, reservation)
-	}
-
-	if err = rows.Err(); err != nil {
-		return reservations, err
-	}
-
-	return reservations, nil
-}
-
 // GetReservationById returns a reservation according to id
 func (repo *postgresDBRepo) GetReservationById(id int) (models.Reservation, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
@@ -356,7 +346,7 @@ func (repo *postgresDBRepo) GetReservationById(id int) (models.Reservation, erro
 
 	query := `
 		select r.id, r.first_name, r.last_name, r.email, r.phone, r.start_date, r.end_date, r.room_id, r.created_at, r.updated_at, r.processed,
-			rm.id, rm.room_name
+			coalesce(r.guest_id, 0), rm.id, rm.room_name
 		from reservations r
 		left join rooms rm on (r.room_id = rm.id)
 		where r.id = $1
@@ -376,6 +366,7 @@ func (repo *postgresDBRepo) GetReservationById(id int) (models.Reservation, erro
 		&reservation.CreatedAt,
 		&reservation.UpdatedAt,
 		&reservation.Processed,
+		&reservation.GuestID,
 		&reservation.Room.ID,
 		&reservation.Room.RoomName,
 	)
@@ -387,23 +378,74 @@ func (repo *postgresDBRepo) GetReservationById(id int) (models.Reservation, erro
 	return reservation, nil
 }
 
-// UpdateUser updates a user in DB
+// UpdateReservation updates a reservation and moves its room restriction to the reservation's dates and room in a
+// single transaction, nothing is saved if the new dates or room are booked meanwhile
 func (repo *postgresDBRepo) UpdateReservation(r models.Reservation) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	query := `
-		update reservations set first_name = $1, last_name = $2, email = $3, phone = $4, updated_at = $5
-		where id = $6
-	`
+	tx, err := repo.DB.BeginTx(ctx, nil)
 
-	_, err := repo.DB.ExecContext(ctx, query, r.FirstName, r.LastName, r.Email, r.Phone, time.Now(), r.ID)
+	if err != nil {
+		return err
+	}
+
+	// rollback does nothing after the transaction is committed
+	defer tx.Rollback()
+
+	var email string
+	var guestID sql.NullInt64
+	var roomID int
+	var startDate, endDate time.Time
+
+	err = tx.QueryRowContext(ctx, `
+		select email, guest_id, room_id, start_date, end_date from reservations where id = $1 for update
+	`, r.ID).Scan(&email, &guestID, &roomID, &startDate, &endDate)
 
 	if err != nil {
 		return err
 	}
 
-	return nil
+	// only moves are checked, edits of the guest's details must work next to turnovers and overlapping external blocks
+	if roomID != r.RoomID || !startDate.Equal(r.StartDate) || !endDate.Equal(r.EndDate) {
+		err = checkAvailabilityTx(ctx, tx, r.RoomID, r.StartDate, r.EndDate, r.ID)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	// the reservation moves to the guest of its new email, other changes don't change its guest
+	if models.NormalizeEmail(email) != models.NormalizeEmail(r.Email) || !guestID.Valid {
+		id, err := guestForReservationTx(ctx, tx, r)
+
+		if err != nil {
+			return err
+		}
+
+		guestID = sql.NullInt64{Int64: int64(id), Valid: true}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		update reservations set first_name = $1, last_name = $2, email = $3, phone = $4, start_date = $5, end_date = $6,
+			room_id = $7, guest_id = $8, updated_at = $9
+		where id = $10
+	`, r.FirstName, r.LastName, r.Email, r.Phone, r.StartDate, r.EndDate, r.RoomID, guestID, time.Now(), r.ID)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		update room_restrictions set start_date = $1, end_date = $2, room_id = $3, updated_at = $4
+		where reservation_id = $5
+	`, r.StartDate, r.EndDate, r.RoomID, time.Now(), r.ID)
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 // DeleteReservation deletes a reservation from database by id
@@ -452,7 +494,11 @@ func (repo *postgresDBRepo) AllRooms() ([]models.Room, error) {
 
 	var rooms []models.Room
 
-	query := `select id, room_name, created_at, updated_at from rooms order by room_name`
+	query := `
+		select id, room_name, description, active, sort_order, created_at, updated_at
+		from rooms
+		order by sort_order, room_name
+	`
 
 	rows, err := repo.DB.QueryContext(ctx, query)
 
@@ -468,6 +514,9 @@ func (repo *postgresDBRepo) AllRooms() ([]models.Room, error) {
 		err := rows.Scan(
 			&room.ID,
 			&room.RoomName,
+			&room.Description,
+			&room.Active,
+			&room.SortOrder,
 			&room.CreatedAt,
 			&room.UpdatedAt,
 		)
@@ -575,3 +624,2655 @@ func (repo *postgresDBRepo) RemoveBlockForRoom(id int) error {
 
 	return nil
 }
+
+// AllWebhookEndpoints returns all of the webhook endpoints from DB
+func (repo *postgresDBRepo) AllWebhookEndpoints() ([]models.WebhookEndpoint, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `
+		select id, url, secret, events, active, created_at, updated_at
+		from webhook_endpoints
+		order by id asc
+	`
+
+	return repo.queryWebhookEndpoints(ctx, query)
+}
+
+// ActiveWebhookEndpoints returns the webhook endpoints that are active
+func (repo *postgresDBRepo) ActiveWebhookEndpoints() ([]models.WebhookEndpoint, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `
+		select id, url, secret, events, active, created_at, updated_at
+		from webhook_endpoints
+		where active = 1
+		order by id asc
+	`
+
+	return repo.queryWebhookEndpoints(ctx, query)
+}
+
+// queryWebhookEndpoints runs the given query and scans the rows into webhook endpoints
+func (repo *postgresDBRepo) queryWebhookEndpoints(ctx context.Context, query string, args ...interface{}) ([]models.WebhookEndpoint, error) {
+	var endpoints []models.WebhookEndpoint
+
+	rows, err := repo.DB.QueryContext(ctx, query, args...)
+
+	if err != nil {
+		return endpoints, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var e models.WebhookEndpoint
+		var events string
+
+		err := rows.Scan(
+			&e.ID,
+			&e.URL,
+			&e.Secret,
+			&events,
+			&e.Active,
+			&e.CreatedAt,
+			&e.UpdatedAt,
+		)
+
+		if err != nil {
+			return endpoints, err
+		}
+
+		e.Events = splitList(events)
+		endpoints = append(endpoints, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return endpoints, err
+	}
+
+	return endpoints, nil
+}
+
+// GetWebhookEndpointById returns a webhook endpoint by id
+func (repo *postgresDBRepo) GetWebhookEndpointById(id int) (models.WebhookEndpoint, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var e models.WebhookEndpoint
+	var events string
+
+	query := `
+		select id, url, secret, events, active, created_at, updated_at
+		from webhook_endpoints
+		where id = $1
+	`
+
+	row := repo.DB.QueryRowContext(ctx, query, id)
+	err := row.Scan(&e.ID, &e.URL, &e.Secret, &events, &e.Active, &e.CreatedAt, &e.UpdatedAt)
+
+	if err != nil {
+		return e, err
+	}
+
+	e.Events = splitList(events)
+
+	return e, nil
+}
+
+// InsertWebhookEndpoint inserts a new webhook endpoint into DB
+func (repo *postgresDBRepo) InsertWebhookEndpoint(e models.WebhookEndpoint) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var newID int
+
+	statement := `insert into webhook_endpoints (url, secret, events, active, created_at, updated_at)
+					values($1, $2, $3, $4, $5, $6) returning id`
+
+	err := repo.DB.QueryRowContext(ctx, statement,
+		e.URL,
+		e.Secret,
+		strings.Join(e.Events, ","),
+		e.Active,
+		time.Now(),
+		time.Now(),
+	).Scan(&newID)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return newID, nil
+}
+
+// UpdateWebhookEndpoint updates a webhook endpoint in DB
+func (repo *postgresDBRepo) UpdateWebhookEndpoint(e models.WebhookEndpoint) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `
+		update webhook_endpoints set url = $1, secret = $2, events = $3, active = $4, updated_at = $5
+		where id = $6
+	`
+
+	_, err := repo.DB.ExecContext(ctx, query, e.URL, e.Secret, strings.Join(e.Events, ","), e.Active, time.Now(), e.ID)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteWebhookEndpoint deletes a webhook endpoint and its deliveries from DB
+func (repo *postgresDBRepo) DeleteWebhookEndpoint(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := repo.DB.ExecContext(ctx, `delete from webhook_endpoints where id = $1`, id)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// InsertWebhookDelivery inserts a new delivery into the delivery log
+func (repo *postgresDBRepo) InsertWebhookDelivery(d models.WebhookDelivery) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var newID int
+
+	statement := `insert into webhook_deliveries (endpoint_id, event, payload, attempts, status_code, response,
+                    delivered, created_at, updated_at)
+					values($1, $2, $3, $4, $5, $6, $7, $8, $9) returning id`
+
+	err := repo.DB.QueryRowContext(ctx, statement,
+		d.EndpointID,
+		d.Event,
+		d.Payload,
+		d.Attempts,
+		d.StatusCode,
+		d.Response,
+		d.Delivered,
+		time.Now(),
+		time.Now(),
+	).Scan(&newID)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return newID, nil
+}
+
+// UpdateWebhookDelivery updates the result of a delivery after an attempt
+func (repo *postgresDBRepo) UpdateWebhookDelivery(d models.WebhookDelivery) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `
+		update webhook_deliveries set attempts = $1, status_code = $2, response = $3, delivered = $4, updated_at = $5
+		where id = $6
+	`
+
+	_, err := repo.DB.ExecContext(ctx, query, d.Attempts, d.StatusCode, d.Response, d.Delivered, time.Now(), d.ID)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// WebhookDeliveriesForEndpoint returns the latest deliveries of an endpoint
+func (repo *postgresDBRepo) WebhookDeliveriesForEndpoint(endpointID, limit int) ([]models.WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var deliveries []models.WebhookDelivery
+
+	query := `
+		select id, endpoint_id, event, payload, attempts, status_code, response, delivered, created_at, updated_at
+		from webhook_deliveries
+		where endpoint_id = $1
+		order by created_at desc
+		limit $2
+	`
+
+	rows, err := repo.DB.QueryContext(ctx, query, endpointID, limit)
+
+	if err != nil {
+		return deliveries, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var d models.WebhookDelivery
+
+		err := rows.Scan(
+			&d.ID,
+			&d.EndpointID,
+			&d.Event,
+			&d.Payload,
+			&d.Attempts,
+			&d.StatusCode,
+			&d.Response,
+			&d.Delivered,
+			&d.CreatedAt,
+			&d.UpdatedAt,
+		)
+
+		if err != nil {
+			return deliveries, err
+		}
+
+		deliveries = append(deliveries, d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return deliveries, err
+	}
+
+	return deliveries, nil
+}
+
+// splitList turns a comma separated column from DB, like event names or tags, into a slice
+func splitList(list string) []string {
+	var items []string
+
+	for _, x := range strings.Split(list, ",") {
+		if x = strings.TrimSpace(x); x != "" {
+			items = append(items, x)
+		}
+	}
+
+	return items
+}
+
+// AllICalFeeds returns all of the calendar feeds with their rooms
+func (repo *postgresDBRepo) AllICalFeeds() ([]models.ICalFeed, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var feeds []models.ICalFeed
+
+	query := `
+		select f.id, f.room_id, f.token, f.include_guests, f.created_at, f.updated_at, rm.id, rm.room_name
+		from ical_feeds f
+		left join rooms rm on (f.room_id = rm.id)
+		order by rm.room_name, f.id
+	`
+
+	rows, err := repo.DB.QueryContext(ctx, query)
+
+	if err != nil {
+		return feeds, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var f models.ICalFeed
+
+		err := rows.Scan(
+			&f.ID,
+			&f.RoomID,
+			&f.Token,
+			&f.IncludeGuests,
+			&f.CreatedAt,
+			&f.UpdatedAt,
+			&f.Room.ID,
+			&f.Room.RoomName,
+		)
+
+		if err != nil {
+			return feeds, err
+		}
+
+		feeds = append(feeds, f)
+	}
+
+	if err = rows.Err(); err != nil {
+		return feeds, err
+	}
+
+	return feeds, nil
+}
+
+// GetICalFeedByToken returns a calendar feed by its token
+func (repo *postgresDBRepo) GetICalFeedByToken(token string) (models.ICalFeed, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var f models.ICalFeed
+
+	query := `
+		select f.id, f.room_id, f.token, f.include_guests, f.created_at, f.updated_at, rm.id, rm.room_name
+		from ical_feeds f
+		left join rooms rm on (f.room_id = rm.id)
+		where f.token = $1
+	`
+
+	row := repo.DB.QueryRowContext(ctx, query, token)
+	err := row.Scan(&f.ID, &f.RoomID, &f.Token, &f.IncludeGuests, &f.CreatedAt, &f.UpdatedAt, &f.Room.ID, &f.Room.RoomName)
+
+	if err != nil {
+		return f, err
+	}
+
+	return f, nil
+}
+
+// InsertICalFeed inserts a new calendar feed into DB
+func (repo *postgresDBRepo) InsertICalFeed(f models.ICalFeed) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var newID int
+
+	statement := `insert into ical_feeds (room_id, token, include_guests, created_at, updated_at)
+					values($1, $2, $3, $4, $5) returning id`
+
+	err := repo.DB.QueryRowContext(ctx, statement,
+		f.RoomID,
+		f.Token,
+		f.IncludeGuests,
+		time.Now(),
+		time.Now(),
+	).Scan(&newID)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return newID, nil
+}
+
+// DeleteICalFeed deletes a calendar feed from DB, so its token can't be used anymore
+func (repo *postgresDBRepo) DeleteICalFeed(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := repo.DB.ExecContext(ctx, `delete from ical_feeds where id = $1`, id)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetRestrictionsForRoomFeed returns a room's restrictions that end after since, with their reservation and
+// restriction details
+func (repo *postgresDBRepo) GetRestrictionsForRoomFeed(roomID int, since time.Time) ([]models.RoomRestriction, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var restrictions []models.RoomRestriction
+
+	query := `
+		select rr.id, coalesce(rr.reservation_id, 0), rr.restriction_id, rr.room_id, rr.start_date, rr.end_date,
+			rr.created_at, rr.updated_at, coalesce(r.first_name, ''), coalesce(r.last_name, ''),
+			coalesce(r.updated_at, rr.updated_at), rs.restriction_name
+		from room_restrictions rr
+		left join reservations r on (rr.reservation_id = r.id)
+		left join restrictions rs on (rr.restriction_id = rs.id)
+		where rr.room_id = $1 and rr.end_date >= $2
+		order by rr.start_date asc
+	`
+
+	rows, err := repo.DB.QueryContext(ctx, query, roomID, since)
+
+	if err != nil {
+		return restrictions, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var r models.RoomRestriction
+
+		err := rows.Scan(
+			&r.ID,
+			&r.ReservationID,
+			&r.RestrictionID,
+			&r.RoomID,
+			&r.StartDate,
+			&r.EndDate,
+			&r.CreatedAt,
+			&r.UpdatedAt,
+			&r.Reservation.FirstName,
+			&r.Reservation.LastName,
+			&r.Reservation.UpdatedAt,
+			&r.Restriction.RestrictionName,
+		)
+
+		if err != nil {
+			return restrictions, err
+		}
+
+		r.Reservation.ID = r.ReservationID
+		r.Restriction.ID = r.RestrictionID
+		restrictions = append(restrictions, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return restrictions, err
+	}
+
+	return restrictions, nil
+}
+
+// AllICalImports returns all of the calendar imports with their rooms
+func (repo *postgresDBRepo) AllICalImports() ([]models.ICalImport, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var imports []models.ICalImport
+
+	query := `
+		select i.id, i.room_id, i.name, i.url, i.content, i.last_synced_at, i.last_error, i.conflicts,
+			i.created_at, i.updated_at, rm.id, rm.room_name
+		from ical_imports i
+		left join rooms rm on (i.room_id = rm.id)
+		order by rm.room_name, i.id
+	`
+
+	rows, err := repo.DB.QueryContext(ctx, query)
+
+	if err != nil {
+		return imports, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		i, err := scanICalImport(rows)
+
+		if err != nil {
+			return imports, err
+		}
+
+		imports = append(imports, i)
+	}
+
+	if err = rows.Err(); err != nil {
+		return imports, err
+	}
+
+	return imports, nil
+}
+
+// GetICalImportById returns a calendar import by id
+func (repo *postgresDBRepo) GetICalImportById(id int) (models.ICalImport, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `
+		select i.id, i.room_id, i.name, i.url, i.content, i.last_synced_at, i.last_error, i.conflicts,
+			i.created_at, i.updated_at, rm.id, rm.room_name
+		from ical_imports i
+		left join rooms rm on (i.room_id = rm.id)
+		where i.id = $1
+	`
+
+	return scanICalImport(repo.DB.QueryRowContext(ctx, query, id))
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows, so a single func can scan both of them
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanICalImport scans a row of the calendar import queries, last_synced_at is null until the first sync
+func scanICalImport(row rowScanner) (models.ICalImport, error) {
+	var i models.ICalImport
+	var lastSynced sql.NullTime
+	var conflicts string
+
+	err := row.Scan(
+		&i.ID,
+		&i.RoomID,
+		&i.Name,
+		&i.URL,
+		&i.Content,
+		&lastSynced,
+		&i.LastError,
+		&conflicts,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Room.ID,
+		&i.Room.RoomName,
+	)
+
+	if err != nil {
+		return i, err
+	}
+
+	i.LastSyncedAt = lastSynced.Time
+	if conflicts != "" {
+		i.Conflicts = strings.Split(conflicts, "\n")
+	}
+
+	return i, nil
+}
+
+// InsertICalImport inserts a new calendar import into DB
+func (repo *postgresDBRepo) InsertICalImport(i models.ICalImport) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var newID int
+
+	statement := `insert into ical_imports (room_id, name, url, content, created_at, updated_at)
+					values($1, $2, $3, $4, $5, $6) returning id`
+
+	err := repo.DB.QueryRowContext(ctx, statement,
+		i.RoomID,
+		i.Name,
+		i.URL,
+		i.Content,
+		time.Now(),
+		time.Now(),
+	).Scan(&newID)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return newID, nil
+}
+
+// UpdateICalImportSync saves the result of the last sync of a calendar import
+func (repo *postgresDBRepo) UpdateICalImportSync(i models.ICalImport) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `
+		update ical_imports set last_synced_at = $1, last_error = $2, conflicts = $3, updated_at = $4
+		where id = $5
+	`
+
+	_, err := repo.DB.ExecContext(ctx, query, i.LastSyncedAt, i.LastError, strings.Join(i.Conflicts, "\n"), time.Now(), i.ID)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteICalImport deletes a calendar import, its blocks are deleted by the foreign key
+func (repo *postgresDBRepo) DeleteICalImport(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := repo.DB.ExecContext(ctx, `delete from ical_imports where id = $1`, id)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ExternalBlocksForImport returns the blocks that are created by a calendar import
+func (repo *postgresDBRepo) ExternalBlocksForImport(importID int) ([]models.RoomRestriction, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var restrictions []models.RoomRestriction
+
+	query := `
+		select id, restriction_id, room_id, start_date, end_date, ical_import_id, external_uid
+		from room_restrictions
+		where ical_import_id = $1
+	`
+
+	rows, err := repo.DB.QueryContext(ctx, query, importID)
+
+	if err != nil {
+		return restrictions, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var r models.RoomRestriction
+
+		err := rows.Scan(
+			&r.ID,
+			&r.RestrictionID,
+			&r.RoomID,
+			&r.StartDate,
+			&r.EndDate,
+			&r.ICalImportID,
+			&r.ExternalUID,
+		)
+
+		if err != nil {
+			return restrictions, err
+		}
+
+		restrictions = append(restrictions, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return restrictions, err
+	}
+
+	return restrictions, nil
+}
+
+// InsertExternalBlockForRoom inserts a new block of the External restriction for an event of a calendar import
+func (repo *postgresDBRepo) InsertExternalBlockForRoom(importID, roomID int, uid string, startDate, endDate time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `
+		insert into room_restrictions (start_date, end_date, room_id, restriction_id, ical_import_id, external_uid,
+			created_at, updated_at)
+		values($1, $2, $3, (select id from restrictions where restriction_name = 'External'), $4, $5, $6, $7)
+	`
+
+	_, err := repo.DB.ExecContext(ctx, query,
+		startDate,
+		endDate,
+		roomID,
+		importID,
+		uid,
+		time.Now(),
+		time.Now(),
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// UpdateExternalBlockForRoom moves an external block to the new dates of its event
+func (repo *postgresDBRepo) UpdateExternalBlockForRoom(id int, startDate, endDate time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `update room_restrictions set start_date = $1, end_date = $2, updated_at = $3 where id = $4`
+
+	_, err := repo.DB.ExecContext(ctx, query, startDate, endDate, time.Now(), id)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// reservationFilterQuery builds the where clause of a reservation filter, reservations that overlap with the date
+// range are matched
+func reservationFilterQuery(f models.ReservationFilter) (string, []interface{}) {
+	var where []string
+	var args []interface{}
+
+	add := func(clause string, arg interface{}) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(clause, len(args)))
+	}
+
+	if len(f.IDs) > 0 {
+		add("r.id = any($%d)", f.IDs)
+	}
+
+	if !f.StartDate.IsZero() {
+		add("r.end_date >= $%d", f.StartDate)
+	}
+
+	if !f.EndDate.IsZero() {
+		add("r.start_date <= $%d", f.EndDate)
+	}
+
+	if f.RoomID > 0 {
+		add("r.room_id = $%d", f.RoomID)
+	}
+
+	switch f.Status {
+	case models.StatusNew:
+		where = append(where, "r.processed = 0")
+	case models.StatusProcessed:
+		where = append(where, "r.processed = 1")
+	}
+
+	if f.Search != "" {
+		search := containsPattern(f.Search)
+		add(`(r.first_name ilike $%[1]d or r.last_name ilike $%[1]d or r.first_name || ' ' || r.last_name ilike $%[1]d
+			or r.email ilike $%[1]d or r.phone ilike $%[1]d
+			or exists (select 1 from reservation_notes n where n.reservation_id = r.id and n.body ilike $%[1]d))`, search)
+	}
+
+	if len(where) == 0 {
+		return "", args
+	}
+
+	return "where " + strings.Join(where, " and "), args
+}
+
+// containsPattern returns the ilike pattern that matches values containing s, % and _ typed by admins are searched as
+// they are
+func containsPattern(s string) string {
+	return "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s) + "%"
+}
+
+// reservationSortColumns maps the sort columns of a reservation filter to their SQL, so only these can be used
+var reservationSortColumns = map[string]string{
+	"id":         "r.id",
+	"last_name":  "r.last_name",
+	"room":       "rm.room_name",
+	"start_date": "r.start_date",
+	"end_date":   "r.end_date",
+	"created_at": "r.created_at",
+}
+
+// reservationOrderQuery builds the order by clause of a reservation filter, reservations are sorted by start date if
+// the filter has no valid sort column
+func reservationOrderQuery(f models.ReservationFilter) string {
+	column, ok := reservationSortColumns[f.Sort]
+
+	if !ok {
+		column = "r.start_date"
+	}
+
+	direction := "asc"
+	if f.Direction == models.SortDesc {
+		direction = "desc"
+	}
+
+	// id keeps the order of reservations with the same value stable between pages
+	return fmt.Sprintf("order by %s %s, r.id %s", column, direction, direction)
+}
+
+// FilterReservations returns a page of the reservations that match the filter, and the number of all matching
+// reservations
+func (repo *postgresDBRepo) FilterReservations(filter models.ReservationFilter) ([]models.Reservation, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var reservations []models.Reservation
+	var total int
+
+	where, args := reservationFilterQuery(filter)
+
+	err := repo.DB.QueryRowContext(ctx, fmt.Sprintf(`
+		select count(r.id)
+		from reservations r
+		left join rooms rm on (r.room_id = rm.id)
+		%s
+	`, where), args...).Scan(&total)
+
+	if err != nil {
+		return reservations, 0, err
+	}
+
+	query := fmt.Sprintf(`
+		select r.id, r.first_name, r.last_name, r.email, r.phone, r.start_date, r.end_date, r.room_id, r.created_at, r.updated_at, r.processed, rm.id, rm.room_name
+		from reservations r
+		left join rooms rm on (r.room_id = rm.id)
+		%s
+		%s
+		limit $%d offset $%d
+	`, where, reservationOrderQuery(filter), len(args)+1, len(args)+2)
+
+	rows, err := repo.DB.QueryContext(ctx, query, append(args, filter.PerPage, filter.Offset())...)
+
+	if err != nil {
+		return reservations, 0, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var reservation models.Reservation
+		err := rows.Scan(
+			&reservation.ID,
+			&reservation.FirstName,
+			&reservation.LastName,
+			&reservation.Email,
+			&reservation.Phone,
+			&reservation.StartDate,
+			&reservation.EndDate,
+			&reservation.RoomID,
+			&reservation.CreatedAt,
+			&reservation.UpdatedAt,
+			&reservation.Processed,
+			&reservation.Room.ID,
+			&reservation.Room.RoomName,
+		)
+
+		if err != nil {
+			return reservations, 0, err
+		}
+
+		reservations = append(reservations, reservation)
+	}
+
+	if err = rows.Err(); err != nil {
+		return reservations, 0, err
+	}
+
+	return reservations, total, nil
+}
+
+// EachReservation calls fn for each reservation that matches the filter, rows are read one by one, so exports don't
+// need to hold all reservations in memory
+func (repo *postgresDBRepo) EachReservation(filter models.ReservationFilter, fn func(models.Reservation) error) error {
+	// exports might take longer than our usual queries, so we give them more time
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	where, args := reservationFilterQuery(filter)
+
+	// notes are aggregated as JSON, so they are read with their reservation's row
+	query := fmt.Sprintf(`
+		select r.id, r.first_name, r.last_name, r.email, r.phone, r.start_date, r.end_date, r.room_id, r.created_at, r.updated_at, r.processed, rm.id, rm.room_name,
+			coalesce((
+				select json_agg(json_build_object(
+					'ID', n.id,
+					'Body', n.body,
+					'CreatedAt', to_char(n.created_at, 'YYYY-MM-DD"T"HH24:MI:SS"Z"'),
+					'User', json_build_object('ID', coalesce(u.id, 0), 'FirstName', coalesce(u.first_name, ''), 'LastName', coalesce(u.last_name, ''))
+				) order by n.created_at, n.id)
+				from reservation_notes n
+				left join users u on (n.user_id = u.id)
+				where n.reservation_id = r.id
+			), '[]')
+		from reservations r
+		left join rooms rm on (r.room_id = rm.id)
+		%s
+		%s
+	`, where, reservationOrderQuery(filter))
+
+	rows, err := repo.DB.QueryContext(ctx, query, args...)
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var reservation models.Reservation
+		var notes []byte
+		err := rows.Scan(
+			&reservation.ID,
+			&reservation.FirstName,
+			&reservation.LastName,
+			&reservation.Email,
+			&reservation.Phone,
+			&reservation.StartDate,
+			&reservation.EndDate,
+			&reservation.RoomID,
+			&reservation.CreatedAt,
+			&reservation.UpdatedAt,
+			&reservation.Processed,
+			&reservation.Room.ID,
+			&reservation.Room.RoomName,
+			&notes,
+		)
+
+		if err != nil {
+			return err
+		}
+
+		err = json.Unmarshal(notes, &reservation.Notes)
+
+		if err != nil {
+			return err
+		}
+
+		err = fn(reservation)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// ImportReservationsAndBlocks saves the reservations with their room restrictions and the blocks in a single
+// transaction, nothing is saved if any of them overlaps with an existing restriction
+func (repo *postgresDBRepo) ImportReservationsAndBlocks(reservations []models.Reservation, blocks []models.RoomRestriction) error {
+	// imports might have hundreds of rows, so we give them more time than our usual queries
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	tx, err := repo.DB.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	// rollback does nothing after the transaction is committed
+	defer tx.Rollback()
+
+	// rows of other admins might be saved after the import was previewed, so availability is checked again in here
+	for _, r := range reservations {
+		_, err = insertReservationTx(ctx, tx, r)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, b := range blocks {
+		err = checkAvailabilityTx(ctx, tx, b.RoomID, b.StartDate, b.EndDate, 0)
+
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			insert into room_restrictions (start_date, end_date, room_id, restriction_id, created_at, updated_at)
+			values ($1, $2, $3, $4, $5, $6)
+		`, b.StartDate, b.EndDate, b.RoomID, b.RestrictionID, time.Now(), time.Now())
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// CountNewReservations returns the number of reservations that are not processed yet
+func (repo *postgresDBRepo) CountNewReservations() (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var count int
+
+	err := repo.DB.QueryRowContext(ctx, `select count(id) from reservations where processed = 0`).Scan(&count)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// DashboardStats returns the arrivals, departures and guests staying on the day, and the number of new reservations
+func (repo *postgresDBRepo) DashboardStats(day time.Time) (models.DashboardStats, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var stats models.DashboardStats
+
+	query := `
+		select
+			count(id) filter (where start_date = $1),
+			count(id) filter (where end_date = $1),
+			count(id) filter (where start_date <= $1 and end_date > $1),
+			count(id) filter (where processed = 0)
+		from reservations
+	`
+
+	err := repo.DB.QueryRowContext(ctx, query, day).Scan(
+		&stats.Arrivals,
+		&stats.Departures,
+		&stats.InHouse,
+		&stats.Pending,
+	)
+
+	if err != nil {
+		return stats, err
+	}
+
+	return stats, nil
+}
+
+// OccupancyByRoom returns the reserved and blocked nights of each room between start and end, end is exclusive
+func (repo *postgresDBRepo) OccupancyByRoom(start, end time.Time) ([]models.RoomOccupancy, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var occupancy []models.RoomOccupancy
+
+	// only the nights of a restriction that are in the period are counted
+	query := `
+		select
+			rm.id, rm.room_name,
+			coalesce(sum(least(rr.end_date, $2::date) - greatest(rr.start_date, $1::date))
+				filter (where rr.reservation_id is not null), 0),
+			coalesce(sum(least(rr.end_date, $2::date) - greatest(rr.start_date, $1::date))
+				filter (where rr.reservation_id is null), 0)
+		from rooms rm
+		left join room_restrictions rr on (rr.room_id = rm.id and rr.start_date < $2 and rr.end_date > $1)
+		group by rm.id, rm.room_name, rm.sort_order
+		order by rm.sort_order, rm.room_name
+	`
+
+	rows, err := repo.DB.QueryContext(ctx, query, start, end)
+
+	if err != nil {
+		return occupancy, err
+	}
+
+	defer rows.Close()
+
+	nights := int(end.Sub(start).Hours() / 24)
+
+	for rows.Next() {
+		o := models.RoomOccupancy{Nights: nights}
+		err := rows.Scan(&o.RoomID, &o.RoomName, &o.ReservedNights, &o.BlockedNights)
+
+		if err != nil {
+			return occupancy, err
+		}
+
+		occupancy = append(occupancy, o)
+	}
+
+	if err = rows.Err(); err != nil {
+		return occupancy, err
+	}
+
+	return occupancy, nil
+}
+
+// BookingsPerWeek returns the number of reservations created in each week since the given day, weeks without
+// reservations are not returned
+func (repo *postgresDBRepo) BookingsPerWeek(since time.Time) ([]models.WeeklyCount, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var weeks []models.WeeklyCount
+
+	query := `
+		select date_trunc('week', created_at)::date as week, count(id)
+		from reservations
+		where created_at >= $1
+		group by week
+		order by week
+	`
+
+	rows, err := repo.DB.QueryContext(ctx, query, since)
+
+	if err != nil {
+		return weeks, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var w models.WeeklyCount
+		err := rows.Scan(&w.WeekStart, &w.Count)
+
+		if err != nil {
+			return weeks, err
+		}
+
+		weeks = append(weeks, w)
+	}
+
+	if err = rows.Err(); err != nil {
+		return weeks, err
+	}
+
+	return weeks, nil
+}
+
+// InsertReservationWithRestriction saves the reservation and its room restriction in a single transaction, and
+// returns the reservation's id, nothing is saved if the room is booked meanwhile
+func (repo *postgresDBRepo) InsertReservationWithRestriction(res models.Reservation) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := repo.DB.BeginTx(ctx, nil)
+
+	if err != nil {
+		return 0, err
+	}
+
+	// rollback does nothing after the transaction is committed
+	defer tx.Rollback()
+
+	id, err := insertReservationTx(ctx, tx, res)
+
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// checkAvailabilityTx returns an error if the room has a restriction between start and end, it uses the same dates
+// as SearchAvailabilityByDatesByRoomID, the restriction of reservationID is ignored, 0 checks all of them
+func checkAvailabilityTx(ctx context.Context, tx *sql.Tx, roomID int, start, end time.Time, reservationID int) error {
+	var numRows int
+
+	err := tx.QueryRowContext(ctx, `
+		select count(id) from room_restrictions
+		where room_id = $1 and $2 <= end_date and $3 >= start_date and
+			(reservation_id is null or reservation_id <> $4)
+	`, roomID, start, end, reservationID).Scan(&numRows)
+
+	if err != nil {
+		return err
+	}
+
+	if numRows > 0 {
+		return fmt.Errorf("room %d is not available between %s and %s", roomID,
+			start.Format("2006-01-02"), end.Format("2006-01-02"))
+	}
+
+	return nil
+}
+
+// guestForReservationTx returns the id of the guest with the reservation's email, the guest is created with the
+// reservation's details if there isn't one, reservations without an email get a new guest
+func guestForReservationTx(ctx context.Context, tx *sql.Tx, r models.Reservation) (int, error) {
+	var id int
+
+	// the update makes the insert return the existing guest's id
+	err := tx.QueryRowContext(ctx, `
+		insert into guests (first_name, last_name, email, email_normalized, phone, created_at, updated_at)
+		values ($1, $2, $3, $4, $5, $6, $7)
+		on conflict (email_normalized) where email_normalized <> '' do update set updated_at = excluded.updated_at
+		returning id
+	`, r.FirstName, r.LastName, strings.TrimSpace(r.Email), models.NormalizeEmail(r.Email), r.Phone, time.Now(),
+		time.Now()).Scan(&id)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// insertReservationTx checks the room's availability, and inserts the reservation with its guest and room restriction
+func insertReservationTx(ctx context.Context, tx *sql.Tx, r models.Reservation) (int, error) {
+	err := checkAvailabilityTx(ctx, tx, r.RoomID, r.StartDate, r.EndDate, 0)
+
+	if err != nil {
+		return 0, err
+	}
+
+	guestID, err := guestForReservationTx(ctx, tx, r)
+
+	if err != nil {
+		return 0, err
+	}
+
+	var id int
+
+	err = tx.QueryRowContext(ctx, `
+		insert into reservations (first_name, last_name, email, phone, start_date, end_date, room_id, guest_id, created_at,
+			updated_at)
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) returning id
+	`, r.FirstName, r.LastName, r.Email, r.Phone, r.StartDate, r.EndDate, r.RoomID, guestID, time.Now(), time.Now()).Scan(&id)
+
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		insert into room_restrictions (start_date, end_date, room_id, reservation_id, restriction_id, created_at, updated_at)
+		values ($1, $2, $3, $4, $5, $6, $7)
+	`, r.StartDate, r.EndDate, r.RoomID, id, 1, time.Now(), time.Now())
+
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// InsertRoom inserts a new room after the existing ones, and returns its id
+func (repo *postgresDBRepo) InsertRoom(r models.Room) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var id int
+
+	query := `
+		insert into rooms (room_name, description, active, sort_order, created_at, updated_at)
+		values ($1, $2, $3, (select coalesce(max(sort_order), 0) + 1 from rooms), $4, $5) returning id
+	`
+
+	err := repo.DB.QueryRowContext(ctx, query, r.RoomName, r.Description, r.Active, time.Now(), time.Now()).Scan(&id)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// UpdateRoom updates a room's name, description and status, a room can't be deactivated while it has reservations
+// that end after the given day. sql.ErrNoRows is returned if the room doesn't exist
+func (repo *postgresDBRepo) UpdateRoom(r models.Room, day time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var exists bool
+
+	err := repo.DB.QueryRowContext(ctx, `select exists (select 1 from rooms where id = $1)`, r.ID).Scan(&exists)
+
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return sql.ErrNoRows
+	}
+
+	query := `
+		update rooms set room_name = $1, description = $2, active = $3, updated_at = $4
+		where id = $5 and (
+			$3 = 1 or not exists (select 1 from reservations where room_id = $5 and end_date > $6)
+		)
+	`
+
+	result, err := repo.DB.ExecContext(ctx, query, r.RoomName, r.Description, r.Active, time.Now(), r.ID, day)
+
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return repository.ErrRoomHasReservations
+	}
+
+	return nil
+}
+
+// UpdateRoomOrder saves the rooms' order, the ids are given in the order they are shown
+func (repo *postgresDBRepo) UpdateRoomOrder(ids []int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := repo.DB.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	// rollback does nothing after the transaction is committed
+	defer tx.Rollback()
+
+	for n, id := range ids {
+		_, err = tx.ExecContext(ctx, `update rooms set sort_order = $1, updated_at = $2 where id = $3`, n+1, time.Now(), id)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// FutureReservationsForRoom returns the reservations of the room that end after the given day
+func (repo *postgresDBRepo) FutureReservationsForRoom(roomID int, day time.Time) ([]models.Reservation, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var reservations []models.Reservation
+
+	query := `
+		select id, first_name, last_name, email, phone, start_date, end_date, room_id, processed
+		from reservations
+		where room_id = $1 and end_date > $2
+		order by start_date
+	`
+
+	rows, err := repo.DB.QueryContext(ctx, query, roomID, day)
+
+	if err != nil {
+		return reservations, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var r models.Reservation
+		err := rows.Scan(&r.ID, &r.FirstName, &r.LastName, &r.Email, &r.Phone, &r.StartDate, &r.EndDate, &r.RoomID, &r.Processed)
+
+		if err != nil {
+			return reservations, err
+		}
+
+		reservations = append(reservations, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return reservations, err
+	}
+
+	return reservations, nil
+}
+
+// GetUserByEmail returns a user from DB by email
+func (repo *postgresDBRepo) GetUserByEmail(email string) (models.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var u models.User
+
+	query := `
+		select id, first_name, last_name, email, password, access_level, active, totp_secret, created_at, updated_at
+		from users
+		where email = $1
+	`
+
+	row := repo.DB.QueryRowContext(ctx, query, email)
+	err := row.Scan(&u.ID, &u.FirstName, &u.LastName, &u.Email, &u.Password, &u.AccessLevel, &u.Active, &u.TOTPSecret,
+		&u.CreatedAt, &u.UpdatedAt)
+
+	if err != nil {
+		return u, err
+	}
+
+	return u, nil
+}
+
+// InsertUser inserts an invited user without a password, the user sets it with the invitation link
+func (repo *postgresDBRepo) InsertUser(u models.User) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var id int
+
+	query := `
+		insert into users (first_name, last_name, email, password, access_level, active, created_at, updated_at)
+		values ($1, $2, $3, '', $4, 1, $5, $6) returning id
+	`
+
+	err := repo.DB.QueryRowContext(ctx, query, u.FirstName, u.LastName, u.Email, u.AccessLevel, time.Now(),
+		time.Now()).Scan(&id)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// ResetUserPassword removes the user's password, the user can't log in until a new one is set with a reset link
+func (repo *postgresDBRepo) ResetUserPassword(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := repo.DB.ExecContext(ctx, `update users set password = '', updated_at = $1 where id = $2`, time.Now(), id)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// hashToken returns the hash of a token that is stored in DB instead of the token itself
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// InsertUserToken saves the hash of a new user token
+func (repo *postgresDBRepo) InsertUserToken(t models.UserToken) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `
+		insert into user_tokens (user_id, token_hash, purpose, email, expires_at, created_at, updated_at)
+		values ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	_, err := repo.DB.ExecContext(ctx, query, t.UserID, hashToken(t.Token), t.Purpose, t.Email, t.ExpiresAt, time.Now(),
+		time.Now())
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetUserToken returns an unused and unexpired user token with its user, sql.ErrNoRows is returned for others
+func (repo *postgresDBRepo) GetUserToken(token string) (models.UserToken, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var t models.UserToken
+
+	query := `
+		select t.id, t.user_id, t.purpose, t.email, t.expires_at, t.created_at, t.updated_at,
+			u.id, u.first_name, u.last_name, u.email, u.access_level, u.active
+		from user_tokens t
+		left join users u on (t.user_id = u.id)
+		where t.token_hash = $1 and t.used_at is null and t.expires_at > $2 and u.active = 1
+	`
+
+	row := repo.DB.QueryRowContext(ctx, query, hashToken(token), time.Now())
+	err := row.Scan(&t.ID, &t.UserID, &t.Purpose, &t.Email, &t.ExpiresAt, &t.CreatedAt, &t.UpdatedAt,
+		&t.User.ID, &t.User.FirstName, &t.User.LastName, &t.User.Email, &t.User.AccessLevel, &t.User.Active)
+
+	if err != nil {
+		return t, err
+	}
+
+	t.Token = token
+
+	return t, nil
+}
+
+// SetPasswordWithToken uses the token to set the user's password, the user's other tokens can't be used after it
+func (repo *postgresDBRepo) SetPasswordWithToken(t models.UserToken, password string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+
+	if err != nil {
+		return err
+	}
+
+	tx, err := repo.DB.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	// rollback does nothing after the transaction is committed
+	defer tx.Rollback()
+
+	err = useUserTokenTx(ctx, tx, t.ID)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `update users set password = $1, updated_at = $2 where id = $3`,
+		string(hashedPassword), time.Now(), t.UserID)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		update user_tokens set used_at = $1, updated_at = $1
+		where user_id = $2 and used_at is null
+	`, time.Now(), t.UserID)
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// useUserTokenTx marks the token as used, sql.ErrNoRows is returned if it is used or expired
+func useUserTokenTx(ctx context.Context, tx *sql.Tx, id int) error {
+	// the token is used in the same statement that checks it, so it can't be used twice at the same time
+	result, err := tx.ExecContext(ctx, `
+		update user_tokens set used_at = $1, updated_at = $1
+		where id = $2 and used_at is null and expires_at > $1
+	`, time.Now(), id)
+
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// ConfirmEmailWithToken uses the email change token to set the user's email to the token's email
+func (repo *postgresDBRepo) ConfirmEmailWithToken(t models.UserToken) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := repo.DB.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	// rollback does nothing after the transaction is committed
+	defer tx.Rollback()
+
+	err = useUserTokenTx(ctx, tx, t.ID)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `update users set email = $1, updated_at = $2 where id = $3`, t.Email, time.Now(), t.UserID)
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ChangePassword sets the user's password, the user's unused tokens can't be used after it
+func (repo *postgresDBRepo) ChangePassword(id int, password string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+
+	if err != nil {
+		return err
+	}
+
+	tx, err := repo.DB.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	// rollback does nothing after the transaction is committed
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `update users set password = $1, updated_at = $2 where id = $3`,
+		string(hashedPassword), time.Now(), id)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		update user_tokens set used_at = $1, updated_at = $1
+		where user_id = $2 and used_at is null
+	`, time.Now(), id)
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// InsertAuditEntry saves an entry to the audit log, entries without a user are saved without one
+func (repo *postgresDBRepo) InsertAuditEntry(e models.AuditEntry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// zero values are saved as null, so the foreign key and the JSON columns accept them
+	var userID, before, after interface{}
+
+	if e.UserID > 0 {
+		userID = e.UserID
+	}
+
+	if e.Before != "" {
+		before = e.Before
+	}
+
+	if e.After != "" {
+		after = e.After
+	}
+
+	query := `
+		insert into audit_logs (user_id, action, entity, entity_id, before, after, ip, created_at, updated_at)
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+
+	_, err := repo.DB.ExecContext(ctx, query, userID, e.Action, e.Entity, e.EntityID, before, after, e.IP, time.Now(),
+		time.Now())
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// auditFilterQuery builds the where clause and its arguments of an audit filter
+func auditFilterQuery(f models.AuditFilter) (string, []interface{}) {
+	var where []string
+	var args []interface{}
+
+	add := func(clause string, arg interface{}) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(clause, len(args)))
+	}
+
+	if f.UserID > 0 {
+		add("a.user_id = $%d", f.UserID)
+	}
+
+	if f.Action != "" {
+		add("a.action = $%d", f.Action)
+	}
+
+	if f.Entity != "" {
+		add("a.entity = $%d", f.Entity)
+	}
+
+	if !f.StartDate.IsZero() {
+		add("a.created_at >= $%d", f.StartDate)
+	}
+
+	// the end date is included as a whole day
+	if !f.EndDate.IsZero() {
+		add("a.created_at < $%d", f.EndDate.AddDate(0, 0, 1))
+	}
+
+	if len(where) == 0 {
+		return "", args
+	}
+
+	return "where " + strings.Join(where, " and "), args
+}
+
+// FilterAuditEntries returns a page of the audit entries that match the filter with their users, latest first, and
+// the number of all matching entries
+func (repo *postgresDBRepo) FilterAuditEntries(filter models.AuditFilter) ([]models.AuditEntry, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var entries []models.AuditEntry
+	var total int
+
+	where, args := auditFilterQuery(filter)
+
+	err := repo.DB.QueryRowContext(ctx, fmt.Sprintf(`select count(a.id) from audit_logs a %s`, where), args...).Scan(&total)
+
+	if err != nil {
+		return entries, 0, err
+	}
+
+	query := fmt.Sprintf(`
+		select a.id, coalesce(a.user_id, 0), a.action, a.entity, a.entity_id, coalesce(a.before::text, ''),
+			coalesce(a.after::text, ''), a.ip, a.created_at, coalesce(u.first_name, ''), coalesce(u.last_name, ''),
+			coalesce(u.email, '')
+		from audit_logs a
+		left join users u on (a.user_id = u.id)
+		%s
+		order by a.created_at desc, a.id desc
+		limit $%d offset $%d
+	`, where, len(args)+1, len(args)+2)
+
+	rows, err := repo.DB.QueryContext(ctx, query, append(args, filter.PerPage, filter.Offset())...)
+
+	if err != nil {
+		return entries, 0, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var e models.AuditEntry
+		err := rows.Scan(
+			&e.ID,
+			&e.UserID,
+			&e.Action,
+			&e.Entity,
+			&e.EntityID,
+			&e.Before,
+			&e.After,
+			&e.IP,
+			&e.CreatedAt,
+			&e.User.FirstName,
+			&e.User.LastName,
+			&e.User.Email,
+		)
+
+		if err != nil {
+			return entries, 0, err
+		}
+
+		e.User.ID = e.UserID
+		entries = append(entries, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return entries, 0, err
+	}
+
+	return entries, total, nil
+}
+
+// UpdateProcessedForReservations updates processed for all reservations, if any of them doesn't exist none of them are
+// updated
+func (repo *postgresDBRepo) UpdateProcessedForReservations(ids []int, processed int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := repo.DB.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	// rollback does nothing after the transaction is committed
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `update reservations set processed = $1, updated_at = $2 where id = any($3)`,
+		processed, time.Now(), ids)
+
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if affected != int64(len(ids)) {
+		return repository.ErrReservationsChanged
+	}
+
+	return tx.Commit()
+}
+
+// DeleteReservations deletes all reservations, their restrictions are deleted with them, if any of them doesn't exist
+// none of them are deleted
+func (repo *postgresDBRepo) DeleteReservations(ids []int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := repo.DB.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	// rollback does nothing after the transaction is committed
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `delete from reservations where id = any($1)`, ids)
+
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if affected != int64(len(ids)) {
+		return repository.ErrReservationsChanged
+	}
+
+	return tx.Commit()
+}
+
+// RoomTimeline returns all rooms in their order with the reservations and blocks that overlap the period from start
+// until end, the reservations come with their guests' names. Rooms and restrictions are read in a single query, so the
+// timeline doesn't need a query for each room
+func (repo *postgresDBRepo) RoomTimeline(start, end time.Time) ([]models.TimelineRoom, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var timeline []models.TimelineRoom
+
+	// rooms without restrictions in the period have a single row with null restriction columns
+	query := `
+		select rm.id, rm.room_name, rm.active, coalesce(rr.id, 0), coalesce(rr.reservation_id, 0),
+			coalesce(rr.restriction_id, 0), coalesce(rr.ical_import_id, 0), coalesce(rr.start_date, $1),
+			coalesce(rr.end_date, $1), coalesce(r.first_name, ''), coalesce(r.last_name, ''), coalesce(r.processed, 0)
+		from rooms rm
+		left join room_restrictions rr on (rr.room_id = rm.id and rr.start_date < $2 and rr.end_date > $1)
+		left join reservations r on (rr.reservation_id = r.id)
+		order by rm.sort_order, rm.room_name, rm.id, rr.start_date
+	`
+
+	rows, err := repo.DB.QueryContext(ctx, query, start, end)
+
+	if err != nil {
+		return timeline, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var room models.Room
+		var rr models.RoomRestriction
+
+		err := rows.Scan(
+			&room.ID,
+			&room.RoomName,
+			&room.Active,
+			&rr.ID,
+			&rr.ReservationID,
+			&rr.RestrictionID,
+			&rr.ICalImportID,
+			&rr.StartDate,
+			&rr.EndDate,
+			&rr.Reservation.FirstName,
+			&rr.Reservation.LastName,
+			&rr.Reservation.Processed,
+		)
+
+		if err != nil {
+			return timeline, err
+		}
+
+		if len(timeline) == 0 || timeline[len(timeline)-1].Room.ID != room.ID {
+			timeline = append(timeline, models.TimelineRoom{Room: room})
+		}
+
+		if rr.ID > 0 {
+			rr.RoomID = room.ID
+			rr.Reservation.ID = rr.ReservationID
+			current := &timeline[len(timeline)-1]
+			current.Restrictions = append(current.Restrictions, rr)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return timeline, err
+	}
+
+	return timeline, nil
+}
+
+// guestColumns are the columns scanned by scanGuest
+const guestColumns = `g.id, g.first_name, g.last_name, g.email, g.phone, g.notes, g.tags, g.created_at, g.updated_at`
+
+// scanGuest scans the guestColumns of a row into a guest
+func scanGuest(row interface{ Scan(...interface{}) error }, extra ...interface{}) (models.Guest, error) {
+	var g models.Guest
+	var tags string
+
+	err := row.Scan(append([]interface{}{
+		&g.ID,
+		&g.FirstName,
+		&g.LastName,
+		&g.Email,
+		&g.Phone,
+		&g.Notes,
+		&tags,
+		&g.CreatedAt,
+		&g.UpdatedAt,
+	}, extra...)...)
+
+	g.Tags = splitList(tags)
+
+	return g, err
+}
+
+// FilterGuests returns a page of the guests that match the filter with their number of stays, and the number of all
+// matching guests
+func (repo *postgresDBRepo) FilterGuests(filter models.GuestFilter) ([]models.Guest, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var guests []models.Guest
+	var total int
+
+	var where []string
+	var args []interface{}
+
+	if filter.Search != "" {
+		args = append(args, "%"+strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(filter.Search)+"%")
+		where = append(where, fmt.Sprintf(`(g.first_name ilike $%[1]d or g.last_name ilike $%[1]d
+			or g.first_name || ' ' || g.last_name ilike $%[1]d or g.email ilike $%[1]d or g.phone ilike $%[1]d)`, len(args)))
+	}
+
+	if filter.Tag != "" {
+		args = append(args, strings.ToLower(filter.Tag))
+		where = append(where, fmt.Sprintf(`$%d = any(string_to_array(lower(g.tags), ','))`, len(args)))
+	}
+
+	whereClause := ""
+	if len(where) > 0 {
+		whereClause = "where " + strings.Join(where, " and ")
+	}
+
+	err := repo.DB.QueryRowContext(ctx, fmt.Sprintf(`select count(g.id) from guests g %s`, whereClause), args...).Scan(&total)
+
+	if err != nil {
+		return guests, 0, err
+	}
+
+	query := fmt.Sprintf(`
+		select %s, (select count(r.id) from reservations r where r.guest_id = g.id)
+		from guests g
+		%s
+		order by g.last_name, g.first_name, g.id
+		limit $%d offset $%d
+	`, guestColumns, whereClause, len(args)+1, len(args)+2)
+
+	rows, err := repo.DB.QueryContext(ctx, query, append(args, filter.PerPage, filter.Offset())...)
+
+	if err != nil {
+		return guests, 0, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var stays int
+
+		g, err := scanGuest(rows, &stays)
+
+		if err != nil {
+			return guests, 0, err
+		}
+
+		g.Stays = stays
+		guests = append(guests, g)
+	}
+
+	if err = rows.Err(); err != nil {
+		return guests, 0, err
+	}
+
+	return guests, total, nil
+}
+
+// GetGuestById returns a guest by id
+func (repo *postgresDBRepo) GetGuestById(id int) (models.Guest, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	row := repo.DB.QueryRowContext(ctx, fmt.Sprintf(`select %s from guests g where g.id = $1`, guestColumns), id)
+
+	return scanGuest(row)
+}
+
+// GetGuestByEmail returns the guest with the email, emails are compared in their normalized form
+func (repo *postgresDBRepo) GetGuestByEmail(email string) (models.Guest, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	row := repo.DB.QueryRowContext(ctx, fmt.Sprintf(`select %s from guests g where g.email_normalized = $1 and $1 <> ''`,
+		guestColumns), models.NormalizeEmail(email))
+
+	return scanGuest(row)
+}
+
+// UpdateGuest updates a guest's details, notes and tags
+func (repo *postgresDBRepo) UpdateGuest(g models.Guest) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `
+		update guests set first_name = $1, last_name = $2, email = $3, email_normalized = $4, phone = $5, notes = $6,
+			tags = $7, updated_at = $8
+		where id = $9
+	`
+
+	_, err := repo.DB.ExecContext(ctx, query, g.FirstName, g.LastName, strings.TrimSpace(g.Email),
+		models.NormalizeEmail(g.Email), g.Phone, g.Notes, strings.Join(g.Tags, ","), time.Now(), g.ID)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ReservationsForGuest returns all reservations of a guest with their rooms, latest stays first
+func (repo *postgresDBRepo) ReservationsForGuest(guestID int) ([]models.Reservation, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var reservations []models.Reservation
+
+	query := `
+		select r.id, r.first_name, r.last_name, r.email, r.phone, r.start_date, r.end_date, r.room_id, r.created_at,
+			r.updated_at, r.processed, rm.id, rm.room_name
+		from reservations r
+		left join rooms rm on (r.room_id = rm.id)
+		where r.guest_id = $1
+		order by r.start_date desc
+	`
+
+	rows, err := repo.DB.QueryContext(ctx, query, guestID)
+
+	if err != nil {
+		return reservations, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var i models.Reservation
+
+		err := rows.Scan(
+			&i.ID,
+			&i.FirstName,
+			&i.LastName,
+			&i.Email,
+			&i.Phone,
+			&i.StartDate,
+			&i.EndDate,
+			&i.RoomID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Processed,
+			&i.Room.ID,
+			&i.Room.RoomName,
+		)
+
+		if err != nil {
+			return reservations, err
+		}
+
+		i.GuestID = guestID
+		reservations = append(reservations, i)
+	}
+
+	if err = rows.Err(); err != nil {
+		return reservations, err
+	}
+
+	return reservations, nil
+}
+
+// GuestDuplicates returns the other guests that might be the same person, they have the same name or phone
+func (repo *postgresDBRepo) GuestDuplicates(g models.Guest) ([]models.Guest, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var guests []models.Guest
+
+	query := fmt.Sprintf(`
+		select %s, (select count(r.id) from reservations r where r.guest_id = g.id)
+		from guests g
+		where g.id <> $1 and (
+			(lower(g.first_name) = lower($2) and lower(g.last_name) = lower($3))
+			or (g.phone <> '' and g.phone = $4)
+		)
+		order by g.id
+	`, guestColumns)
+
+	rows, err := repo.DB.QueryContext(ctx, query, g.ID, g.FirstName, g.LastName, g.Phone)
+
+	if err != nil {
+		return guests, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var stays int
+
+		d, err := scanGuest(rows, &stays)
+
+		if err != nil {
+			return guests, err
+		}
+
+		d.Stays = stays
+		guests = append(guests, d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return guests, err
+	}
+
+	return guests, nil
+}
+
+// MergeGuests moves the duplicate's reservations to the guest and deletes the duplicate in a single transaction, the
+// duplicate's notes and tags are added to the guest's, and its email and phone fill the guest's empty ones
+func (repo *postgresDBRepo) MergeGuests(id, duplicateID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := repo.DB.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	// rollback does nothing after the transaction is committed
+	defer tx.Rollback()
+
+	query := fmt.Sprintf(`select %s from guests g where g.id = $1 for update`, guestColumns)
+
+	guest, err := scanGuest(tx.QueryRowContext(ctx, query, id))
+
+	if err != nil {
+		return err
+	}
+
+	duplicate, err := scanGuest(tx.QueryRowContext(ctx, query, duplicateID))
+
+	if err != nil {
+		return err
+	}
+
+	guest = models.MergeGuest(guest, duplicate)
+
+	_, err = tx.ExecContext(ctx, `update reservations set guest_id = $1 where guest_id = $2`, id, duplicateID)
+
+	if err != nil {
+		return err
+	}
+
+	// the duplicate is deleted first, so its email can be moved to the guest
+	_, err = tx.ExecContext(ctx, `delete from guests where id = $1`, duplicateID)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		update guests set email = $1, email_normalized = $2, phone = $3, notes = $4, tags = $5, updated_at = $6
+		where id = $7
+	`, guest.Email, models.NormalizeEmail(guest.Email), guest.Phone, guest.Notes, strings.Join(guest.Tags, ","),
+		time.Now(), id)
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// InsertReservationNote inserts a note of a reservation and returns its id
+func (repo *postgresDBRepo) InsertReservationNote(n models.ReservationNote) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var id int
+
+	// notes without an author are saved with a null user, so the foreign key accepts them
+	var userID interface{}
+
+	if n.UserID > 0 {
+		userID = n.UserID
+	}
+
+	query := `
+		insert into reservation_notes (reservation_id, user_id, body, created_at, updated_at)
+		values ($1, $2, $3, $4, $5)
+		returning id
+	`
+
+	err := repo.DB.QueryRowContext(ctx, query,
+		n.ReservationID,
+		userID,
+		n.Body,
+		time.Now(),
+		time.Now(),
+	).Scan(&id)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// NotesForReservation returns the notes of a reservation with their authors, oldest first
+func (repo *postgresDBRepo) NotesForReservation(reservationID int) ([]models.ReservationNote, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var notes []models.ReservationNote
+
+	query := `
+		select n.id, n.reservation_id, coalesce(n.user_id, 0), n.body, n.created_at, n.updated_at,
+			coalesce(u.id, 0), coalesce(u.first_name, ''), coalesce(u.last_name, '')
+		from reservation_notes n
+		left join users u on (n.user_id = u.id)
+		where n.reservation_id = $1
+		order by n.created_at, n.id
+	`
+
+	rows, err := repo.DB.QueryContext(ctx, query, reservationID)
+
+	if err != nil {
+		return notes, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var n models.ReservationNote
+		err := rows.Scan(
+			&n.ID,
+			&n.ReservationID,
+			&n.UserID,
+			&n.Body,
+			&n.CreatedAt,
+			&n.UpdatedAt,
+			&n.User.ID,
+			&n.User.FirstName,
+			&n.User.LastName,
+		)
+
+		if err != nil {
+			return notes, err
+		}
+
+		notes = append(notes, n)
+	}
+
+	if err = rows.Err(); err != nil {
+		return notes, err
+	}
+
+	return notes, nil
+}
+
+// Search returns the reservations, guests and rooms whose names, emails or phones contain the query, at most limit of
+// each, the best matches first, a number searches reservation ids too, and room descriptions are searched by words
+func (repo *postgresDBRepo) Search(query string, limit int) (models.SearchResults, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var results models.SearchResults
+
+	pattern := containsPattern(query)
+
+	// reservation references are their ids, admins might type them with a leading #
+	id, err := strconv.Atoi(strings.TrimPrefix(query, "#"))
+
+	if err != nil {
+		id = 0
+	}
+
+	rows, err := repo.DB.QueryContext(ctx, `
+		select r.id, r.first_name, r.last_name, r.email, r.phone, r.start_date, r.end_date, r.room_id, r.processed,
+			coalesce(rm.id, 0), coalesce(rm.room_name, '')
+		from reservations r
+		left join rooms rm on (r.room_id = rm.id)
+		where r.id = $1 or r.first_name || ' ' || r.last_name ilike $2 or r.email ilike $2 or r.phone ilike $2
+		order by r.id = $1 desc, similarity(r.first_name || ' ' || r.last_name, $3) desc, r.start_date desc
+		limit $4
+	`, id, pattern, query, limit)
+
+	if err != nil {
+		return results, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var r models.Reservation
+		err := rows.Scan(
+			&r.ID,
+			&r.FirstName,
+			&r.LastName,
+			&r.Email,
+			&r.Phone,
+			&r.StartDate,
+			&r.EndDate,
+			&r.RoomID,
+			&r.Processed,
+			&r.Room.ID,
+			&r.Room.RoomName,
+		)
+
+		if err != nil {
+			return results, err
+		}
+
+		results.Reservations = append(results.Reservations, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return results, err
+	}
+
+	rows, err = repo.DB.QueryContext(ctx, fmt.Sprintf(`
+		select %s, (select count(r.id) from reservations r where r.guest_id = g.id)
+		from guests g
+		where g.first_name || ' ' || g.last_name ilike $1 or g.email ilike $1 or g.phone ilike $1
+		order by similarity(g.first_name || ' ' || g.last_name, $2) desc, g.last_name, g.first_name
+		limit $3
+	`, guestColumns), pattern, query, limit)
+
+	if err != nil {
+		return results, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var stays int
+
+		g, err := scanGuest(rows, &stays)
+
+		if err != nil {
+			return results, err
+		}
+
+		g.Stays = stays
+		results.Guests = append(results.Guests, g)
+	}
+
+	if err = rows.Err(); err != nil {
+		return results, err
+	}
+
+	rows, err = repo.DB.QueryContext(ctx, `
+		select id, room_name, description, active
+		from rooms
+		where room_name ilike $1 or to_tsvector('simple', description) @@ plainto_tsquery('simple', $2)
+		order by similarity(room_name, $2) desc, sort_order, room_name
+		limit $3
+	`, pattern, query, limit)
+
+	if err != nil {
+		return results, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var room models.Room
+		err := rows.Scan(
+			&room.ID,
+			&room.RoomName,
+			&room.Description,
+			&room.Active,
+		)
+
+		if err != nil {
+			return results, err
+		}
+
+		results.Rooms = append(results.Rooms, room)
+	}
+
+	if err = rows.Err(); err != nil {
+		return results, err
+	}
+
+	return results, nil
+}
+
+// EnableTwoFactor saves the user's confirmed TOTP secret and replaces the user's recovery codes, only the hashes of
+// the codes are stored
+func (repo *postgresDBRepo) EnableTwoFactor(userID int, secret string, recoveryCodes []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := repo.DB.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	// rollback does nothing after the transaction is committed
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `update users set totp_secret = $1, updated_at = $2 where id = $3`, secret, time.Now(), userID)
+
+	if err != nil {
+		return err
+	}
+
+	err = replaceRecoveryCodesTx(ctx, tx, userID, recoveryCodes)
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DisableTwoFactor removes the user's TOTP secret and recovery codes, the user logs in with the password only
+func (repo *postgresDBRepo) DisableTwoFactor(userID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := repo.DB.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	// rollback does nothing after the transaction is committed
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `update users set totp_secret = '', updated_at = $1 where id = $2`, time.Now(), userID)
+
+	if err != nil {
+		return err
+	}
+
+	err = replaceRecoveryCodesTx(ctx, tx, userID, nil)
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ReplaceRecoveryCodes replaces the user's recovery codes with new ones, the old ones can't be used anymore
+func (repo *postgresDBRepo) ReplaceRecoveryCodes(userID int, recoveryCodes []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := repo.DB.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	// rollback does nothing after the transaction is committed
+	defer tx.Rollback()
+
+	err = replaceRecoveryCodesTx(ctx, tx, userID, recoveryCodes)
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// replaceRecoveryCodesTx deletes the user's recovery codes and saves the hashes of the new ones in the transaction
+func replaceRecoveryCodesTx(ctx context.Context, tx *sql.Tx, userID int, recoveryCodes []string) error {
+	_, err := tx.ExecContext(ctx, `delete from user_recovery_codes where user_id = $1`, userID)
+
+	if err != nil {
+		return err
+	}
+
+	for _, code := range recoveryCodes {
+		_, err = tx.ExecContext(ctx, `
+			insert into user_recovery_codes (user_id, code_hash, created_at, updated_at)
+			values ($1, $2, $3, $4)
+		`, userID, hashToken(code), time.Now(), time.Now())
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UseRecoveryCode marks the user's recovery code as used, sql.ErrNoRows is returned if the code is unknown or used
+func (repo *postgresDBRepo) UseRecoveryCode(userID int, code string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// the code is used in the same statement that checks it, so it can't be used twice at the same time
+	result, err := repo.DB.ExecContext(ctx, `
+		update user_recovery_codes set used_at = $1, updated_at = $1
+		where user_id = $2 and code_hash = $3 and used_at is null
+	`, time.Now(), userID, hashToken(code))
+
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// CountRecoveryCodes returns how many of the user's recovery codes are not used yet
+func (repo *postgresDBRepo) CountRecoveryCodes(userID int) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var count int
+
+	err := repo.DB.QueryRowContext(ctx, `
+		select count(id) from user_recovery_codes where user_id = $1 and used_at is null
+	`, userID).Scan(&count)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// InsertLoginFailure records a failed login of the email from the IP address
+func (repo *postgresDBRepo) InsertLoginFailure(email, ip string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := repo.DB.ExecContext(ctx, `
+		insert into login_failures (email, ip, created_at, updated_at)
+		values (lower($1), $2, $3, $4)
+	`, email, ip, time.Now(), time.Now())
+
+	return err
+}
+
+// LoginFailuresSince counts the failed logins of the email and of the IP address since the time
+func (repo *postgresDBRepo) LoginFailuresSince(email, ip string, since time.Time) (models.LoginFailures, models.LoginFailures, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	account := models.LoginFailures{Email: strings.ToLower(email)}
+	byIP := models.LoginFailures{IP: ip}
+
+	query := `
+		select
+			count(*) filter (where email = lower($1)),
+			coalesce(max(created_at) filter (where email = lower($1)), '0001-01-01'),
+			count(*) filter (where ip = $2),
+			coalesce(max(created_at) filter (where ip = $2), '0001-01-01')
+		from login_failures
+		where (email = lower($1) or ip = $2) and created_at > $3
+	`
+
+	err := repo.DB.QueryRowContext(ctx, query, email, ip, since).Scan(
+		&account.Count,
+		&account.Last,
+		&byIP.Count,
+		&byIP.Last,
+	)
+
+	return account, byIP, err
+}
+
+// DeleteLoginFailuresBefore removes the failed logins older than the time and returns how many were removed
+func (repo *postgresDBRepo) DeleteLoginFailuresBefore(before time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := repo.DB.ExecContext(ctx, `delete from login_failures where created_at < $1`, before)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// ClearLoginFailures removes the failed logins of the email and of the IP address, empty values are skipped
+func (repo *postgresDBRepo) ClearLoginFailures(email, ip string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := repo.DB.ExecContext(ctx, `
+		delete from login_failures
+		where ($1 <> '' and email = lower($1)) or ($2 <> '' and ip = $2)
+	`, email, ip)
+
+	return err
+}
+
+// LockedLogins returns the emails with at least accountLimit and the IP addresses with at least ipLimit failed logins
+// since the time, the most recent first
+func (repo *postgresDBRepo) LockedLogins(since time.Time, accountLimit, ipLimit int) ([]models.LoginFailures, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var locked []models.LoginFailures
+
+	query := `
+		select email, '', count(*), max(created_at)
+		from login_failures
+		where created_at > $1 and email <> ''
+		group by email
+		having count(*) >= $2
+		union all
+		select '', ip, count(*), max(created_at)
+		from login_failures
+		where created_at > $1 and ip <> ''
+		group by ip
+		having count(*) >= $3
+		order by 4 desc
+	`
+
+	rows, err := repo.DB.QueryContext(ctx, query, since, accountLimit, ipLimit)
+
+	if err != nil {
+		return locked, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var f models.LoginFailures
+
+		err = rows.Scan(&f.Email, &f.IP, &f.Count, &f.Last)
+
+		if err != nil {
+			return locked, err
+		}
+
+		locked = append(locked, f)
+	}
+
+	return locked, rows.Err()
+}
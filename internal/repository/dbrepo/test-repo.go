@@ -1,16 +1,26 @@
 package dbrepo
 
 import (
+	"database/sql"
 	"errors"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/burakkarasel/bookings/internal/models"
+	"github.com/burakkarasel/bookings/internal/repository"
 )
 
 // for now i only need this functions to exist, so I can make my unit test with other packages
 
-func (repo *testDBRepo) AllUsers() bool {
-	return true
+// AllUsers returns all of the staff users
+func (repo *testDBRepo) AllUsers() ([]models.User, error) {
+	users := []models.User{
+		{ID: 1, FirstName: "Admin", LastName: "Admin", Email: "admin@admin.com", Password: "hash", AccessLevel: 3, Active: 1},
+		{ID: 2, FirstName: "Jane", LastName: "Doe", Email: "jane@here.com", AccessLevel: 1, Active: 1},
+	}
+
+	return users, nil
 }
 
 // InsertReservation inserts a reservation into database
@@ -38,9 +48,19 @@ func (repo *testDBRepo) SearchAvailabilityByDatesByRoomID(start, end time.Time,
 		return false, errors.New("some error")
 	}
 
+	// the room 2 is booked for the first week of 2030
+	if roomID == 2 && !start.After(time.Date(2030, 1, 7, 0, 0, 0, 0, time.UTC)) && !end.Before(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		return false, nil
+	}
+
 	return true, nil
 }
 
+// SearchAvailabilityExcludingReservation returns true if the room is available, ignoring the reservation's restriction
+func (repo *testDBRepo) SearchAvailabilityExcludingReservation(start, end time.Time, roomID, reservationID int) (bool, error) {
+	return repo.SearchAvailabilityByDatesByRoomID(start, end, roomID)
+}
+
 // SearchAvailabilityForAllRooms checks for all rooms restriction's in a given period of time and returns available rooms
 func (repo *testDBRepo) SearchAvailabilityForAllRooms(start, end time.Time) ([]models.Room, error) {
 	if start.Format("2006-01-02") == "2023-02-19" {
@@ -58,13 +78,29 @@ func (repo *testDBRepo) GetRoomById(id int) (models.Room, error) {
 	return room, nil
 }
 
+// TestTOTPSecret is the TOTP secret of user 2, tests generate valid codes with it
+const TestTOTPSecret = "JBSWY3DPEHPK3PXP"
+
 // GetUserById gets user from DB by id
 func (repo *testDBRepo) GetUserById(id int) (models.User, error) {
-	return models.User{}, nil
+	if id > 2 {
+		return models.User{}, errors.New("some error")
+	}
+
+	// user 2 has two-factor authentication
+	if id == 2 {
+		return models.User{ID: id, Email: "two-factor@here.com", Active: 1, TOTPSecret: TestTOTPSecret}, nil
+	}
+
+	return models.User{ID: id, Active: 1}, nil
 }
 
 // UpdateUser updates a user
 func (repo *testDBRepo) UpdateUser(u models.User) error {
+	if u.LastName == "Fail" {
+		return errors.New("some error")
+	}
+
 	return nil
 }
 
@@ -73,25 +109,25 @@ func (repo *testDBRepo) Authenticate(email, testPassword string) (int, string, e
 	if email == "jack@nimble.com" {
 		return 0, "", errors.New("some error")
 	}
+	if email == "two-factor@here.com" {
+		return 2, "", nil
+	}
+	if testPassword == "wrong" {
+		return 0, "", errors.New("some error")
+	}
 	return 0, "", nil
 }
 
-func (repo *testDBRepo) AllReservations() ([]models.Reservation, error) {
-	var reservations []models.Reservation
-	return reservations, nil
-}
-
-func (repo *testDBRepo) AllNewReservations() ([]models.Reservation, error) {
-	var reservations []models.Reservation
-	return reservations, nil
-}
-
 func (repo *testDBRepo) GetReservationById(id int) (models.Reservation, error) {
 	var reservation models.Reservation
 	return reservation, nil
 }
 
 func (repo *testDBRepo) UpdateReservation(r models.Reservation) error {
+	if r.LastName == "Fail" {
+		return errors.New("some error")
+	}
+
 	return nil
 }
 
@@ -114,6 +150,16 @@ func (repo *testDBRepo) AllRooms() ([]models.Room, error) {
 func (repo *testDBRepo) GetRestrictionsForRoomByDate(roomID int, start, end time.Time) ([]models.RoomRestriction, error) {
 
 	var restrictions []models.RoomRestriction
+	if roomID == 2 {
+		restrictions = append(restrictions, models.RoomRestriction{
+			ID:            1,
+			ReservationID: 1,
+			RestrictionID: 1,
+			RoomID:        roomID,
+			StartDate:     start,
+			EndDate:       end,
+		})
+	}
 	return restrictions, nil
 }
 
@@ -126,3 +172,703 @@ func (repo *testDBRepo) InsertBlockForRoom(id int, startDate time.Time) error {
 func (repo *testDBRepo) RemoveBlockForRoom(id int) error {
 	return nil
 }
+
+// AllWebhookEndpoints returns all of the webhook endpoints from DB
+func (repo *testDBRepo) AllWebhookEndpoints() ([]models.WebhookEndpoint, error) {
+	var endpoints []models.WebhookEndpoint
+	return endpoints, nil
+}
+
+// ActiveWebhookEndpoints returns the webhook endpoints that are active
+func (repo *testDBRepo) ActiveWebhookEndpoints() ([]models.WebhookEndpoint, error) {
+	var endpoints []models.WebhookEndpoint
+	return endpoints, nil
+}
+
+// GetWebhookEndpointById returns a webhook endpoint by id
+func (repo *testDBRepo) GetWebhookEndpointById(id int) (models.WebhookEndpoint, error) {
+	var e models.WebhookEndpoint
+	if id > 2 {
+		return e, errors.New("some error")
+	}
+	e.ID = id
+	return e, nil
+}
+
+// InsertWebhookEndpoint inserts a new webhook endpoint into DB
+func (repo *testDBRepo) InsertWebhookEndpoint(e models.WebhookEndpoint) (int, error) {
+	if e.URL == "http://fail.com" {
+		return 0, errors.New("some error")
+	}
+	return 1, nil
+}
+
+// UpdateWebhookEndpoint updates a webhook endpoint in DB
+func (repo *testDBRepo) UpdateWebhookEndpoint(e models.WebhookEndpoint) error {
+	if e.URL == "http://fail.com" {
+		return errors.New("some error")
+	}
+	return nil
+}
+
+// DeleteWebhookEndpoint deletes a webhook endpoint and its deliveries from DB
+func (repo *testDBRepo) DeleteWebhookEndpoint(id int) error {
+	return nil
+}
+
+// InsertWebhookDelivery inserts a new delivery into the delivery log
+func (repo *testDBRepo) InsertWebhookDelivery(d models.WebhookDelivery) (int, error) {
+	return 1, nil
+}
+
+// UpdateWebhookDelivery updates the result of a delivery after an attempt
+func (repo *testDBRepo) UpdateWebhookDelivery(d models.WebhookDelivery) error {
+	return nil
+}
+
+// WebhookDeliveriesForEndpoint returns the latest deliveries of an endpoint
+func (repo *testDBRepo) WebhookDeliveriesForEndpoint(endpointID, limit int) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	return deliveries, nil
+}
+
+// AllICalFeeds returns all of the calendar feeds with their rooms
+func (repo *testDBRepo) AllICalFeeds() ([]models.ICalFeed, error) {
+	var feeds []models.ICalFeed
+	return feeds, nil
+}
+
+// GetICalFeedByToken returns a calendar feed by its token
+func (repo *testDBRepo) GetICalFeedByToken(token string) (models.ICalFeed, error) {
+	var f models.ICalFeed
+	if token == "invalid" {
+		return f, errors.New("some error")
+	}
+	f.RoomID = 1
+	f.Token = token
+	if token == "staff" {
+		f.IncludeGuests = 1
+	}
+	return f, nil
+}
+
+// InsertICalFeed inserts a new calendar feed into DB
+func (repo *testDBRepo) InsertICalFeed(f models.ICalFeed) (int, error) {
+	if f.RoomID > 2 {
+		return 0, errors.New("some error")
+	}
+	return 1, nil
+}
+
+// DeleteICalFeed deletes a calendar feed from DB, so its token can't be used anymore
+func (repo *testDBRepo) DeleteICalFeed(id int) error {
+	return nil
+}
+
+// GetRestrictionsForRoomFeed returns a room's restrictions that end after since
+func (repo *testDBRepo) GetRestrictionsForRoomFeed(roomID int, since time.Time) ([]models.RoomRestriction, error) {
+	restrictions := []models.RoomRestriction{
+		{
+			ID:            1,
+			ReservationID: 1,
+			RestrictionID: 1,
+			RoomID:        roomID,
+			StartDate:     since.AddDate(0, 0, 10),
+			EndDate:       since.AddDate(0, 0, 12),
+			Reservation:   models.Reservation{ID: 1, FirstName: "John", LastName: "Smith"},
+			Restriction:   models.Restriction{ID: 1, RestrictionName: "Reservation"},
+		},
+		{
+			ID:            2,
+			RestrictionID: 2,
+			RoomID:        roomID,
+			StartDate:     since.AddDate(0, 0, 20),
+			EndDate:       since.AddDate(0, 0, 21),
+			Restriction:   models.Restriction{ID: 2, RestrictionName: "Owner Block"},
+		},
+	}
+	return restrictions, nil
+}
+
+// AllICalImports returns all of the calendar imports with their rooms
+func (repo *testDBRepo) AllICalImports() ([]models.ICalImport, error) {
+	var imports []models.ICalImport
+	return imports, nil
+}
+
+// GetICalImportById returns a calendar import by id
+func (repo *testDBRepo) GetICalImportById(id int) (models.ICalImport, error) {
+	var i models.ICalImport
+	if id > 2 {
+		return i, errors.New("some error")
+	}
+	i.ID = id
+	i.RoomID = 1
+	return i, nil
+}
+
+// InsertICalImport inserts a new calendar import into DB
+func (repo *testDBRepo) InsertICalImport(i models.ICalImport) (int, error) {
+	if i.RoomID > 2 {
+		return 0, errors.New("some error")
+	}
+	return 1, nil
+}
+
+// UpdateICalImportSync saves the result of the last sync of a calendar import
+func (repo *testDBRepo) UpdateICalImportSync(i models.ICalImport) error {
+	return nil
+}
+
+// DeleteICalImport deletes a calendar import
+func (repo *testDBRepo) DeleteICalImport(id int) error {
+	return nil
+}
+
+// ExternalBlocksForImport returns the blocks that are created by a calendar import
+func (repo *testDBRepo) ExternalBlocksForImport(importID int) ([]models.RoomRestriction, error) {
+	restrictions := []models.RoomRestriction{
+		{
+			ID:           5,
+			RoomID:       1,
+			StartDate:    time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC),
+			EndDate:      time.Date(2022, 8, 3, 0, 0, 0, 0, time.UTC),
+			ICalImportID: importID,
+			ExternalUID:  "moved@external",
+		},
+		{
+			ID:           6,
+			RoomID:       1,
+			StartDate:    time.Date(2022, 8, 10, 0, 0, 0, 0, time.UTC),
+			EndDate:      time.Date(2022, 8, 12, 0, 0, 0, 0, time.UTC),
+			ICalImportID: importID,
+			ExternalUID:  "removed@external",
+		},
+	}
+	return restrictions, nil
+}
+
+// InsertExternalBlockForRoom inserts a new block of the External restriction for an event of a calendar import
+func (repo *testDBRepo) InsertExternalBlockForRoom(importID, roomID int, uid string, startDate, endDate time.Time) error {
+	return nil
+}
+
+// UpdateExternalBlockForRoom moves an external block to the new dates of its event
+func (repo *testDBRepo) UpdateExternalBlockForRoom(id int, startDate, endDate time.Time) error {
+	return nil
+}
+
+// EachReservation calls fn for each reservation that matches the filter
+func (repo *testDBRepo) EachReservation(filter models.ReservationFilter, fn func(models.Reservation) error) error {
+	if filter.RoomID > 2 {
+		return errors.New("some error")
+	}
+
+	for _, x := range sampleReservations() {
+		if err := fn(x); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ImportReservationsAndBlocks saves the reservations and blocks of an import
+func (repo *testDBRepo) ImportReservationsAndBlocks(reservations []models.Reservation, blocks []models.RoomRestriction) error {
+	for _, r := range reservations {
+		if r.LastName == "Fail" {
+			return errors.New("some error")
+		}
+	}
+
+	return nil
+}
+
+// CountNewReservations returns the number of reservations that are not processed yet
+func (repo *testDBRepo) CountNewReservations() (int, error) {
+	return 2, nil
+}
+
+// DashboardStats returns the arrivals, departures and guests staying on the day, and the number of new reservations
+func (repo *testDBRepo) DashboardStats(day time.Time) (models.DashboardStats, error) {
+	return models.DashboardStats{Arrivals: 1, Departures: 2, InHouse: 3, Pending: 2}, nil
+}
+
+// OccupancyByRoom returns the reserved and blocked nights of each room between start and end
+func (repo *testDBRepo) OccupancyByRoom(start, end time.Time) ([]models.RoomOccupancy, error) {
+	nights := int(end.Sub(start).Hours() / 24)
+
+	occupancy := []models.RoomOccupancy{
+		{RoomID: 1, RoomName: "General's Quarters", ReservedNights: 10, BlockedNights: 2, Nights: nights},
+		{RoomID: 2, RoomName: "Major's Suite", Nights: nights},
+	}
+
+	return occupancy, nil
+}
+
+// BookingsPerWeek returns the number of reservations created in each week since the given day
+func (repo *testDBRepo) BookingsPerWeek(since time.Time) ([]models.WeeklyCount, error) {
+	weeks := []models.WeeklyCount{
+		{WeekStart: since.AddDate(0, 0, 14), Count: 3},
+	}
+
+	return weeks, nil
+}
+
+// sampleReservations returns the reservations of the testing repo's reservation lists
+func sampleReservations() []models.Reservation {
+	return []models.Reservation{
+		{
+			ID:        1,
+			FirstName: "John",
+			LastName:  "Smith",
+			Email:     "john@smith.com",
+			Phone:     "=1+2",
+			StartDate: time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC),
+			EndDate:   time.Date(2022, 8, 3, 0, 0, 0, 0, time.UTC),
+			RoomID:    1,
+			Room:      models.Room{ID: 1, RoomName: "General's Quarters"},
+			Notes:     sampleNotes(),
+		},
+		{
+			ID:        2,
+			FirstName: "Jane",
+			LastName:  "Doe",
+			Email:     "jane@doe.com",
+			StartDate: time.Date(2022, 8, 5, 0, 0, 0, 0, time.UTC),
+			EndDate:   time.Date(2022, 8, 6, 0, 0, 0, 0, time.UTC),
+			RoomID:    2,
+			Processed: 1,
+			Room:      models.Room{ID: 2, RoomName: "Major's Suite"},
+		},
+	}
+}
+
+// FilterReservations returns a page of the reservations that match the filter, and the number of all matching
+// reservations, the total is big enough to have pages unless the reservations are filtered by their ids
+func (repo *testDBRepo) FilterReservations(filter models.ReservationFilter) ([]models.Reservation, int, error) {
+	if filter.RoomID > 2 {
+		return nil, 0, errors.New("some error")
+	}
+
+	if len(filter.IDs) > 0 {
+		var reservations []models.Reservation
+
+		for _, res := range sampleReservations() {
+			for _, id := range filter.IDs {
+				if res.ID == id {
+					reservations = append(reservations, res)
+				}
+			}
+		}
+
+		return reservations, len(reservations), nil
+	}
+
+	return sampleReservations(), 60, nil
+}
+
+// InsertReservationWithRestriction saves the reservation and its room restriction
+func (repo *testDBRepo) InsertReservationWithRestriction(res models.Reservation) (int, error) {
+	if res.LastName == "Fail" {
+		return 0, errors.New("some error")
+	}
+
+	return 1, nil
+}
+
+// InsertRoom inserts a new room
+func (repo *testDBRepo) InsertRoom(r models.Room) (int, error) {
+	if r.RoomName == "Fail" {
+		return 0, errors.New("some error")
+	}
+
+	return 3, nil
+}
+
+// UpdateRoom updates a room, the room 2 has upcoming reservations and a room named Deleted is missing
+func (repo *testDBRepo) UpdateRoom(r models.Room, day time.Time) error {
+	if r.RoomName == "Fail" {
+		return errors.New("some error")
+	}
+
+	if r.ID == 2 && r.Active == 0 {
+		return repository.ErrRoomHasReservations
+	}
+
+	// the room is deleted while it's being edited
+	if r.RoomName == "Deleted" {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// UpdateRoomOrder saves the rooms' order
+func (repo *testDBRepo) UpdateRoomOrder(ids []int) error {
+	return nil
+}
+
+// FutureReservationsForRoom returns the upcoming reservations of the room, only the room 2 has one
+func (repo *testDBRepo) FutureReservationsForRoom(roomID int, day time.Time) ([]models.Reservation, error) {
+	var reservations []models.Reservation
+
+	if roomID == 2 {
+		reservations = append(reservations, models.Reservation{
+			ID:        1,
+			FirstName: "John",
+			LastName:  "Smith",
+			RoomID:    2,
+			StartDate: day.AddDate(0, 0, 7),
+			EndDate:   day.AddDate(0, 0, 9),
+		})
+	}
+
+	return reservations, nil
+}
+
+// GetUserByEmail returns a user by email, only taken@here.com exists
+func (repo *testDBRepo) GetUserByEmail(email string) (models.User, error) {
+	if email != "taken@here.com" {
+		return models.User{}, sql.ErrNoRows
+	}
+
+	return models.User{ID: 2, Email: email, Active: 1}, nil
+}
+
+// InsertUser inserts an invited user
+func (repo *testDBRepo) InsertUser(u models.User) (int, error) {
+	if u.LastName == "Fail" {
+		return 0, errors.New("some error")
+	}
+
+	return 3, nil
+}
+
+// ResetUserPassword removes the user's password
+func (repo *testDBRepo) ResetUserPassword(id int) error {
+	return nil
+}
+
+// InsertUserToken saves a new user token
+func (repo *testDBRepo) InsertUserToken(t models.UserToken) error {
+	return nil
+}
+
+// GetUserToken returns a user token, only valid-token and email-token are valid, email-token changes the email
+func (repo *testDBRepo) GetUserToken(token string) (models.UserToken, error) {
+	if token == "email-token" {
+		return models.UserToken{
+			ID:        2,
+			UserID:    2,
+			Token:     token,
+			Purpose:   models.TokenEmailChange,
+			Email:     "new@here.com",
+			ExpiresAt: time.Now().Add(time.Hour),
+			User:      models.User{ID: 2, FirstName: "Jane", LastName: "Doe", Email: "jane@here.com", Active: 1},
+		}, nil
+	}
+
+	if token != "valid-token" {
+		return models.UserToken{}, sql.ErrNoRows
+	}
+
+	return models.UserToken{
+		ID:        1,
+		UserID:    2,
+		Token:     token,
+		Purpose:   models.TokenInvitation,
+		ExpiresAt: time.Now().Add(time.Hour),
+		User:      models.User{ID: 2, FirstName: "Jane", LastName: "Doe", Email: "jane@here.com", Active: 1},
+	}, nil
+}
+
+// SetPasswordWithToken sets the user's password
+func (repo *testDBRepo) SetPasswordWithToken(t models.UserToken, password string) error {
+	return nil
+}
+
+// ConfirmEmailWithToken sets the user's email
+func (repo *testDBRepo) ConfirmEmailWithToken(t models.UserToken) error {
+	return nil
+}
+
+// ChangePassword sets the user's password
+func (repo *testDBRepo) ChangePassword(id int, password string) error {
+	return nil
+}
+
+// InsertAuditEntry saves an entry to the audit log
+func (repo *testDBRepo) InsertAuditEntry(e models.AuditEntry) error {
+	return nil
+}
+
+// FilterAuditEntries returns an audit entry, the filter is ignored
+func (repo *testDBRepo) FilterAuditEntries(filter models.AuditFilter) ([]models.AuditEntry, int, error) {
+	return []models.AuditEntry{
+		{
+			ID:       1,
+			UserID:   1,
+			Action:   models.AuditDelete,
+			Entity:   models.EntityReservation,
+			EntityID: 1,
+			Before:   `{"id": 1, "last_name": "Smith"}`,
+			IP:       "127.0.0.1",
+			User:     models.User{ID: 1, FirstName: "John", LastName: "Smith"},
+		},
+	}, 1, nil
+}
+
+// UpdateProcessedForReservations updates processed for all reservations
+func (repo *testDBRepo) UpdateProcessedForReservations(ids []int, processed int) error {
+	return nil
+}
+
+// DeleteReservations deletes all reservations
+func (repo *testDBRepo) DeleteReservations(ids []int) error {
+	return nil
+}
+
+// RoomTimeline returns two rooms, the first one has a reservation that starts before the period and a block
+func (repo *testDBRepo) RoomTimeline(start, end time.Time) ([]models.TimelineRoom, error) {
+	return []models.TimelineRoom{
+		{
+			Room: models.Room{ID: 1, RoomName: "General's Quarters", Active: 1},
+			Restrictions: []models.RoomRestriction{
+				{
+					ID:            1,
+					StartDate:     start.AddDate(0, 0, -2),
+					EndDate:       start.AddDate(0, 0, 3),
+					RoomID:        1,
+					RestrictionID: 1,
+					ReservationID: 1,
+					Reservation:   models.Reservation{ID: 1, FirstName: "John", LastName: "Smith"},
+				},
+				{
+					ID:            2,
+					StartDate:     start.AddDate(0, 0, 5),
+					EndDate:       start.AddDate(0, 0, 6),
+					RoomID:        1,
+					RestrictionID: 2,
+				},
+			},
+		},
+		{
+			Room: models.Room{ID: 2, RoomName: "Major's Suite", Active: 1},
+		},
+	}, nil
+}
+
+// sampleGuests returns the guests of the test reservations
+func sampleGuests() []models.Guest {
+	return []models.Guest{
+		{ID: 1, FirstName: "John", LastName: "Smith", Email: "john@smith.com", Tags: []string{"VIP"}, Stays: 2},
+		{ID: 2, FirstName: "Jane", LastName: "Doe", Email: "jane@doe.com", Notes: "Allergic to nuts", Stays: 1},
+	}
+}
+
+// FilterGuests returns a page of the guests that match the filter, and the number of all matching guests
+func (repo *testDBRepo) FilterGuests(filter models.GuestFilter) ([]models.Guest, int, error) {
+	return sampleGuests(), 2, nil
+}
+
+// GetGuestById returns a guest by id, only the sample guests exist
+func (repo *testDBRepo) GetGuestById(id int) (models.Guest, error) {
+	for _, g := range sampleGuests() {
+		if g.ID == id {
+			return g, nil
+		}
+	}
+
+	return models.Guest{}, sql.ErrNoRows
+}
+
+// GetGuestByEmail returns the guest with the email, only the sample guests exist
+func (repo *testDBRepo) GetGuestByEmail(email string) (models.Guest, error) {
+	for _, g := range sampleGuests() {
+		if g.Email == models.NormalizeEmail(email) {
+			return g, nil
+		}
+	}
+
+	return models.Guest{}, sql.ErrNoRows
+}
+
+// UpdateGuest updates a guest
+func (repo *testDBRepo) UpdateGuest(g models.Guest) error {
+	if g.LastName == "Fail" {
+		return errors.New("some error")
+	}
+
+	return nil
+}
+
+// ReservationsForGuest returns the reservations of a guest
+func (repo *testDBRepo) ReservationsForGuest(guestID int) ([]models.Reservation, error) {
+	return sampleReservations(), nil
+}
+
+// GuestDuplicates returns the guests that might be the same person, the sample guests are duplicates of each other
+func (repo *testDBRepo) GuestDuplicates(g models.Guest) ([]models.Guest, error) {
+	var guests []models.Guest
+
+	for _, d := range sampleGuests() {
+		if d.ID != g.ID {
+			guests = append(guests, d)
+		}
+	}
+
+	return guests, nil
+}
+
+// MergeGuests merges the duplicate into the guest
+func (repo *testDBRepo) MergeGuests(id, duplicateID int) error {
+	return nil
+}
+
+// sampleNotes returns the notes of the first sample reservation
+func sampleNotes() []models.ReservationNote {
+	return []models.ReservationNote{
+		{
+			ID:            1,
+			ReservationID: 1,
+			UserID:        1,
+			Body:          "Late arrival ~11pm",
+			CreatedAt:     time.Date(2022, 7, 20, 9, 30, 0, 0, time.UTC),
+			User:          models.User{ID: 1, FirstName: "Admin", LastName: "User"},
+		},
+		{
+			ID:            2,
+			ReservationID: 1,
+			Body:          "Paid cash deposit",
+			CreatedAt:     time.Date(2022, 7, 21, 14, 0, 0, 0, time.UTC),
+		},
+	}
+}
+
+// InsertReservationNote inserts a note of a reservation
+func (repo *testDBRepo) InsertReservationNote(n models.ReservationNote) (int, error) {
+	if n.Body == "Fail" {
+		return 0, errors.New("some error")
+	}
+
+	return 3, nil
+}
+
+// NotesForReservation returns the notes of a reservation, the first reservation has the sample notes
+func (repo *testDBRepo) NotesForReservation(reservationID int) ([]models.ReservationNote, error) {
+	if reservationID == 1 {
+		return sampleNotes(), nil
+	}
+
+	return nil, nil
+}
+
+// Search returns the sample reservations and guests, and a room, whose names contain the query
+func (repo *testDBRepo) Search(query string, limit int) (models.SearchResults, error) {
+	var results models.SearchResults
+
+	if query == "fail" {
+		return results, errors.New("some error")
+	}
+
+	matches := func(s string) bool {
+		return strings.Contains(strings.ToLower(s), strings.ToLower(query))
+	}
+
+	for _, r := range sampleReservations() {
+		if matches(r.FirstName+" "+r.LastName) || strconv.Itoa(r.ID) == strings.TrimPrefix(query, "#") {
+			results.Reservations = append(results.Reservations, r)
+		}
+	}
+
+	for _, g := range sampleGuests() {
+		if matches(g.FirstName + " " + g.LastName) {
+			results.Guests = append(results.Guests, g)
+		}
+	}
+
+	if room := (models.Room{ID: 1, RoomName: "General's Quarters", Active: 1}); matches(room.RoomName) {
+		results.Rooms = append(results.Rooms, room)
+	}
+
+	return results, nil
+}
+
+// EnableTwoFactor saves the user's TOTP secret and recovery codes
+func (repo *testDBRepo) EnableTwoFactor(userID int, secret string, recoveryCodes []string) error {
+	if userID > 2 {
+		return errors.New("some error")
+	}
+
+	return nil
+}
+
+// DisableTwoFactor removes the user's TOTP secret and recovery codes
+func (repo *testDBRepo) DisableTwoFactor(userID int) error {
+	return nil
+}
+
+// ReplaceRecoveryCodes replaces the user's recovery codes
+func (repo *testDBRepo) ReplaceRecoveryCodes(userID int, recoveryCodes []string) error {
+	return nil
+}
+
+// UseRecoveryCode marks the recovery code as used, only "abcde23456" is a valid code
+func (repo *testDBRepo) UseRecoveryCode(userID int, code string) error {
+	if code != "abcde23456" {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// CountRecoveryCodes returns how many of the user's recovery codes are not used
+func (repo *testDBRepo) CountRecoveryCodes(userID int) (int, error) {
+	return 8, nil
+}
+
+// InsertLoginFailure records a failed login
+func (repo *testDBRepo) InsertLoginFailure(email, ip string) error {
+	return nil
+}
+
+// LoginFailuresSince counts failed logins, locked@here.com and 10.0.0.1 are locked out, slow@here.com must wait,
+// and taken@here.com is locked out after one more failure
+func (repo *testDBRepo) LoginFailuresSince(email, ip string, since time.Time) (models.LoginFailures, models.LoginFailures, error) {
+	account := models.LoginFailures{Email: email}
+	byIP := models.LoginFailures{IP: ip}
+
+	switch email {
+	case "locked@here.com":
+		account.Count, account.Last = 10, time.Now()
+	case "slow@here.com":
+		account.Count, account.Last = 5, time.Now()
+	case "taken@here.com":
+		account.Count, account.Last = 9, time.Now().Add(-time.Hour)
+	}
+
+	if ip == "10.0.0.1" {
+		byIP.Count, byIP.Last = 100, time.Now()
+	}
+
+	return account, byIP, nil
+}
+
+// DeleteLoginFailuresBefore removes old failed logins
+func (repo *testDBRepo) DeleteLoginFailuresBefore(before time.Time) (int64, error) {
+	return 0, nil
+}
+
+// ClearLoginFailures removes failed logins
+func (repo *testDBRepo) ClearLoginFailures(email, ip string) error {
+	return nil
+}
+
+// LockedLogins returns a locked out email and IP address
+func (repo *testDBRepo) LockedLogins(since time.Time, accountLimit, ipLimit int) ([]models.LoginFailures, error) {
+	return []models.LoginFailures{
+		{Email: "locked@here.com", Count: accountLimit, Last: time.Now()},
+		{IP: "10.0.0.1", Count: ipLimit, Last: time.Now()},
+	}, nil
+}
@@ -1,23 +1,30 @@
 package repository
 
 import (
+	"errors"
 	"time"
 
 	"github.com/burakkarasel/bookings/internal/models"
 )
 
+// ErrRoomHasReservations is returned when a room with upcoming reservations is deactivated
+var ErrRoomHasReservations = errors.New("room has upcoming reservations")
+
+// ErrReservationsChanged is returned when some reservations of a bulk action don't exist anymore, none of them are
+// changed then
+var ErrReservationsChanged = errors.New("some reservations don't exist anymore")
+
 type DatabaseRepo interface {
-	AllUsers() bool
+	AllUsers() ([]models.User, error)
 	InsertReservation(res models.Reservation) (int, error)
 	InsertRoomRestriction(r models.RoomRestriction) error
 	SearchAvailabilityByDatesByRoomID(start, end time.Time, roomID int) (bool, error)
 	SearchAvailabilityForAllRooms(start, end time.Time) ([]models.Room, error)
+	SearchAvailabilityExcludingReservation(start, end time.Time, roomID, reservationID int) (bool, error)
 	GetRoomById(id int) (models.Room, error)
 	GetUserById(id int) (models.User, error)
 	UpdateUser(u models.User) error
 	Authenticate(email, testPassword string) (int, string, error)
-	AllReservations() ([]models.Reservation, error)
-	AllNewReservations() ([]models.Reservation, error)
 	GetReservationById(id int) (models.Reservation, error)
 	UpdateReservation(r models.Reservation) error
 	DeleteReservation(id int) error
@@ -26,4 +33,71 @@ type DatabaseRepo interface {
 	GetRestrictionsForRoomByDate(roomID int, start, end time.Time) ([]models.RoomRestriction, error)
 	InsertBlockForRoom(id int, startDate time.Time) error
 	RemoveBlockForRoom(id int) error
+	AllWebhookEndpoints() ([]models.WebhookEndpoint, error)
+	ActiveWebhookEndpoints() ([]models.WebhookEndpoint, error)
+	GetWebhookEndpointById(id int) (models.WebhookEndpoint, error)
+	InsertWebhookEndpoint(e models.WebhookEndpoint) (int, error)
+	UpdateWebhookEndpoint(e models.WebhookEndpoint) error
+	DeleteWebhookEndpoint(id int) error
+	InsertWebhookDelivery(d models.WebhookDelivery) (int, error)
+	UpdateWebhookDelivery(d models.WebhookDelivery) error
+	WebhookDeliveriesForEndpoint(endpointID, limit int) ([]models.WebhookDelivery, error)
+	AllICalFeeds() ([]models.ICalFeed, error)
+	GetICalFeedByToken(token string) (models.ICalFeed, error)
+	InsertICalFeed(f models.ICalFeed) (int, error)
+	DeleteICalFeed(id int) error
+	GetRestrictionsForRoomFeed(roomID int, since time.Time) ([]models.RoomRestriction, error)
+	AllICalImports() ([]models.ICalImport, error)
+	GetICalImportById(id int) (models.ICalImport, error)
+	InsertICalImport(i models.ICalImport) (int, error)
+	UpdateICalImportSync(i models.ICalImport) error
+	DeleteICalImport(id int) error
+	ExternalBlocksForImport(importID int) ([]models.RoomRestriction, error)
+	InsertExternalBlockForRoom(importID, roomID int, uid string, startDate, endDate time.Time) error
+	UpdateExternalBlockForRoom(id int, startDate, endDate time.Time) error
+	EachReservation(filter models.ReservationFilter, fn func(models.Reservation) error) error
+	FilterReservations(filter models.ReservationFilter) ([]models.Reservation, int, error)
+	InsertReservationNote(n models.ReservationNote) (int, error)
+	NotesForReservation(reservationID int) ([]models.ReservationNote, error)
+	Search(query string, limit int) (models.SearchResults, error)
+	EnableTwoFactor(userID int, secret string, recoveryCodes []string) error
+	DisableTwoFactor(userID int) error
+	ReplaceRecoveryCodes(userID int, recoveryCodes []string) error
+	UseRecoveryCode(userID int, code string) error
+	CountRecoveryCodes(userID int) (int, error)
+	InsertLoginFailure(email, ip string) error
+	LoginFailuresSince(email, ip string, since time.Time) (models.LoginFailures, models.LoginFailures, error)
+	ClearLoginFailures(email, ip string) error
+	DeleteLoginFailuresBefore(before time.Time) (int64, error)
+	LockedLogins(since time.Time, accountLimit, ipLimit int) ([]models.LoginFailures, error)
+	ImportReservationsAndBlocks(reservations []models.Reservation, blocks []models.RoomRestriction) error
+	InsertReservationWithRestriction(res models.Reservation) (int, error)
+	CountNewReservations() (int, error)
+	DashboardStats(day time.Time) (models.DashboardStats, error)
+	OccupancyByRoom(start, end time.Time) ([]models.RoomOccupancy, error)
+	BookingsPerWeek(since time.Time) ([]models.WeeklyCount, error)
+	InsertRoom(r models.Room) (int, error)
+	UpdateRoom(r models.Room, day time.Time) error
+	UpdateRoomOrder(ids []int) error
+	FutureReservationsForRoom(roomID int, day time.Time) ([]models.Reservation, error)
+	GetUserByEmail(email string) (models.User, error)
+	InsertUser(u models.User) (int, error)
+	ResetUserPassword(id int) error
+	InsertUserToken(t models.UserToken) error
+	GetUserToken(token string) (models.UserToken, error)
+	SetPasswordWithToken(t models.UserToken, password string) error
+	ConfirmEmailWithToken(t models.UserToken) error
+	ChangePassword(id int, password string) error
+	InsertAuditEntry(e models.AuditEntry) error
+	UpdateProcessedForReservations(ids []int, processed int) error
+	DeleteReservations(ids []int) error
+	RoomTimeline(start, end time.Time) ([]models.TimelineRoom, error)
+	FilterGuests(filter models.GuestFilter) ([]models.Guest, int, error)
+	GetGuestById(id int) (models.Guest, error)
+	GetGuestByEmail(email string) (models.Guest, error)
+	UpdateGuest(g models.Guest) error
+	ReservationsForGuest(guestID int) ([]models.Reservation, error)
+	GuestDuplicates(g models.Guest) ([]models.Guest, error)
+	MergeGuests(id, duplicateID int) error
+	FilterAuditEntries(filter models.AuditFilter) ([]models.AuditEntry, int, error)
 }
@@ -0,0 +1,85 @@
+package sessionstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+)
+
+// PostgresStore keeps sessions in the sessions table, so they survive restarts and are shared by all app instances
+type PostgresStore struct {
+	DB *sql.DB
+}
+
+// the store satisfies the interface the session manager uses
+var _ scs.Store = (*PostgresStore)(nil)
+
+// NewPostgresStore creates a new session store with the DB
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{DB: db}
+}
+
+// Find returns the data of an unexpired session, found is false if the token doesn't exist or is expired
+func (s *PostgresStore) Find(token string) ([]byte, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var b []byte
+
+	row := s.DB.QueryRowContext(ctx, "select data from sessions where token = $1 and expiry > $2", token, time.Now())
+	err := row.Scan(&b)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	return b, true, nil
+}
+
+// Commit saves the session's data, an existing session with the token is replaced
+func (s *PostgresStore) Commit(token string, b []byte, expiry time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `
+		insert into sessions (token, data, expiry)
+		values ($1, $2, $3)
+		on conflict (token) do update set data = excluded.data, expiry = excluded.expiry
+	`
+
+	_, err := s.DB.ExecContext(ctx, query, token, b, expiry)
+
+	return err
+}
+
+// Delete removes the session with the token, a token that doesn't exist isn't an error
+func (s *PostgresStore) Delete(token string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := s.DB.ExecContext(ctx, "delete from sessions where token = $1", token)
+
+	return err
+}
+
+// DeleteExpired removes the expired sessions and returns how many were removed, Find skips them already, so this only
+// keeps the table small
+func (s *PostgresStore) DeleteExpired() (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := s.DB.ExecContext(ctx, "delete from sessions where expiry < $1", time.Now())
+
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,322 @@
+package sessionstore
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeDrv is a database driver that keeps the sessions table in memory, it understands only the store's queries, so
+// the tests run without a postgres server
+var fakeDrv = &fakeDriver{dbs: make(map[string]*fakeDB)}
+
+// TestMain registers the fake driver before our tests run
+func TestMain(m *testing.M) {
+	sql.Register("fakepostgres", fakeDrv)
+
+	os.Exit(m.Run())
+}
+
+// newTestStore returns a store with an empty sessions table, and the table to check what the store saved
+func newTestStore(t *testing.T) (*PostgresStore, *fakeDB) {
+	db, err := sql.Open("fakepostgres", t.Name())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { db.Close() })
+
+	return NewPostgresStore(db), fakeDrv.db(t.Name())
+}
+
+// TestPostgresStore_CommitAndFind checks if a committed session is found, and a second commit replaces it
+func TestPostgresStore_CommitAndFind(t *testing.T) {
+	store, _ := newTestStore(t)
+
+	err := store.Commit("token", []byte("first"), time.Now().Add(time.Hour))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = store.Commit("token", []byte("second"), time.Now().Add(2*time.Hour))
+
+	if err != nil {
+		t.Fatalf("expected the second commit to replace the session, got %s", err)
+	}
+
+	b, found, err := store.Find("token")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !found || string(b) != "second" {
+		t.Errorf("got %q, found %t, wanted the data of the second commit", b, found)
+	}
+}
+
+// TestPostgresStore_CommitExtendsExpiry checks if committing an expired session's token saves the new expiry
+func TestPostgresStore_CommitExtendsExpiry(t *testing.T) {
+	store, table := newTestStore(t)
+
+	_ = store.Commit("token", []byte("data"), time.Now().Add(-time.Minute))
+
+	expiry := time.Now().Add(time.Hour)
+	_ = store.Commit("token", []byte("data"), expiry)
+
+	if got := table.sessions["token"].expiry; !got.Equal(expiry) {
+		t.Errorf("got expiry %s, wanted %s", got, expiry)
+	}
+
+	if _, found, _ := store.Find("token"); !found {
+		t.Error("expected the session to be found after its expiry is extended")
+	}
+}
+
+// TestPostgresStore_Find checks if missing and expired tokens aren't found and aren't errors
+func TestPostgresStore_Find(t *testing.T) {
+	store, _ := newTestStore(t)
+
+	_ = store.Commit("expired", []byte("data"), time.Now().Add(-time.Second))
+
+	var tests = []struct {
+		name  string
+		token string
+	}{
+		{"missing token", "missing"},
+		{"expired token", "expired"},
+	}
+
+	for _, tt := range tests {
+		b, found, err := store.Find(tt.token)
+
+		if err != nil {
+			t.Errorf("for %s: got error %s, wanted none", tt.name, err)
+		}
+
+		if found || b != nil {
+			t.Errorf("for %s: got %q, found %t, wanted nothing", tt.name, b, found)
+		}
+	}
+}
+
+// TestPostgresStore_FindError checks if database errors aren't hidden as missing sessions
+func TestPostgresStore_FindError(t *testing.T) {
+	store, table := newTestStore(t)
+
+	table.fail = true
+
+	_, found, err := store.Find("token")
+
+	if err == nil || found {
+		t.Errorf("got found %t and error %v, wanted an error", found, err)
+	}
+}
+
+// TestPostgresStore_Delete checks if a deleted session isn't found, and deleting a missing token isn't an error
+func TestPostgresStore_Delete(t *testing.T) {
+	store, _ := newTestStore(t)
+
+	_ = store.Commit("token", []byte("data"), time.Now().Add(time.Hour))
+
+	err := store.Delete("token")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, found, _ := store.Find("token"); found {
+		t.Error("expected the deleted session not to be found")
+	}
+
+	if err = store.Delete("token"); err != nil {
+		t.Errorf("got error %s for a missing token, wanted none", err)
+	}
+}
+
+// TestPostgresStore_DeleteExpired checks if only the expired sessions are removed and counted
+func TestPostgresStore_DeleteExpired(t *testing.T) {
+	store, table := newTestStore(t)
+
+	_ = store.Commit("expired", []byte("data"), time.Now().Add(-time.Hour))
+	_ = store.Commit("also-expired", []byte("data"), time.Now().Add(-time.Second))
+	_ = store.Commit("active", []byte("data"), time.Now().Add(time.Hour))
+
+	n, err := store.DeleteExpired()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 2 {
+		t.Errorf("got %d removed sessions, wanted 2", n)
+	}
+
+	if len(table.sessions) != 1 || table.sessions["active"].data == nil {
+		t.Errorf("expected only the active session to be left, got %d sessions", len(table.sessions))
+	}
+
+	table.fail = true
+
+	if _, err = store.DeleteExpired(); err == nil {
+		t.Error("expected the database error to be returned")
+	}
+}
+
+// fakeSession is a row of the fake sessions table
+type fakeSession struct {
+	data   []byte
+	expiry time.Time
+}
+
+// fakeDB is a sessions table, fail makes every query fail
+type fakeDB struct {
+	mu       sync.Mutex
+	sessions map[string]fakeSession
+	fail     bool
+}
+
+// fakeDriver keeps a table for every data source name, so tests don't share sessions
+type fakeDriver struct {
+	mu  sync.Mutex
+	dbs map[string]*fakeDB
+}
+
+// db returns the table of the data source name, it's created on first use
+func (d *fakeDriver) db(name string) *fakeDB {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.dbs[name] == nil {
+		d.dbs[name] = &fakeDB{sessions: make(map[string]fakeSession)}
+	}
+
+	return d.dbs[name]
+}
+
+// Open opens a connection to the table of the data source name
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	return &fakeConn{db: d.db(name)}, nil
+}
+
+// fakeConn runs the store's queries against its table
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements aren't supported")
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions aren't supported")
+}
+
+// QueryContext runs the store's select
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+
+	if c.db.fail {
+		return nil, errors.New("connection refused")
+	}
+
+	if query != "select data from sessions where token = $1 and expiry > $2" {
+		return nil, errors.New("unexpected query: " + query)
+	}
+
+	rows := &fakeRows{}
+
+	s, ok := c.db.sessions[args[0].Value.(string)]
+
+	if ok && s.expiry.After(args[1].Value.(time.Time)) {
+		rows.data = append(rows.data, s.data)
+	}
+
+	return rows, nil
+}
+
+// ExecContext runs the store's insert and deletes
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+
+	if c.db.fail {
+		return nil, errors.New("connection refused")
+	}
+
+	query = strings.Join(strings.Fields(query), " ")
+
+	switch {
+	case strings.HasPrefix(query, "insert into sessions (token, data, expiry) values ($1, $2, $3)"):
+		token := args[0].Value.(string)
+
+		if _, ok := c.db.sessions[token]; ok && !strings.HasSuffix(query, "on conflict (token) do update set data = excluded.data, expiry = excluded.expiry") {
+			return nil, errors.New(`duplicate key value violates unique constraint "sessions_pkey"`)
+		}
+
+		c.db.sessions[token] = fakeSession{data: append([]byte(nil), args[1].Value.([]byte)...), expiry: args[2].Value.(time.Time)}
+
+		return driver.RowsAffected(1), nil
+	case query == "delete from sessions where token = $1":
+		token := args[0].Value.(string)
+
+		if _, ok := c.db.sessions[token]; !ok {
+			return driver.RowsAffected(0), nil
+		}
+
+		delete(c.db.sessions, token)
+
+		return driver.RowsAffected(1), nil
+	case query == "delete from sessions where expiry < $1":
+		var n int64
+
+		for token, s := range c.db.sessions {
+			if s.expiry.Before(args[0].Value.(time.Time)) {
+				delete(c.db.sessions, token)
+				n++
+			}
+		}
+
+		return driver.RowsAffected(n), nil
+	}
+
+	return nil, errors.New("unexpected query: " + query)
+}
+
+// fakeRows returns the data column of the found sessions
+type fakeRows struct {
+	data [][]byte
+}
+
+func (r *fakeRows) Columns() []string {
+	return []string{"data"}
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.data) == 0 {
+		return io.EOF
+	}
+
+	dest[0] = r.data[0]
+	r.data = r.data[1:]
+
+	return nil
+}
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/burakkarasel/bookings/internal/config"
+	"github.com/burakkarasel/bookings/internal/helpers"
 	"github.com/burakkarasel/bookings/internal/models"
 	"github.com/justinas/nosurf"
 )
@@ -34,6 +35,7 @@ func AddDefaultData(td *models.TemplateData, r *http.Request) *models.TemplateDa
 	if app.Session.Exists(r.Context(), "user_id") {
 		td.IsAuthenticated = 1
 	}
+	td.User = helpers.CurrentUser(r)
 	// we add CSRF token to our default data
 	td.CSRFToken = nosurf.Token(r)
 	return td
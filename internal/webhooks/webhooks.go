@@ -0,0 +1,161 @@
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/burakkarasel/bookings/internal/config"
+	"github.com/burakkarasel/bookings/internal/models"
+	"github.com/burakkarasel/bookings/internal/repository"
+)
+
+const defaultMaxAttempts = 6
+const defaultBaseDelay = 5 * time.Second
+const maxResponseLength = 1024
+
+// Dispatcher sends events to the webhook endpoints that subscribed to them
+type Dispatcher struct {
+	App         *config.AppConfig
+	DB          repository.DatabaseRepo
+	Client      *http.Client
+	MaxAttempts int
+	BaseDelay   time.Duration
+}
+
+// NewDispatcher creates a new dispatcher with default retry settings
+func NewDispatcher(a *config.AppConfig, db repository.DatabaseRepo) *Dispatcher {
+	return &Dispatcher{
+		App:         a,
+		DB:          db,
+		Client:      &http.Client{Timeout: 10 * time.Second},
+		MaxAttempts: defaultMaxAttempts,
+		BaseDelay:   defaultBaseDelay,
+	}
+}
+
+// Dispatch finds the active endpoints subscribed to the event and delivers it to each of them in the background
+func (d *Dispatcher) Dispatch(e models.Event) {
+	endpoints, err := d.DB.ActiveWebhookEndpoints()
+
+	if err != nil {
+		d.App.ErrorLog.Println(err)
+		return
+	}
+
+	payload, err := json.Marshal(e)
+
+	if err != nil {
+		d.App.ErrorLog.Println(err)
+		return
+	}
+
+	for _, endpoint := range endpoints {
+		if !endpoint.Subscribes(e.Name) {
+			continue
+		}
+		go d.Deliver(endpoint, e.Name, payload)
+	}
+}
+
+// Deliver posts the payload to the endpoint, and retries with exponential backoff until it succeeds or runs out of
+// attempts, each attempt's result is recorded in the delivery log
+func (d *Dispatcher) Deliver(endpoint models.WebhookEndpoint, event string, payload []byte) models.WebhookDelivery {
+	delivery := models.WebhookDelivery{
+		EndpointID: endpoint.ID,
+		Event:      event,
+		Payload:    string(payload),
+	}
+
+	id, err := d.DB.InsertWebhookDelivery(delivery)
+
+	if err != nil {
+		d.App.ErrorLog.Println(err)
+		return delivery
+	}
+
+	delivery.ID = id
+
+	for delivery.Attempts < d.MaxAttempts {
+		if delivery.Attempts > 0 {
+			time.Sleep(Backoff(d.BaseDelay, delivery.Attempts))
+		}
+
+		delivery.Attempts++
+		delivery.StatusCode, delivery.Response = d.post(endpoint, delivery, payload)
+
+		if delivery.StatusCode >= 200 && delivery.StatusCode < 300 {
+			delivery.Delivered = 1
+		}
+
+		err = d.DB.UpdateWebhookDelivery(delivery)
+
+		if err != nil {
+			d.App.ErrorLog.Println(err)
+		}
+
+		if delivery.Delivered == 1 {
+			break
+		}
+	}
+
+	return delivery
+}
+
+// post makes a single signed request to the endpoint, and returns the status code and the response body or the error
+func (d *Dispatcher) post(endpoint models.WebhookEndpoint, delivery models.WebhookDelivery, payload []byte) (int, string) {
+	req, err := http.NewRequest("POST", endpoint.URL, bytes.NewReader(payload))
+
+	if err != nil {
+		return 0, err.Error()
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Bookings-Event", delivery.Event)
+	req.Header.Set("X-Bookings-Delivery", fmt.Sprintf("%d", delivery.ID))
+	req.Header.Set("X-Bookings-Signature", "sha256="+Sign(endpoint.Secret, payload))
+
+	resp, err := d.Client.Do(req)
+
+	if err != nil {
+		return 0, err.Error()
+	}
+
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseLength))
+
+	return resp.StatusCode, string(body)
+}
+
+// Sign returns hex encoded HMAC-SHA256 of the payload with the endpoint's secret, receivers use it to verify requests
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Backoff returns how long we wait before the next attempt, it doubles after each failed attempt
+func Backoff(base time.Duration, attempts int) time.Duration {
+	return base * time.Duration(1<<uint(attempts-1))
+}
+
+// NewSecret generates a random secret for a new endpoint
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+
+	_, err := rand.Read(b)
+
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
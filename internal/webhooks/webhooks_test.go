@@ -0,0 +1,118 @@
+package webhooks
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/burakkarasel/bookings/internal/config"
+	"github.com/burakkarasel/bookings/internal/models"
+	"github.com/burakkarasel/bookings/internal/repository/dbrepo"
+)
+
+var testApp config.AppConfig
+
+// TestMain builds environment to run our tests for webhooks.go
+func TestMain(m *testing.M) {
+	testApp.InfoLog = log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
+	testApp.ErrorLog = log.New(os.Stdout, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
+
+	os.Exit(m.Run())
+}
+
+// TestDispatcher_Deliver checks if the delivery is signed and retried until the endpoint responds successfully
+func TestDispatcher_Deliver(t *testing.T) {
+	var calls int
+	payload := []byte(`{"event":"reservation.created"}`)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+
+		body, _ := io.ReadAll(r.Body)
+
+		if r.Header.Get("X-Bookings-Signature") != "sha256="+Sign("secret", body) {
+			t.Error("got wrong signature")
+		}
+
+		if r.Header.Get("X-Bookings-Event") != models.EventReservationCreated {
+			t.Errorf("got event header %s, wanted %s", r.Header.Get("X-Bookings-Event"), models.EventReservationCreated)
+		}
+
+		if calls < 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	d := NewDispatcher(&testApp, dbrepo.NewTestingRepo(&testApp))
+	d.BaseDelay = time.Millisecond
+
+	endpoint := models.WebhookEndpoint{ID: 1, URL: srv.URL, Secret: "secret"}
+
+	delivery := d.Deliver(endpoint, models.EventReservationCreated, payload)
+
+	if delivery.Delivered != 1 {
+		t.Error("delivery should have been delivered")
+	}
+
+	if delivery.Attempts != 3 {
+		t.Errorf("got %d attempts, wanted 3", delivery.Attempts)
+	}
+
+	if delivery.StatusCode != http.StatusOK {
+		t.Errorf("got status code %d, wanted %d", delivery.StatusCode, http.StatusOK)
+	}
+}
+
+// TestDispatcher_DeliverGivesUp checks if the delivery stops after max attempts
+func TestDispatcher_DeliverGivesUp(t *testing.T) {
+	var calls int
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	d := NewDispatcher(&testApp, dbrepo.NewTestingRepo(&testApp))
+	d.BaseDelay = time.Millisecond
+	d.MaxAttempts = 4
+
+	delivery := d.Deliver(models.WebhookEndpoint{ID: 1, URL: srv.URL}, models.EventBlockAdded, []byte("{}"))
+
+	if delivery.Delivered != 0 {
+		t.Error("delivery should not have been delivered")
+	}
+
+	if calls != 4 {
+		t.Errorf("endpoint got %d calls, wanted 4", calls)
+	}
+}
+
+// TestBackoff checks if the delay doubles after each attempt
+func TestBackoff(t *testing.T) {
+	base := time.Second
+
+	for attempts, expected := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 5: 16 * time.Second} {
+		if got := Backoff(base, attempts); got != expected {
+			t.Errorf("for %d attempts got %s, wanted %s", attempts, got, expected)
+		}
+	}
+}
+
+// TestSign checks if Sign returns a known HMAC-SHA256 value
+func TestSign(t *testing.T) {
+	got := Sign("key", []byte("The quick brown fox jumps over the lazy dog"))
+	expected := "f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8"
+
+	if got != expected {
+		t.Errorf("got %s, wanted %s", got, expected)
+	}
+}
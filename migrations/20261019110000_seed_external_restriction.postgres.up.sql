@@ -0,0 +1,2 @@
+INSERT INTO public.restrictions (restriction_name,created_at,updated_at) VALUES
+                                                               ('External','2026-10-19 00:00:00.000','2026-10-19 00:00:00.000');
\ No newline at end of file
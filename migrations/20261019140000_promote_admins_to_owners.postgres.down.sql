@@ -0,0 +1 @@
+update users set access_level = 3 where access_level = 4;
\ No newline at end of file
@@ -0,0 +1,2 @@
+-- access level 3 was the highest before the roles, those users become owners
+update users set access_level = 4 where access_level = 3;
\ No newline at end of file
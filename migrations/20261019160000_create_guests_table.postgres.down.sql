@@ -0,0 +1,3 @@
+alter table reservations drop column guest_id;
+
+drop table guests;
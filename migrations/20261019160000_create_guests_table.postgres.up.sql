@@ -0,0 +1,38 @@
+create table guests (
+    id serial primary key,
+    first_name varchar(255) not null default '',
+    last_name varchar(255) not null default '',
+    email varchar(255) not null default '',
+    email_normalized varchar(255) not null default '',
+    phone varchar(255) not null default '',
+    notes text not null default '',
+    tags varchar(255) not null default '',
+    created_at timestamp not null,
+    updated_at timestamp not null
+);
+
+-- guests are deduplicated by their normalized email, guests without an email can't be matched
+create unique index guests_email_normalized_idx on guests (email_normalized) where email_normalized <> '';
+
+alter table reservations add column guest_id integer null;
+
+alter table reservations add constraint reservations_guests_guest_id_fk foreign key (guest_id) references guests (id)
+    on delete set null on update cascade;
+
+create index reservations_guest_id_idx on reservations (guest_id);
+
+-- the existing reservations get their guests, the latest reservation of an email gives its guest's details
+do $$
+declare
+    res record;
+    new_guest_id integer;
+begin
+    for res in select id, first_name, last_name, email, phone from reservations order by created_at desc, id desc loop
+        insert into guests (first_name, last_name, email, email_normalized, phone, created_at, updated_at)
+        values (res.first_name, res.last_name, trim(res.email), lower(trim(res.email)), res.phone, now(), now())
+        on conflict (email_normalized) where email_normalized <> '' do update set updated_at = guests.updated_at
+        returning id into new_guest_id;
+
+        update reservations set guest_id = new_guest_id where id = res.id;
+    end loop;
+end $$;
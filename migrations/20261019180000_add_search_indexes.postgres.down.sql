@@ -0,0 +1,12 @@
+drop index rooms_description_fts_idx;
+drop index rooms_room_name_trgm_idx;
+
+drop index guests_phone_trgm_idx;
+drop index guests_email_trgm_idx;
+drop index guests_name_trgm_idx;
+
+drop index reservations_phone_trgm_idx;
+drop index reservations_email_trgm_idx;
+drop index reservations_name_trgm_idx;
+
+-- the extension is kept, other database objects might use it
@@ -0,0 +1,15 @@
+-- trigram indexes let the admin search match any part of names, emails and phones with ilike
+create extension if not exists pg_trgm;
+
+create index reservations_name_trgm_idx on reservations using gin ((first_name || ' ' || last_name) gin_trgm_ops);
+create index reservations_email_trgm_idx on reservations using gin (email gin_trgm_ops);
+create index reservations_phone_trgm_idx on reservations using gin (phone gin_trgm_ops);
+
+create index guests_name_trgm_idx on guests using gin ((first_name || ' ' || last_name) gin_trgm_ops);
+create index guests_email_trgm_idx on guests using gin (email gin_trgm_ops);
+create index guests_phone_trgm_idx on guests using gin (phone gin_trgm_ops);
+
+create index rooms_room_name_trgm_idx on rooms using gin (room_name gin_trgm_ops);
+
+-- descriptions are longer texts, so they are searched by their words
+create index rooms_description_fts_idx on rooms using gin (to_tsvector('simple', description));
@@ -0,0 +1,8 @@
+-- sessions of the postgres session store, data is the encoded session and expired sessions are cleaned up periodically
+create table sessions (
+    token text primary key,
+    data bytea not null,
+    expiry timestamptz not null
+);
+
+create index sessions_expiry_idx on sessions (expiry);